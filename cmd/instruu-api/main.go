@@ -1,43 +1,259 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/natethinks/instruu-api/internal/auth"
+	"github.com/natethinks/instruu-api/internal/mail"
 	"github.com/natethinks/instruu-api/internal/server"
+	"github.com/natethinks/instruu-api/internal/store"
+	"github.com/natethinks/instruu-api/internal/store/bolt"
+	"github.com/natethinks/instruu-api/internal/store/memory"
 	"github.com/natethinks/instruu-api/internal/store/postgres"
 )
 
 func main() {
+	storeKind := flag.String("store", envOr("INSTRUU_STORE", "postgres"), "store backend to use: postgres, bolt or memory")
+	boltPath := flag.String("bolt-path", envOr("INSTRUU_BOLT_PATH", "server.db"), "path to the bolt database file")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.Arg(0) == "migrate" {
+		if err := migrate(flag.Arg(1)); err != nil {
+			log.Fatalf("migrating database: %v\n", err)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "role" {
+		sto := openStore(*storeKind, *boltPath)
+		err := role(sto, flag.Arg(1), flag.Arg(2), flag.Arg(3))
+		sto.Close()
+
+		if err != nil {
+			log.Fatalf("updating role: %v\n", err)
+		}
+		return
+	}
+
+	options, err := serverOptions()
+	if err != nil {
+		log.Fatalf("configuring server: %v\n", err)
+	}
+
+	mailer, err := mailBackend()
+	if err != nil {
+		log.Fatalf("configuring mail: %v\n", err)
+	}
+
+	sto := openStore(*storeKind, *boltPath)
+
+	// mail goes through the outbox so it's retried when the backend fails
+	outbox := mail.NewOutbox(sto, mailer)
+	stop := make(chan struct{})
+	go outbox.Run(stop)
+	options.Mailer = outbox
+
+	s := server.New(sto, options)
+
+	addr := os.Getenv("INSTRUU_ADDR")
+	fmt.Printf("Starting server on port %v\n", addr)
+
+	if err := s.Run(addr); err != nil {
+		log.Fatalf("running server: %v\n", err)
+	}
+
+	close(stop)
+	sto.Close()
+}
+
+// openStore opens the configured store backend, exiting when it can't
+func openStore(storeKind, boltPath string) store.Service {
+	var (
+		sto store.Service
+		err error
+	)
+	switch storeKind {
+	case "postgres":
+		sto, err = postgresStore()
+		if err != nil {
+			log.Fatalf("connecting to postgres database: %v\n", err)
+		}
+	case "bolt":
+		sto, err = bolt.New(boltPath)
+		if err != nil {
+			log.Fatalf("opening bolt database: %v\n", err)
+		}
+	case "memory":
+		// nothing is persisted, only use this for local development
+		sto = memory.New()
+	default:
+		log.Fatalf("unknown store: %s\n", storeKind)
+	}
+
+	return sto
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s [flags] [migrate up|down|status | role grant|revoke <role> <user id>]\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func postgresStore() (store.Service, error) {
+	options, err := postgresOptions()
+	if err != nil {
+		return nil, err
+	}
+	return postgres.New(options)
+}
+
+// postgresOptions reads the postgres connection settings from the environment
+func postgresOptions() (postgres.Options, error) {
 	portString := os.Getenv("POSTGRES_PORT")
 	port, err := strconv.Atoi(portString)
 	if err != nil {
-		log.Fatalf("invalid port: %s\n", portString)
+		return postgres.Options{}, fmt.Errorf("invalid port: %s", portString)
 	}
 
-	sto, err := postgres.New(postgres.Options{
+	return postgres.Options{
 		User:    os.Getenv("POSTGRES_USER"),
 		Pass:    os.Getenv("POSTGRES_PASS"),
 		Host:    os.Getenv("POSTGRES_HOST"),
 		Port:    port,
 		DBName:  os.Getenv("POSTGRES_DB_NAME"),
 		SSLMode: os.Getenv("POSTGRES_SSL_MODE"),
-	})
+	}, nil
+}
+
+// serverOptions reads the server settings from the environment
+func serverOptions() (server.Options, error) {
+	options := server.Options{Secret: []byte(os.Getenv("INSTRUU_SECRET"))}
+	if len(options.Secret) == 0 {
+		log.Println("INSTRUU_SECRET isn't set, tokens will stop working when the server restarts")
+	}
 
+	if value := os.Getenv("INSTRUU_REQUIRE_VERIFIED"); value != "" {
+		required, err := strconv.ParseBool(value)
+		if err != nil {
+			return options, fmt.Errorf("invalid INSTRUU_REQUIRE_VERIFIED: %s", value)
+		}
+		options.RequireVerified = required
+	}
+
+	options.Tokens = auth.TokenOptions{
+		Secret:   []byte(os.Getenv("INSTRUU_JWT_SECRET")),
+		Issuer:   os.Getenv("INSTRUU_JWT_ISSUER"),
+		Audience: os.Getenv("INSTRUU_JWT_AUDIENCE"),
+	}
+	if value := os.Getenv("INSTRUU_JWT_TTL"); value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl <= 0 {
+			return options, fmt.Errorf("invalid INSTRUU_JWT_TTL: %s", value)
+		}
+		options.Tokens.TTL = ttl
+	}
+
+	providers, err := oauthProviders()
 	if err != nil {
-		log.Fatalf("connecting to postgres database: %v\n", err)
+		return options, err
 	}
+	options.Providers = providers
 
-	s := server.New(sto)
+	return options, nil
+}
 
-	addr := os.Getenv("INSTRUU_ADDR")
-	fmt.Printf("Starting server on port %v\n", addr)
+// oauthProviders configures the providers listed in INSTRUU_OAUTH_PROVIDERS, github and
+// google only need a client ID and secret while any other provider needs an issuer or its
+// endpoints
+func oauthProviders() ([]*auth.Provider, error) {
+	list := os.Getenv("INSTRUU_OAUTH_PROVIDERS")
+	if list == "" {
+		return nil, nil
+	}
+	redirectBase := strings.TrimSuffix(os.Getenv("INSTRUU_OAUTH_REDIRECT_BASE"), "/")
+	if redirectBase == "" {
+		return nil, fmt.Errorf("INSTRUU_OAUTH_REDIRECT_BASE has to be set to use OAuth providers")
+	}
 
-	if err := s.Run(addr); err != nil {
-		log.Fatalf("running server: %v\n", err)
+	var providers []*auth.Provider
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		env := func(key string) string {
+			return os.Getenv("INSTRUU_OAUTH_" + strings.ToUpper(name) + "_" + key)
+		}
+		clientID, clientSecret := env("CLIENT_ID"), env("CLIENT_SECRET")
+		redirectURL := redirectBase + "/auth/oauth/" + name + "/callback"
+
+		var options auth.ProviderOptions
+		switch name {
+		case "github":
+			options = auth.GitHub(clientID, clientSecret, redirectURL)
+		case "google":
+			options = auth.Google(clientID, clientSecret, redirectURL)
+		default:
+			options = auth.ProviderOptions{
+				Name:         name,
+				ClientID:     clientID,
+				ClientSecret: clientSecret,
+				RedirectURL:  redirectURL,
+				Issuer:       env("ISSUER"),
+				AuthURL:      env("AUTH_URL"),
+				TokenURL:     env("TOKEN_URL"),
+				UserInfoURL:  env("USERINFO_URL"),
+			}
+		}
+		if scopes := env("SCOPES"); scopes != "" {
+			options.Scopes = strings.Fields(scopes)
+		}
+
+		provider, err := auth.NewProvider(options)
+		if err != nil {
+			return nil, fmt.Errorf("configuring OAuth provider %s: %v", name, err)
+		}
+		providers = append(providers, provider)
 	}
+	return providers, nil
+}
 
-	sto.Close()
+// mailBackend reads which backend sends mail from the environment, only the recipient and
+// subject of mail is logged unless INSTRUU_MAIL is set
+func mailBackend() (mail.Mailer, error) {
+	from := envOr("INSTRUU_MAIL_FROM", "instruu@localhost")
+
+	switch backend := envOr("INSTRUU_MAIL", "log"); backend {
+	case "smtp":
+		portString := envOr("INSTRUU_SMTP_PORT", "587")
+		port, err := strconv.Atoi(portString)
+		if err != nil {
+			return nil, fmt.Errorf("invalid INSTRUU_SMTP_PORT: %s", portString)
+		}
+
+		return mail.NewSMTP(mail.SMTPOptions{
+			Host:     os.Getenv("INSTRUU_SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("INSTRUU_SMTP_USERNAME"),
+			Password: os.Getenv("INSTRUU_SMTP_PASSWORD"),
+			From:     from,
+		}), nil
+	case "file":
+		return mail.NewFile(envOr("INSTRUU_MAIL_DIR", "mail"), from)
+	case "log":
+		return mail.Log{}, nil
+	default:
+		return nil, fmt.Errorf("unknown mail backend: %s", backend)
+	}
+}
+
+// envOr returns the environment variable key, or fallback when it isn't set
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
 }
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store/postgres"
+)
+
+// migrate runs the migrate subcommand against the postgres store
+func migrate(command string) error {
+	options, err := postgresOptions()
+	if err != nil {
+		return err
+	}
+
+	db, err := postgres.Open(options)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := postgres.Migrator(db)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "up":
+		done, err := migrator.Up()
+		for _, m := range done {
+			fmt.Printf("applied %d %s\n", m.Version, m.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Println("database is up to date")
+		}
+		return err
+	case "down":
+		m, ok, err := migrator.Down()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println("no migrations to roll back")
+			return nil
+		}
+		fmt.Printf("rolled back %d %s\n", m.Version, m.Name)
+		return nil
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, status := range statuses {
+			applied := "pending"
+			if status.Applied {
+				applied = status.AppliedAt.Format(time.RFC3339)
+			}
+			if status.Modified {
+				applied += " (modified)"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, applied)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", command)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// role runs the role subcommand, granting or revoking a role for a user. It's how the
+// first admin is made, after that admins can manage roles through the API
+func role(sto store.Service, command, role, rawID string) error {
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid user id: %s", rawID)
+	}
+	if err := store.ValidateRole(role); err != nil {
+		return err
+	}
+
+	switch command {
+	case "grant":
+		err = sto.SetRole(id, role, true)
+	case "revoke":
+		err = sto.SetRole(id, role, false)
+	default:
+		return fmt.Errorf("unknown role command %q, expected grant or revoke", command)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("user %d updated\n", id)
+	return nil
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// APIKeyPrefix starts every API key, it tells keys apart from JWTs and lets secret scanners
+// spot leaked keys
+const APIKeyPrefix = "instruu_"
+
+// apiKeyPrefixLength is how much of a key is kept in the clear so users can tell their
+// keys apart
+const apiKeyPrefixLength = len(APIKeyPrefix) + 6
+
+// NewAPIKey returns a random API key to hand to a user once, the part of it that's safe to
+// show again and the hash to store in its place
+func NewAPIKey() (key, prefix, hash string, err error) {
+	token, _, err := NewToken()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	key = APIKeyPrefix + token
+	return key, key[:apiKeyPrefixLength], HashToken(key), nil
+}
+
+// isAPIKey reports whether a bearer token is an API key rather than a JWT
+func isAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// HasScope reports whether a request authenticated as identity may use scope, only API
+// keys are limited to scopes
+func (i Identity) HasScope(scope string) bool {
+	if i.APIKey == 0 {
+		return true
+	}
+	for _, granted := range i.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// methodScope is the scope an API key needs to make a request with method
+func methodScope(method string) string {
+	switch method {
+	case "GET", "HEAD", "OPTIONS":
+		return store.ScopeRead
+	default:
+		return store.ScopeWrite
+	}
+}
+
+// checkScope responds with a 403 when identity can't make the request, it's a no-op for
+// everything but API keys
+func checkScope(w http.ResponseWriter, r *http.Request, identity Identity) bool {
+	if scope := methodScope(r.Method); !identity.HasScope(scope) {
+		forbidden(w, "This API key doesn't have the "+scope+" scope")
+		return false
+	}
+	return true
+}
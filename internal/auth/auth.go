@@ -1,10 +1,14 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 
@@ -13,85 +17,224 @@ import (
 	"github.com/natethinks/instruu-api/internal/store"
 )
 
-// NewJWT accepts a user and creates a JWT representing that user
-func NewJWT(user store.User) (string, error) {
-	fmt.Println(user)
+// TokenOptions configures a TokenService
+type TokenOptions struct {
+	// Secret signs and verifies tokens, it can't be empty
+	Secret []byte
+	// Issuer defaults to instruu, Audience is only set and checked when it isn't empty
+	Issuer   string
+	Audience string
+	// TTL is how long a token stays valid, it defaults to DefaultTokenTTL
+	TTL time.Duration
+	// Revoked reports whether the session of a token was revoked, a store.ErrNoResults
+	// error means the user is gone. Tokens are never revoked when it's nil
+	Revoked func(identity Identity) (bool, error)
+	// APIKey looks up who an API key belongs to and records that it was used, a
+	// store.ErrNoResults error means the key is unknown or expired. API keys are turned
+	// away when it's nil
+	APIKey func(key string) (Identity, error)
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"id":       user.ID,
-		"username": user.Username,
-	})
+// DefaultTokenTTL is how long tokens stay valid when TokenOptions doesn't say, it's short
+// since sessions renew them with refresh tokens
+const DefaultTokenTTL = 15 * time.Minute
 
-	tokenString, err := token.SignedString([]byte("my_not_secret_key"))
+// CookieName is the cookie CheckJWT reads the token from
+const CookieName = "auth"
 
-	return tokenString, err
+// TokenService issues the JWTs users authenticate with and checks them on the way in
+type TokenService struct {
+	options TokenOptions
 }
 
-// CheckJWT retrieves user info from the JWT and stores it in the header for an endpoint to use, but allows access without a JWT
-// this is mostly for get requests since post requests will almost always require a user to be logged in as something
-func CheckJWT(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// check to see if the web token is valid, should be stored in a cookie since that's what i'm going to use
-		// also check to make sure the request is coming from a valid resource, XSRF CSRF checks
+// NewTokenService fills in the defaults of options
+func NewTokenService(options TokenOptions) *TokenService {
+	if options.Issuer == "" {
+		options.Issuer = "instruu"
+	}
+	if options.TTL <= 0 {
+		options.TTL = DefaultTokenTTL
+	}
+	return &TokenService{options: options}
+}
 
-		fmt.Println(r.Cookies())
+// Identity is who a request was authenticated as
+type Identity struct {
+	ID       int64
+	Username string
+	// Session is the session the token was issued for, 0 when it wasn't issued for one
+	Session  int64
+	IssuedAt time.Time
+	// APIKey is the key the request was made with and Scopes what it's limited to, 0 when
+	// the request was made with a JWT
+	APIKey int64
+	Scopes []string
+}
 
-		authCookie, _ := r.Cookie("auth")
-		tokenString := authCookie.String()
-		fmt.Println(tokenString)
+type contextKey int
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Don't forget to validate the alg is what you expect:
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
-			}
+const identityKey contextKey = 0
 
-			// hmacSampleSecret is a []byte containing your secret, e.g. []byte("my_secret_key")
-			return []byte("my_not_secret_key"), nil
-		})
+// WithIdentity returns a copy of ctx carrying identity
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey, identity)
+}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			fmt.Println(claims["foo"], claims["nbf"])
-		} else {
-			fmt.Println(err)
-		}
+// IdentityFrom returns the identity CheckJWT put in ctx, if there is one
+func IdentityFrom(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey).(Identity)
+	return identity, ok
+}
 
-		h.ServeHTTP(w, r)
-	})
+// Issue signs a token for identity and returns it along with when it expires
+func (t *TokenService) Issue(identity Identity, now time.Time) (string, time.Time, error) {
+	expires := now.Add(t.options.TTL)
+	claims := jwt.MapClaims{
+		"sub":      strconv.FormatInt(identity.ID, 10),
+		"username": identity.Username,
+		"iss":      t.options.Issuer,
+		"iat":      now.Unix(),
+		"exp":      expires.Unix(),
+	}
+	if identity.Session != 0 {
+		claims["sid"] = identity.Session
+	}
+	if t.options.Audience != "" {
+		claims["aud"] = t.options.Audience
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(t.options.Secret)
+	return token, expires, err
 }
 
-// SecureCheckJWT blocks access to an endpoint unless a user is logged in with a valid JWT
-func SecureCheckJWT(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// check to see if the web token is valid, should be stored in a cookie since that's what i'm going to use
-		// also check to make sure the request is coming from a valid resource, XSRF CSRF checks
+// Parse checks the signature, expiry, issuer and audience of a token and returns who it
+// was issued to
+func (t *TokenService) Parse(tokenString string) (Identity, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+		return t.options.Secret, nil
+	})
+	if err != nil || !token.Valid {
+		return Identity{}, ErrInvalidToken
+	}
 
-		fmt.Println(r.Cookies())
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !claims.VerifyIssuer(t.options.Issuer, true) {
+		return Identity{}, ErrInvalidToken
+	}
+	if t.options.Audience != "" && !claims.VerifyAudience(t.options.Audience, true) {
+		return Identity{}, ErrInvalidToken
+	}
 
-		jwt, err := r.Cookie("auth")
-		if err != nil {
-			// no cookie found by that name
-			w.WriteHeader(http.StatusUnauthorized)
-			respond.JSON(w, errors.New("Missing or invalid token"))
+	subject, _ := claims["sub"].(string)
+	id, err := strconv.ParseInt(subject, 10, 64)
+	// numbers come back from the JSON as float64
+	issuedAt, ok := claims["iat"].(float64)
+	if err != nil || id <= 0 || !ok {
+		return Identity{}, ErrInvalidToken
+	}
+
+	username, _ := claims["username"].(string)
+	session, _ := claims["sid"].(float64)
+	return Identity{
+		ID:       id,
+		Username: username,
+		Session:  int64(session),
+		IssuedAt: time.Unix(int64(issuedAt), 0),
+	}, nil
+}
+
+// authenticate finds the token of a request, in the Authorization header or the auth
+// cookie, and checks it. It returns false without an error when there's no token
+func (t *TokenService) authenticate(r *http.Request) (Identity, bool, error) {
+	var tokenString string
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		tokenString = strings.TrimPrefix(header, "Bearer ")
+	} else if cookie, err := r.Cookie(CookieName); err == nil {
+		tokenString = cookie.Value
+	} else {
+		return Identity{}, false, nil
+	}
+
+	if isAPIKey(tokenString) {
+		if t.options.APIKey == nil {
+			return Identity{}, false, ErrInvalidToken
 		}
-		fmt.Println(jwt)
+		identity, err := t.options.APIKey(tokenString)
+		if err == store.ErrNoResults {
+			return identity, false, ErrInvalidToken
+		}
+		return identity, err == nil, err
+	}
+
+	identity, err := t.Parse(tokenString)
+	if err != nil {
+		return identity, false, err
+	}
 
-		// if the validation fails
-		if 1 == 1 {
-			log.Println("invalid JWT")
+	if t.options.Revoked != nil {
+		revoked, err := t.options.Revoked(identity)
+		if err == store.ErrNoResults || (err == nil && revoked) {
+			return identity, false, ErrInvalidToken
+		} else if err != nil {
+			return identity, false, err
+		}
+	}
 
-			err := errors.New("Missing or invalid token")
+	return identity, true, nil
+}
 
-			w.WriteHeader(http.StatusUnauthorized)
-			respond.JSON(w, err)
+// CheckJWT puts the identity of a request with a valid token or API key into its context,
+// requests without a token are let through anonymously while ones with a bad token are
+// turned away. API keys without the scope for the request's method get a 403
+func (t *TokenService) CheckJWT(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, ok, err := t.authenticate(r)
+		if err != nil {
+			unauthorized(w, err)
 			return
-			// if the validation succeeds. continue with the serving of the endpoint that this wraps
-		} else {
-			h.ServeHTTP(w, r)
 		}
+		if ok {
+			if !checkScope(w, r, identity) {
+				return
+			}
+			r = r.WithContext(WithIdentity(r.Context(), identity))
+		}
+
+		h.ServeHTTP(w, r)
 	})
 }
 
+// SecureCheckJWT is CheckJWT for endpoints that need a user, requests without a valid
+// token are turned away
+func (t *TokenService) SecureCheckJWT(h http.Handler) http.Handler {
+	return t.CheckJWT(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := IdentityFrom(r.Context()); !ok {
+			unauthorized(w, errors.New("Missing or invalid token"))
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	}))
+}
+
+func unauthorized(w http.ResponseWriter, err error) {
+	if err != ErrInvalidToken {
+		log.Printf("checking token: %v\n", err)
+		err = errors.New("Missing or invalid token")
+	}
+
+	w.WriteHeader(http.StatusUnauthorized)
+	respond.JSON(w, err)
+}
+
+func forbidden(w http.ResponseWriter, message string) {
+	w.WriteHeader(http.StatusForbidden)
+	respond.JSON(w, errors.New(message))
+}
+
 // Generate PasswordHash accepts a plaintext password as a string of bytes and returns
 // a salted hash in a string to be stored in the DB
 func GeneratePasswordHash(pwd []byte) string {
@@ -1,6 +1,14 @@
 package auth
 
-import "testing"
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
 
 func TestGeneratePasswordHash(t *testing.T) {
 	password := "testing"
@@ -24,3 +32,187 @@ func TestVerifyPassword(t *testing.T) {
 	}
 	return
 }
+
+func TestVerificationToken(t *testing.T) {
+	secret := []byte("testing")
+	user := store.User{ID: 4, Email: "nate@example.com"}
+
+	token, err := NewVerificationToken(secret, user, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, email, err := ParseVerificationToken(secret, token)
+	if err != nil || id != user.ID || email != user.Email {
+		t.Errorf("ParseVerificationToken() = %d, %q, %v", id, email, err)
+	}
+
+	if _, _, err := ParseVerificationToken([]byte("other"), token); err != ErrInvalidToken {
+		t.Errorf("expected a token signed with another secret to be invalid, got %v", err)
+	}
+
+	expired, _ := NewVerificationToken(secret, user, time.Now().Add(-VerificationTTL-time.Minute))
+	if _, _, err := ParseVerificationToken(secret, expired); err != ErrInvalidToken {
+		t.Errorf("expected an expired token to be invalid, got %v", err)
+	}
+}
+
+func TestNewToken(t *testing.T) {
+	token, hash, err := NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if HashToken(token) != hash || hash == token {
+		t.Errorf("expected the hash of %q to be %q", token, hash)
+	}
+
+	other, _, _ := NewToken()
+	if other == token {
+		t.Error("expected tokens to be random")
+	}
+}
+
+func TestTokenService(t *testing.T) {
+	tokens := NewTokenService(TokenOptions{Secret: []byte("testing"), Audience: "web"})
+	user := Identity{ID: 4, Username: "nate", Session: 2}
+
+	token, expires, err := tokens.Issue(user, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expires.Before(time.Now().Add(DefaultTokenTTL - time.Minute)) {
+		t.Errorf("expected the token to last %v, it expires at %v", DefaultTokenTTL, expires)
+	}
+	identity, err := tokens.Parse(token)
+	if err != nil || identity.ID != user.ID || identity.Username != user.Username || identity.Session != user.Session {
+		t.Errorf("Parse() = %+v, %v", identity, err)
+	}
+
+	for name, other := range map[string]*TokenService{
+		"another secret":   NewTokenService(TokenOptions{Secret: []byte("other"), Audience: "web"}),
+		"another issuer":   NewTokenService(TokenOptions{Secret: []byte("testing"), Issuer: "other", Audience: "web"}),
+		"another audience": NewTokenService(TokenOptions{Secret: []byte("testing"), Audience: "mobile"}),
+	} {
+		if _, err := other.Parse(token); err != ErrInvalidToken {
+			t.Errorf("expected a token checked with %s to be invalid, got %v", name, err)
+		}
+	}
+
+	expired, _, _ := tokens.Issue(user, time.Now().Add(-DefaultTokenTTL-time.Minute))
+	if _, err := tokens.Parse(expired); err != ErrInvalidToken {
+		t.Errorf("expected an expired token to be invalid, got %v", err)
+	}
+
+	verification, _ := NewVerificationToken([]byte("testing"), store.User{ID: user.ID, Email: "nate@example.com"}, time.Now())
+	if _, err := tokens.Parse(verification); err != ErrInvalidToken {
+		t.Errorf("expected a verification token to be turned away, got %v", err)
+	}
+}
+
+func TestCheckJWT(t *testing.T) {
+	revoked := time.Now().Add(-time.Hour)
+	tokens := NewTokenService(TokenOptions{
+		Secret:  []byte("testing"),
+		Revoked: func(identity Identity) (bool, error) { return identity.IssuedAt.Before(revoked), nil },
+	})
+
+	var identity Identity
+	handler := tokens.CheckJWT(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, _ = IdentityFrom(r.Context())
+	}))
+	check := func(token string) int {
+		identity = Identity{}
+		req := httptest.NewRequest("GET", "/", nil)
+		if token != "" {
+			req.AddCookie(&http.Cookie{Name: CookieName, Value: token})
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := check(""); code != http.StatusOK || identity.ID != 0 {
+		t.Errorf("expected anonymous requests through without an identity, got %d %+v", code, identity)
+	}
+	if code := check("garbage"); code != http.StatusUnauthorized {
+		t.Errorf("expected a bad token to be turned away, got %d", code)
+	}
+
+	token, _, _ := tokens.Issue(Identity{ID: 4, Username: "nate"}, time.Now())
+	if code := check(token); code != http.StatusOK || identity.ID != 4 || identity.Username != "nate" {
+		t.Errorf("expected the identity in the context, got %d %+v", code, identity)
+	}
+
+	old, _, _ := tokens.Issue(Identity{ID: 4, Username: "nate"}, revoked.Add(-time.Minute))
+	if code := check(old); code != http.StatusUnauthorized {
+		t.Errorf("expected a token issued before the revocation to be turned away, got %d", code)
+	}
+}
+
+func TestRequire(t *testing.T) {
+	roles := map[int64][]string{1: {store.RoleAdmin}, 2: {store.RoleModerator}}
+	authz := NewAuthorizer(func(user int64) ([]string, error) {
+		if _, ok := roles[user]; !ok {
+			return nil, store.ErrNoResults
+		}
+		return roles[user], nil
+	})
+	handler := authz.Require(PermissionListUsers, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	check := func(user int64) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		if user != 0 {
+			req = req.WithContext(WithIdentity(req.Context(), Identity{ID: user}))
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for user, expected := range map[int64]int{0: http.StatusUnauthorized, 1: http.StatusOK, 2: http.StatusForbidden, 3: http.StatusForbidden} {
+		if code := check(user); code != expected {
+			t.Errorf("expected user %d to get %d, got %d", user, expected, code)
+		}
+	}
+
+	if !Can([]string{store.RoleModerator}, PermissionApproveResources) || Can([]string{"owner"}, PermissionApproveResources) {
+		t.Error("expected only moderators to approve resources")
+	}
+}
+
+func TestCheckAPIKey(t *testing.T) {
+	key, prefix, hash, err := NewAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(key, prefix) || !strings.HasPrefix(prefix, APIKeyPrefix) || hash != HashToken(key) {
+		t.Fatalf("NewAPIKey() = %q, %q, %q", key, prefix, hash)
+	}
+
+	tokens := NewTokenService(TokenOptions{
+		Secret: []byte("testing"),
+		APIKey: func(k string) (Identity, error) {
+			if k != key {
+				return Identity{}, store.ErrNoResults
+			}
+			return Identity{ID: 4, APIKey: 1, Scopes: []string{store.ScopeRead}}, nil
+		},
+	})
+	handler := tokens.CheckJWT(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	check := func(method, token string) int {
+		req := httptest.NewRequest(method, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := check("GET", key); code != http.StatusOK {
+		t.Errorf("expected the key to be let through, got %d", code)
+	}
+	if code := check("POST", key); code != http.StatusForbidden {
+		t.Errorf("expected a read only key to be forbidden from posting, got %d", code)
+	}
+	if code := check("GET", APIKeyPrefix+"other"); code != http.StatusUnauthorized {
+		t.Errorf("expected an unknown key to be turned away, got %d", code)
+	}
+}
@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// oauthTTL is how long users have to sign in at the provider
+const oauthTTL = 10 * time.Minute
+
+// oauthPurpose keeps the state cookie from being mistaken for any other token signed with
+// the same secret
+const oauthPurpose = "oauth"
+
+// oauthCookieName is the cookie that carries a sign in from Begin to Complete
+const oauthCookieName = "oauth"
+
+// ErrInvalidState is returned when a callback doesn't belong to a sign in this browser
+// started, or the sign in took too long
+var ErrInvalidState = errors.New("Invalid or expired sign in, try signing in again")
+
+// OAuth runs the authorization code flow with PKCE for a set of providers. What's needed to
+// finish a sign in is kept in a signed cookie, so nothing is stored until it's done
+type OAuth struct {
+	secret    []byte
+	providers map[string]*Provider
+}
+
+// NewOAuth signs the state cookie with secret
+func NewOAuth(secret []byte, providers ...*Provider) *OAuth {
+	o := &OAuth{secret: secret, providers: make(map[string]*Provider, len(providers))}
+	for _, p := range providers {
+		o.providers[p.Name()] = p
+	}
+	return o
+}
+
+// Provider returns the provider called name
+func (o *OAuth) Provider(name string) (*Provider, bool) {
+	p, ok := o.providers[name]
+	return p, ok
+}
+
+// NewPKCE returns a PKCE code verifier and its S256 challenge
+func NewPKCE() (verifier, challenge string, err error) {
+	verifier, _, err = NewToken()
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// Begin sends the user to sign in at the provider. link is the user the provider's account
+// is being linked to, 0 when the user is signing in
+func (o *OAuth) Begin(w http.ResponseWriter, r *http.Request, p *Provider, link int64) error {
+	state, _, err := NewToken()
+	if err != nil {
+		return err
+	}
+	nonce, _, err := NewToken()
+	if err != nil {
+		return err
+	}
+	verifier, challenge, err := NewPKCE()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	cookie, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"purpose":  oauthPurpose,
+		"provider": p.Name(),
+		"state":    state,
+		"nonce":    nonce,
+		"verifier": verifier,
+		"link":     link,
+		"exp":      now.Add(oauthTTL).Unix(),
+	}).SignedString(o.secret)
+	if err != nil {
+		return err
+	}
+
+	// Lax so the cookie comes back with the provider's redirect
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthCookieName,
+		Value:    cookie,
+		Path:     oauthCookiePath(p),
+		Expires:  now.Add(oauthTTL),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, p.AuthCodeURL(state, challenge, nonce), http.StatusFound)
+	return nil
+}
+
+// oauthCookiePath keeps the cookie to the routes of one provider
+func oauthCookiePath(p *Provider) string {
+	return "/auth/oauth/" + p.Name()
+}
+
+// Complete finishes a sign in on the provider's redirect back, it checks the callback
+// belongs to the sign in Begin started and returns the user's profile at the provider along
+// with the user Begin was linking. Errors the provider reports are an *OAuthError
+func (o *OAuth) Complete(w http.ResponseWriter, r *http.Request, p *Provider) (Profile, int64, error) {
+	// whatever happens the sign in is over
+	http.SetCookie(w, &http.Cookie{Name: oauthCookieName, Path: oauthCookiePath(p), MaxAge: -1})
+
+	cookie, err := r.Cookie(oauthCookieName)
+	if err != nil {
+		return Profile{}, 0, ErrInvalidState
+	}
+	token, err := jwt.Parse(cookie.Value, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+		return o.secret, nil
+	})
+	if err != nil || !token.Valid {
+		return Profile{}, 0, ErrInvalidState
+	}
+
+	claims, _ := token.Claims.(jwt.MapClaims)
+	state, _ := claims["state"].(string)
+	nonce, _ := claims["nonce"].(string)
+	verifier, _ := claims["verifier"].(string)
+	// numbers come back from the JSON as float64
+	link, _ := claims["link"].(float64)
+	query := r.URL.Query()
+	if claims["purpose"] != oauthPurpose || claims["provider"] != p.Name() || state == "" ||
+		subtle.ConstantTimeCompare([]byte(state), []byte(query.Get("state"))) != 1 {
+		return Profile{}, 0, ErrInvalidState
+	}
+
+	if code := query.Get("error"); code != "" {
+		return Profile{}, 0, &OAuthError{Code: code, Description: query.Get("error_description")}
+	}
+	if query.Get("code") == "" {
+		return Profile{}, 0, &OAuthError{Code: "invalid_request", Description: "the provider didn't send a code"}
+	}
+
+	profile, err := p.Exchange(query.Get("code"), verifier, nonce)
+	return profile, int64(link), err
+}
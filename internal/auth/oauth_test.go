@@ -0,0 +1,259 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// fakeProvider is an OpenID Connect provider that signs in whoever is set as its user
+type fakeProvider struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+	kid string
+
+	mu sync.Mutex
+	// sign ins are keyed by the code handed out for them
+	signIns map[string]url.Values
+	codes   int
+	// keyFetches counts requests for the keys, broken makes them fail to decode
+	keyFetches int
+	broken     bool
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeProvider{key: key, kid: "1", signIns: make(map[string]url.Values)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 f.URL,
+			"authorization_endpoint": f.URL + "/authorize",
+			"token_endpoint":         f.URL + "/token",
+			"jwks_uri":               f.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/authorize", f.authorize)
+	mux.HandleFunc("/token", f.token)
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.keyFetches++
+		n := base64.RawURLEncoding.EncodeToString(f.key.N.Bytes())
+		if f.broken {
+			n = "not base64!"
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": f.kid,
+			"n":   n,
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(f.key.E)).Bytes()),
+		}}})
+	})
+	f.Server = httptest.NewServer(mux)
+	return f
+}
+
+// authorize signs the user straight in and redirects back with a code
+func (f *fakeProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.codes++
+	code := strconv.Itoa(f.codes)
+	f.signIns[code] = r.URL.Query()
+	f.mu.Unlock()
+
+	redirect := r.URL.Query().Get("redirect_uri") + "?" + url.Values{
+		"code":  {code},
+		"state": {r.URL.Query().Get("state")},
+	}.Encode()
+	http.Redirect(w, r, redirect, http.StatusFound)
+}
+
+// token checks the PKCE verifier against the challenge of the sign in and returns an ID
+// token for it, each code works once
+func (f *fakeProvider) token(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	signIn, ok := f.signIns[r.FormValue("code")]
+	delete(f.signIns, r.FormValue("code"))
+	sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	if !ok || r.FormValue("client_secret") != "secret" ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != signIn.Get("code_challenge") {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            f.URL,
+		"aud":            signIn.Get("client_id"),
+		"sub":            "248289761001",
+		"email":          "nate@example.com",
+		"email_verified": true,
+		"nonce":          signIn.Get("nonce"),
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header["kid"] = f.kid
+	idToken, err := token.SignedString(f.key)
+	if err != nil {
+		panic(err)
+	}
+	json.NewEncoder(w).Encode(map[string]string{"access_token": "access", "token_type": "Bearer", "id_token": idToken})
+}
+
+// rotate switches to a new signing key
+func (f *fakeProvider) rotate(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.mu.Lock()
+	f.key, f.kid = key, f.kid+"1"
+	f.mu.Unlock()
+}
+
+func TestOAuth(t *testing.T) {
+	fake := newFakeProvider(t)
+	defer fake.Close()
+
+	provider, err := NewProvider(ProviderOptions{
+		Name:         "fake",
+		ClientID:     "instruu",
+		ClientSecret: "secret",
+		RedirectURL:  "https://api.example.com/auth/oauth/fake/callback",
+		Issuer:       fake.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oauth := NewOAuth([]byte("testing"), provider)
+
+	noRedirects := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	// signIn begins a sign in, has the fake provider approve it and returns the callback
+	// along with the state cookie
+	signIn := func(link int64) (*url.URL, *http.Cookie) {
+		w := httptest.NewRecorder()
+		if err := oauth.Begin(w, httptest.NewRequest("GET", "/auth/oauth/fake", nil), provider, link); err != nil {
+			t.Fatal(err)
+		}
+		res, err := noRedirects.Get(w.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+
+		callback, err := url.Parse(res.Header.Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return callback, w.Result().Cookies()[0]
+	}
+	complete := func(callback *url.URL, cookie *http.Cookie) (Profile, int64, error) {
+		req := httptest.NewRequest("GET", callback.String(), nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		return oauth.Complete(httptest.NewRecorder(), req, provider)
+	}
+
+	callback, cookie := signIn(7)
+	profile, link, err := complete(callback, cookie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Subject != "248289761001" || profile.Email != "nate@example.com" || !profile.EmailVerified || link != 7 {
+		t.Errorf("Complete() = %+v, %d", profile, link)
+	}
+	if _, _, err := complete(callback, cookie); err == nil {
+		t.Error("expected a code to only work once")
+	}
+
+	// the state has to match the cookie of the browser that started the sign in
+	callback, cookie = signIn(0)
+	if _, _, err := complete(callback, nil); err != ErrInvalidState {
+		t.Errorf("expected a callback without the cookie to be turned away, got %v", err)
+	}
+	_, other := signIn(0)
+	if _, _, err := complete(callback, other); err != ErrInvalidState {
+		t.Errorf("expected a callback with another sign in's cookie to be turned away, got %v", err)
+	}
+
+	denied := *callback
+	denied.RawQuery = url.Values{"state": {callback.Query().Get("state")}, "error": {"access_denied"}}.Encode()
+	if _, _, err := complete(&denied, cookie); err == nil || err.(*OAuthError).Code != "access_denied" {
+		t.Errorf("expected the provider's error, got %v", err)
+	}
+
+	// the code can't be exchanged without the verifier, even by someone holding the code
+	callback, _ = signIn(0)
+	if _, err := provider.Exchange(callback.Query().Get("code"), "stolen", ""); err == nil {
+		t.Error("expected the exchange to need the PKCE verifier")
+	}
+
+	// keys the provider rotated to are fetched again
+	fake.rotate(t)
+	callback, cookie = signIn(0)
+	if _, _, err := complete(callback, cookie); err != nil {
+		t.Errorf("expected a token signed with a new key to be verified, got %v", err)
+	}
+
+	// a kid that's still unknown isn't fetched for again right away
+	fake.mu.Lock()
+	fetches := fake.keyFetches
+	fake.mu.Unlock()
+	for i := 0; i < 3; i++ {
+		if _, err := provider.key("missing"); err == nil {
+			t.Error("expected a missing key to be unknown")
+		}
+	}
+	fake.mu.Lock()
+	if fake.keyFetches != fetches+1 {
+		t.Errorf("expected one fetch for a missing key, got %d", fake.keyFetches-fetches)
+	}
+	// keys that don't decode leave the known ones alone
+	fake.broken = true
+	fake.mu.Unlock()
+	if _, err := provider.key("other"); err == nil {
+		t.Error("expected keys that don't decode to fail")
+	}
+	if _, err := provider.key(fake.kid); err != nil {
+		t.Errorf("expected the known key to survive a broken fetch, got %v", err)
+	}
+}
+
+func TestNewPKCE(t *testing.T) {
+	verifier, challenge, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the example from RFC 7636 appendix B
+	sum := sha256.Sum256([]byte("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Fatal("unexpected S256 encoding")
+	}
+	sum = sha256.Sum256([]byte(verifier))
+	if len(verifier) < 43 || challenge != base64.RawURLEncoding.EncodeToString(sum[:]) {
+		t.Errorf("NewPKCE() = %q, %q", verifier, challenge)
+	}
+}
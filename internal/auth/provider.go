@@ -0,0 +1,405 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// ProviderOptions configures an OAuth2 provider users can sign in with
+type ProviderOptions struct {
+	// Name identifies the provider in URLs and in the accounts linked to users
+	Name         string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is where the provider sends users back to, the provider's callback route
+	RedirectURL string
+	// Issuer makes the provider an OpenID Connect one, the endpoints below that aren't set
+	// are discovered from it and users are read from the ID token instead of UserInfoURL
+	Issuer      string
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+	JWKSURL     string
+	// Scopes default to openid, email and profile for OpenID Connect providers
+	Scopes []string
+	// Profile reads a user from the claims of the ID token or the userinfo response, it
+	// defaults to the standard OpenID Connect claims
+	Profile func(claims map[string]interface{}) Profile
+}
+
+// Profile is who a user is at a provider
+type Profile struct {
+	// Subject identifies the user at the provider, it never changes
+	Subject       string
+	Email         string
+	EmailVerified bool
+	// Username is what the user goes by at the provider, it's only a suggestion for the
+	// username of a new user
+	Username string
+}
+
+// OAuthError is an error the provider reported, like the user turning down the sign in
+type OAuthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *OAuthError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("%s: %s", e.Code, e.Description)
+	}
+	return e.Code
+}
+
+// GitHub configures GitHub as a provider. GitHub isn't an OpenID Connect provider, so users
+// are read from its API and their email is never taken as verified
+func GitHub(clientID, clientSecret, redirectURL string) ProviderOptions {
+	return ProviderOptions{
+		Name:         "github",
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		AuthURL:      "https://github.com/login/oauth/authorize",
+		TokenURL:     "https://github.com/login/oauth/access_token",
+		UserInfoURL:  "https://api.github.com/user",
+		Scopes:       []string{"read:user", "user:email"},
+		Profile:      githubProfile,
+	}
+}
+
+// githubProfile reads a user from GitHub's /user response
+func githubProfile(claims map[string]interface{}) Profile {
+	// numbers come back from the JSON as float64
+	id, _ := claims["id"].(float64)
+	login, _ := claims["login"].(string)
+	email, _ := claims["email"].(string)
+	profile := Profile{Email: email, Username: login}
+	if id > 0 {
+		profile.Subject = strconv.FormatInt(int64(id), 10)
+	}
+	return profile
+}
+
+// Google configures Google as a provider, its endpoints are discovered
+func Google(clientID, clientSecret, redirectURL string) ProviderOptions {
+	return ProviderOptions{
+		Name:         "google",
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Issuer:       "https://accounts.google.com",
+	}
+}
+
+// oidcProfile reads a user from the standard OpenID Connect claims
+func oidcProfile(claims map[string]interface{}) Profile {
+	var profile Profile
+	profile.Subject, _ = claims["sub"].(string)
+	profile.Email, _ = claims["email"].(string)
+	profile.EmailVerified, _ = claims["email_verified"].(bool)
+	profile.Username, _ = claims["preferred_username"].(string)
+	return profile
+}
+
+// Provider signs users in with an OAuth2 or OpenID Connect provider
+type Provider struct {
+	options ProviderOptions
+	client  *http.Client
+
+	mu sync.Mutex
+	// keys verify ID tokens, they're fetched from JWKSURL when a token is signed with a
+	// key that isn't known yet
+	keys map[string]*rsa.PublicKey
+	// unknown holds when kids that weren't among the keys were last looked for
+	unknown map[string]time.Time
+}
+
+// unknownKeyRetry is how long a kid that wasn't found has to wait before the keys are
+// fetched for it again
+const unknownKeyRetry = time.Minute
+
+// NewProvider fills in the defaults of options, discovering the endpoints of OpenID Connect
+// providers
+func NewProvider(options ProviderOptions) (*Provider, error) {
+	p := &Provider{options: options, client: &http.Client{Timeout: 10 * time.Second}}
+	if options.Name == "" || options.ClientID == "" {
+		return nil, errors.New("providers need a name and a client ID")
+	}
+
+	if options.Issuer != "" {
+		if options.AuthURL == "" || options.TokenURL == "" || options.JWKSURL == "" {
+			if err := p.discover(); err != nil {
+				return nil, fmt.Errorf("discovering %s: %v", options.Name, err)
+			}
+		}
+		if len(p.options.Scopes) == 0 {
+			p.options.Scopes = []string{"openid", "email", "profile"}
+		}
+	} else if options.UserInfoURL == "" {
+		return nil, errors.New("providers need an issuer or a userinfo URL")
+	}
+	if p.options.Profile == nil {
+		p.options.Profile = oidcProfile
+	}
+	if p.options.AuthURL == "" || p.options.TokenURL == "" {
+		return nil, errors.New("providers need an auth and a token URL")
+	}
+
+	return p, nil
+}
+
+// Name identifies the provider
+func (p *Provider) Name() string {
+	return p.options.Name
+}
+
+// discover fills in the endpoints that aren't set from the issuer's discovery document
+func (p *Provider) discover() error {
+	var document struct {
+		Issuer      string `json:"issuer"`
+		AuthURL     string `json:"authorization_endpoint"`
+		TokenURL    string `json:"token_endpoint"`
+		UserInfoURL string `json:"userinfo_endpoint"`
+		JWKSURL     string `json:"jwks_uri"`
+	}
+	if err := p.getJSON(strings.TrimSuffix(p.options.Issuer, "/")+"/.well-known/openid-configuration", "", &document); err != nil {
+		return err
+	}
+	if document.Issuer != p.options.Issuer {
+		return fmt.Errorf("the discovery document is for issuer %q", document.Issuer)
+	}
+
+	for _, endpoint := range []struct {
+		url        *string
+		discovered string
+	}{
+		{&p.options.AuthURL, document.AuthURL},
+		{&p.options.TokenURL, document.TokenURL},
+		{&p.options.UserInfoURL, document.UserInfoURL},
+		{&p.options.JWKSURL, document.JWKSURL},
+	} {
+		if *endpoint.url == "" {
+			*endpoint.url = endpoint.discovered
+		}
+	}
+	return nil
+}
+
+// AuthCodeURL is where users are sent to sign in at the provider
+func (p *Provider) AuthCodeURL(state, challenge, nonce string) string {
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.options.ClientID},
+		"redirect_uri":          {p.options.RedirectURL},
+		"scope":                 {strings.Join(p.options.Scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+	if p.options.Issuer != "" {
+		query.Set("nonce", nonce)
+	}
+
+	separator := "?"
+	if strings.Contains(p.options.AuthURL, "?") {
+		separator = "&"
+	}
+	return p.options.AuthURL + separator + query.Encode()
+}
+
+// Exchange trades the code the provider redirected back with for the profile of the user,
+// verifier proves the exchange comes from whoever started the sign in
+func (p *Provider) Exchange(code, verifier, nonce string) (Profile, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.options.RedirectURL},
+		"client_id":     {p.options.ClientID},
+		"client_secret": {p.options.ClientSecret},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequest("POST", p.options.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Profile{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// GitHub answers with a form unless asked for JSON
+	req.Header.Set("Accept", "application/json")
+
+	var token struct {
+		OAuthError
+		AccessToken string `json:"access_token"`
+		IDToken     string `json:"id_token"`
+	}
+	if err := p.do(req, &token); err != nil {
+		return Profile{}, err
+	}
+	if token.Code != "" {
+		return Profile{}, &token.OAuthError
+	}
+
+	var claims map[string]interface{}
+	if p.options.Issuer != "" {
+		if claims, err = p.verifyIDToken(token.IDToken, nonce); err != nil {
+			return Profile{}, err
+		}
+	} else if err := p.getJSON(p.options.UserInfoURL, token.AccessToken, &claims); err != nil {
+		return Profile{}, err
+	}
+
+	profile := p.options.Profile(claims)
+	if profile.Subject == "" {
+		return profile, errors.New("the provider didn't say who the user is")
+	}
+	return profile, nil
+}
+
+// verifyIDToken checks the signature, issuer, audience, expiry and nonce of an ID token
+// and returns its claims
+func (p *Provider) verifyIDToken(idToken, nonce string) (map[string]interface{}, error) {
+	token, err := jwt.Parse(idToken, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return p.key(kid)
+	})
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("invalid ID token: %v", err)
+	}
+
+	claims, _ := token.Claims.(jwt.MapClaims)
+	if !claims.VerifyIssuer(p.options.Issuer, true) || !hasAudience(claims, p.options.ClientID) {
+		return nil, errors.New("the ID token was issued to someone else")
+	}
+	if claims["nonce"] != nonce {
+		return nil, errors.New("the ID token is for another sign in")
+	}
+	return claims, nil
+}
+
+// hasAudience checks aud, which can be a single string or a list of them
+func hasAudience(claims jwt.MapClaims, audience string) bool {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// key returns the public key kid, fetching the provider's keys again when it isn't known
+// since providers rotate their keys. The keys are only replaced once the new ones all decode
+func (p *Provider) key(kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	now := time.Now()
+	if lookedAt, ok := p.unknown[kid]; ok && now.Sub(lookedAt) < unknownKeyRetry {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	for unknown, lookedAt := range p.unknown {
+		if now.Sub(lookedAt) >= unknownKeyRetry {
+			delete(p.unknown, unknown)
+		}
+	}
+	if p.unknown == nil {
+		p.unknown = make(map[string]time.Time)
+	}
+	// a failed fetch counts as a look too, so a provider that's down isn't asked on every call
+	p.unknown[kid] = now
+
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.getJSON(p.options.JWKSURL, "", &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("decoding key %s: %v", jwk.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, fmt.Errorf("decoding key %s: %v", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	p.keys = keys
+
+	key, ok := p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	delete(p.unknown, kid)
+	return key, nil
+}
+
+// getJSON decodes the JSON at endpoint into v, authenticating with accessToken when it's set
+func (p *Provider) getJSON(endpoint, accessToken string, v interface{}) error {
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	return p.do(req, v)
+}
+
+// do sends req and decodes the JSON response into v, error responses that aren't an
+// OAuthError are returned with their status
+func (p *Provider) do(req *http.Request, v interface{}) error {
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if res.StatusCode >= 300 {
+		var oauthErr OAuthError
+		if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Code != "" {
+			return &oauthErr
+		}
+		return fmt.Errorf("%s %s: %s", req.Method, req.URL.Path, res.Status)
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/natethinks/instruu-api/internal/respond"
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Permission allows an action on a kind of resource, named resource:action
+type Permission string
+
+// Permissions that routes can require, anything not listed here every signed in user can do
+const (
+	PermissionApproveResources Permission = "resource:approve"
+	// PermissionEditResources edits and reverts resources someone else submitted
+	PermissionEditResources   Permission = "resource:edit"
+	PermissionDeleteResources Permission = "resource:delete"
+	// PermissionEditTags changes the tags of resources someone else submitted
+	PermissionEditTags    Permission = "tag:edit"
+	PermissionMergeTags   Permission = "tag:merge"
+	PermissionListUsers   Permission = "user:list"
+	PermissionDeleteUsers Permission = "user:delete"
+	PermissionGrantRoles  Permission = "role:grant"
+)
+
+// rolePermissions is the permission matrix, a user has every permission of every role
+// they were granted
+var rolePermissions = map[string][]Permission{
+	store.RoleModerator: {
+		PermissionApproveResources,
+		PermissionEditResources,
+		PermissionDeleteResources,
+		PermissionEditTags,
+		PermissionMergeTags,
+	},
+	store.RoleAdmin: {
+		PermissionApproveResources,
+		PermissionEditResources,
+		PermissionDeleteResources,
+		PermissionEditTags,
+		PermissionMergeTags,
+		PermissionListUsers,
+		PermissionDeleteUsers,
+		PermissionGrantRoles,
+	},
+}
+
+// Can reports whether any of roles has permission, unknown roles have none
+func Can(roles []string, permission Permission) bool {
+	for _, role := range roles {
+		for _, granted := range rolePermissions[role] {
+			if granted == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Authorizer enforces permissions for the identity CheckJWT put in the request context
+type Authorizer struct {
+	roles func(user int64) ([]string, error)
+}
+
+// NewAuthorizer looks up the roles of users with roles on every request, so granting or
+// revoking a role takes effect right away instead of when the user's token expires
+func NewAuthorizer(roles func(user int64) ([]string, error)) *Authorizer {
+	return &Authorizer{roles: roles}
+}
+
+// Allowed reports whether the acting user of r has permission, API keys also need the
+// admin scope to use any permission
+func (a *Authorizer) Allowed(r *http.Request, permission Permission) (bool, error) {
+	identity, ok := IdentityFrom(r.Context())
+	if !ok || !identity.HasScope(store.ScopeAdmin) {
+		return false, nil
+	}
+
+	roles, err := a.roles(identity.ID)
+	if err != nil && err != store.ErrNoResults {
+		return false, err
+	}
+	return Can(roles, permission), nil
+}
+
+// Require only lets the request through to h when the acting user has permission. It
+// responds with a 401 when there's no acting user and a 403 when they lack permission
+func (a *Authorizer) Require(permission Permission, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := IdentityFrom(r.Context())
+		if !ok {
+			unauthorized(w, errors.New("Missing or invalid token"))
+			return
+		}
+
+		if !identity.HasScope(store.ScopeAdmin) {
+			forbidden(w, "This API key doesn't have the "+store.ScopeAdmin+" scope")
+			return
+		}
+
+		allowed, err := a.Allowed(r, permission)
+		if err != nil {
+			log.Printf("looking up roles: %v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			respond.JSON(w, errors.New(http.StatusText(http.StatusInternalServerError)))
+			return
+		}
+		if !allowed {
+			forbidden(w, "You don't have permission to do that")
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
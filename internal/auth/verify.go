@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// VerificationTTL is how long an email verification token stays valid
+const VerificationTTL = 48 * time.Hour
+
+// verificationPurpose keeps verification tokens from being mistaken for any other token
+// signed with the same secret
+const verificationPurpose = "verify-email"
+
+// ErrInvalidToken is returned for tokens that are malformed, tampered with or expired
+var ErrInvalidToken = errors.New("Invalid or expired token")
+
+// NewVerificationToken signs a token proving whoever holds it received mail sent to the
+// user's email, it stops working once the email changes
+func NewVerificationToken(secret []byte, user store.User, now time.Time) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"purpose": verificationPurpose,
+		"id":      user.ID,
+		"email":   user.Email,
+		"iat":     now.Unix(),
+		"exp":     now.Add(VerificationTTL).Unix(),
+	})
+
+	return token.SignedString(secret)
+}
+
+// ParseVerificationToken checks the signature and expiry of a verification token and
+// returns the ID and email of the user it was issued to
+func ParseVerificationToken(secret []byte, tokenString string) (id int64, email string, err error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+		return secret, nil
+	})
+	if err != nil || !token.Valid {
+		return 0, "", ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != verificationPurpose {
+		return 0, "", ErrInvalidToken
+	}
+
+	// numbers come back from the JSON as float64
+	rawID, ok := claims["id"].(float64)
+	email, _ = claims["email"].(string)
+	if !ok || rawID <= 0 || email == "" {
+		return 0, "", ErrInvalidToken
+	}
+
+	return int64(rawID), email, nil
+}
@@ -0,0 +1,39 @@
+package mail
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// File writes each message to its own .eml file in a directory, so mail can be read during
+// development without a mail server
+type File struct {
+	dir  string
+	from string
+	// sent makes names unique when several messages go out in the same nanosecond
+	sent int64
+}
+
+// NewFile returns a File backend writing to dir, creating it when it doesn't exist
+func NewFile(dir, from string) (*File, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &File{dir: dir, from: from}, nil
+}
+
+// Send writes the message to a new file
+func (f *File) Send(msg Message) error {
+	now := time.Now()
+	data, err := encode(f.from, msg, now)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%d.eml", now.UnixNano(), atomic.AddInt64(&f.sent, 1))
+	return ioutil.WriteFile(filepath.Join(f.dir, name), data, 0600)
+}
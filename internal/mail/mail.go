@@ -0,0 +1,121 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is one email to one recipient, HTML is optional and sent as an alternative to Text
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer sends mail, whether it's delivered, written to disk or queued depends on the backend
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// errHeaderInjection is returned for messages that would smuggle extra headers into the mail
+var errHeaderInjection = errors.New("mail headers cannot contain line breaks")
+
+// encode formats msg as an RFC 5322 message from from, as multipart/alternative when it
+// has an HTML body
+func encode(from string, msg Message, date time.Time) ([]byte, error) {
+	if strings.ContainsAny(from+msg.To+msg.Subject, "\r\n") {
+		return nil, errHeaderInjection
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if msg.HTML == "" {
+		buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := writeQuoted(&buf, msg.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", parts.Boundary())
+	// the last part is the preferred one, so HTML goes after its plain text fallback
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuoted(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeQuoted(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// Log logs mail instead of sending it, it's used when no other backend is configured. Only
+// the recipient and subject are logged since bodies carry verification and reset tokens,
+// use File to read whole messages in development
+type Log struct{}
+
+// Send logs the recipient and subject of the message
+func (Log) Send(msg Message) error {
+	log.Printf("mail to %s: %s (body not logged)\n", msg.To, msg.Subject)
+	return nil
+}
+
+// Capture keeps mail in memory so tests can read what was sent
+type Capture struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// Send keeps the message
+func (c *Capture) Send(msg Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.messages = append(c.messages, msg)
+	return nil
+}
+
+// Messages returns everything sent so far, oldest first
+func (c *Capture) Messages() []Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]Message(nil), c.messages...)
+}
@@ -0,0 +1,159 @@
+package mail
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+	"github.com/natethinks/instruu-api/internal/store/memory"
+)
+
+func TestEncode(t *testing.T) {
+	msg := Message{To: "nate@example.com", Subject: "Héllo", Text: "plain", HTML: "<p>rich</p>"}
+	data, err := encode("instruu@example.com", msg, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"To: nate@example.com\r\n", "Subject: =?utf-8?q?H=C3=A9llo?=\r\n",
+		"multipart/alternative", "text/plain", "text/html", "<p>rich</p>"} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("expected the message to contain %q:\n%s", expected, data)
+		}
+	}
+
+	msg.Subject = "Hi\r\nBcc: everyone@example.com"
+	if _, err := encode("instruu@example.com", msg, time.Now()); err != errHeaderInjection {
+		t.Errorf("expected a subject with a line break to be rejected, got %v", err)
+	}
+}
+
+func TestTemplateEscapesHTML(t *testing.T) {
+	msg, err := Verify.Render("nate@example.com", TokenData{Username: "<b>nate</b>", Token: "abc", Expires: "1h0m0s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.To != "nate@example.com" || msg.Subject == "" {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+	if !strings.Contains(msg.Text, "<b>nate</b>") || strings.Contains(msg.HTML, "<b>nate</b>") {
+		t.Errorf("expected only the HTML body to be escaped:\n%s\n%s", msg.Text, msg.HTML)
+	}
+	if lines := strings.Split(strings.TrimSpace(msg.Text), "\n"); lines[len(lines)-1] != "abc" {
+		t.Errorf("expected the token on the last line:\n%s", msg.Text)
+	}
+}
+
+func TestLogLeavesOutBody(t *testing.T) {
+	var buf strings.Builder
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	msg, err := Verify.Render("nate@example.com", TokenData{Username: "nate", Token: "s3cr3t-token", Expires: "48h0m0s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (Log{}).Send(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if out := buf.String(); strings.Contains(out, "s3cr3t-token") || !strings.Contains(out, "nate@example.com") {
+		t.Errorf("unexpected log output: %q", out)
+	}
+}
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "instruu-mail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f, err := NewFile(dir, "instruu@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := f.Send(Message{To: "nate@example.com", Subject: "Hi", Text: "hello"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil || len(files) != 2 {
+		t.Errorf("expected a file per message, got %d (%v)", len(files), err)
+	}
+}
+
+func TestSMTP(t *testing.T) {
+	s := NewSMTP(SMTPOptions{Host: "smtp.example.com", Port: 587, Username: "instruu", From: "instruu@example.com"})
+
+	var addr string
+	var to []string
+	s.sendMail = func(a string, auth smtp.Auth, from string, recipients []string, msg []byte) error {
+		addr, to = a, recipients
+		if auth == nil {
+			t.Error("expected to authenticate with a username set")
+		}
+		return nil
+	}
+
+	if err := s.Send(Message{To: "nate@example.com", Subject: "Hi", Text: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if addr != "smtp.example.com:587" || len(to) != 1 || to[0] != "nate@example.com" {
+		t.Errorf("sent to %v through %s", to, addr)
+	}
+}
+
+// flakyMailer fails the first failures sends
+type flakyMailer struct {
+	failures int
+	Capture
+}
+
+func (m *flakyMailer) Send(msg Message) error {
+	if m.failures > 0 {
+		m.failures--
+		return errors.New("connection refused")
+	}
+	return m.Capture.Send(msg)
+}
+
+func TestOutbox(t *testing.T) {
+	sto := memory.New()
+	mailer := &flakyMailer{failures: 1}
+	outbox := NewOutbox(sto, mailer)
+
+	if err := outbox.Send(Message{To: "nate@example.com", Subject: "Hi", Text: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if sent, err := outbox.Flush(); err != nil || sent != 0 {
+		t.Fatalf("expected the first send to fail, sent %d (%v)", sent, err)
+	}
+
+	// the failed mail waits for its retry
+	if due, _ := sto.ClaimMail(10, time.Minute); len(due) != 0 {
+		t.Fatalf("expected the mail to wait before being retried, got %+v", due)
+	}
+	if err := sto.RetryMail(1, "", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	if sent, err := outbox.Flush(); err != nil || sent != 1 {
+		t.Fatalf("expected the retry to send, sent %d (%v)", sent, err)
+	}
+	if messages := mailer.Messages(); len(messages) != 1 || messages[0].To != "nate@example.com" {
+		t.Errorf("unexpected messages: %+v", messages)
+	}
+	if err := sto.DeleteMail(1); err != store.ErrNoResults {
+		t.Errorf("expected sent mail to leave the outbox, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package mail
+
+import (
+	"log"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+const (
+	// outboxBatch is how many mails are claimed at a time
+	outboxBatch = 10
+	// outboxLease has to outlast sending a batch, otherwise another worker could claim the
+	// same mail while it's still being sent
+	outboxLease = 5 * time.Minute
+	// outboxPoll is how often Run checks for mail that came due
+	outboxPoll = 30 * time.Second
+	// outboxMaxAttempts is how many sends fail before the outbox gives up on a mail
+	outboxMaxAttempts = 8
+)
+
+// Outbox queues mail in the store and sends it through another Mailer from Run. Mail is
+// only removed once it was sent, so it survives the process dying in between, and failed
+// sends are retried with a growing delay
+type Outbox struct {
+	sto    store.Service
+	mailer Mailer
+	wake   chan struct{}
+}
+
+// NewOutbox returns an Outbox sending through mailer
+func NewOutbox(sto store.Service, mailer Mailer) *Outbox {
+	return &Outbox{sto: sto, mailer: mailer, wake: make(chan struct{}, 1)}
+}
+
+// Send queues the message and wakes Run to send it
+func (o *Outbox) Send(msg Message) error {
+	_, err := o.sto.QueueMail(store.OutboxMail{To: msg.To, Subject: msg.Subject, Text: msg.Text, HTML: msg.HTML})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+		// Run was already woken
+	}
+	return nil
+}
+
+// Run sends mail as it's queued or comes due until stop is closed
+func (o *Outbox) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(outboxPoll)
+	defer ticker.Stop()
+
+	for {
+		if _, err := o.Flush(); err != nil {
+			log.Printf("flushing outbox: %v\n", err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-o.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush sends every mail that's due and returns how many were sent
+func (o *Outbox) Flush() (sent int, err error) {
+	for {
+		due, err := o.sto.ClaimMail(outboxBatch, outboxLease)
+		if err != nil {
+			return sent, err
+		}
+
+		for _, mail := range due {
+			msg := Message{To: mail.To, Subject: mail.Subject, Text: mail.Text, HTML: mail.HTML}
+			if err := o.mailer.Send(msg); err != nil {
+				o.retry(mail, err)
+				continue
+			}
+
+			sent++
+			if err := o.sto.DeleteMail(mail.ID); err != nil {
+				// it's sent again once the lease is up, better twice than never
+				log.Printf("removing sent mail %d from the outbox: %v\n", mail.ID, err)
+			}
+		}
+
+		if len(due) < outboxBatch {
+			return sent, nil
+		}
+	}
+}
+
+// retry schedules the next attempt at a failed mail, doubling the delay after every
+// attempt, or gives up on it after outboxMaxAttempts
+func (o *Outbox) retry(mail store.OutboxMail, sendErr error) {
+	var retryAt time.Time
+	if mail.Attempts < outboxMaxAttempts {
+		retryAt = time.Now().Add(time.Minute << uint(mail.Attempts-1))
+	} else {
+		log.Printf("giving up on mail %d to %s after %d attempts: %v\n", mail.ID, mail.To, mail.Attempts, sendErr)
+	}
+
+	if err := o.sto.RetryMail(mail.ID, sendErr.Error(), retryAt); err != nil {
+		log.Printf("rescheduling mail %d: %v\n", mail.ID, err)
+	}
+}
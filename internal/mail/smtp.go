@@ -0,0 +1,46 @@
+package mail
+
+import (
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// SMTPOptions configures the SMTP backend, mail is sent without authenticating when
+// Username is empty
+type SMTPOptions struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// SMTP delivers mail through an SMTP server, upgrading to TLS when the server offers it
+type SMTP struct {
+	options SMTPOptions
+	// sendMail is swapped out in tests
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewSMTP returns an SMTP backend for options
+func NewSMTP(options SMTPOptions) *SMTP {
+	return &SMTP{options: options, sendMail: smtp.SendMail}
+}
+
+// Send delivers the message
+func (s *SMTP) Send(msg Message) error {
+	data, err := encode(s.options.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.options.Username != "" {
+		auth = smtp.PlainAuth("", s.options.Username, s.options.Password, s.options.Host)
+	}
+
+	addr := net.JoinHostPort(s.options.Host, strconv.Itoa(s.options.Port))
+	return s.sendMail(addr, auth, s.options.From, []string{msg.To}, data)
+}
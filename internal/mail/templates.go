@@ -0,0 +1,105 @@
+package mail
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+)
+
+// Template renders the subject and bodies of one kind of mail, the HTML body is escaped
+// while the subject and text body are not
+type Template struct {
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+// NewTemplate parses the templates of a kind of mail, html can be empty to only send text
+func NewTemplate(subject, text, html string) (*Template, error) {
+	t := &Template{}
+	var err error
+	if t.subject, err = texttemplate.New("subject").Parse(subject); err != nil {
+		return nil, err
+	}
+	if t.text, err = texttemplate.New("text").Parse(text); err != nil {
+		return nil, err
+	}
+	if html != "" {
+		if t.html, err = htmltemplate.New("html").Parse(html); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// MustTemplate is NewTemplate for templates known to parse, it panics when they don't
+func MustTemplate(subject, text, html string) *Template {
+	t, err := NewTemplate(subject, text, html)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Render executes the templates with data and addresses the message to to
+func (t *Template) Render(to string, data interface{}) (Message, error) {
+	msg := Message{To: to}
+
+	var buf bytes.Buffer
+	if err := t.subject.Execute(&buf, data); err != nil {
+		return msg, err
+	}
+	msg.Subject = buf.String()
+
+	buf.Reset()
+	if err := t.text.Execute(&buf, data); err != nil {
+		return msg, err
+	}
+	msg.Text = buf.String()
+
+	if t.html != nil {
+		buf.Reset()
+		if err := t.html.Execute(&buf, data); err != nil {
+			return msg, err
+		}
+		msg.HTML = buf.String()
+	}
+	return msg, nil
+}
+
+// TokenData fills in the templates of mail that hands a user a token
+type TokenData struct {
+	Username string
+	Token    string
+	// Expires is how long the token stays valid, like 48h0m0s
+	Expires string
+}
+
+// Verify asks a user to verify their email, the token is the last line of the text body
+var Verify = MustTemplate(
+	"Verify your instruu email",
+	`Hi {{.Username}},
+
+Use this token to verify your email, it expires in {{.Expires}}:
+
+{{.Token}}
+`,
+	`<p>Hi {{.Username}},</p>
+<p>Use this token to verify your email, it expires in {{.Expires}}:</p>
+<p><code>{{.Token}}</code></p>
+`)
+
+// PasswordReset hands a user a token for choosing a new password, the token is the last
+// line of the text body
+var PasswordReset = MustTemplate(
+	"Reset your instruu password",
+	`Hi {{.Username}},
+
+Use this token to choose a new password, it expires in {{.Expires}}. If you didn't ask to reset your password you can ignore this email.
+
+{{.Token}}
+`,
+	`<p>Hi {{.Username}},</p>
+<p>Use this token to choose a new password, it expires in {{.Expires}}. If you didn't ask to reset your password you can ignore this email.</p>
+<p><code>{{.Token}}</code></p>
+`)
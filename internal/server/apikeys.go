@@ -0,0 +1,198 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/auth"
+	"github.com/natethinks/instruu-api/internal/respond"
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// API Key Functions
+
+// apiKeyIdentity looks up who an API key belongs to for CheckJWT
+func (s *Server) apiKeyIdentity(key string) (auth.Identity, error) {
+	stored, err := s.sto.UseAPIKey(auth.HashToken(key))
+	if err != nil {
+		return auth.Identity{}, err
+	}
+	return auth.Identity{ID: stored.User, APIKey: stored.ID, Scopes: stored.Scopes}, nil
+}
+
+// apiKeyOwner checks the user in the path is the acting user. Keys can only be managed
+// from a signed in session, so a leaked key can't be used to mint more
+func apiKeyOwner(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	user, ok := pathUser(w, r, "API keys can only be managed by their owner")
+	if !ok {
+		return user, false
+	}
+
+	if identity, _ := auth.IdentityFrom(r.Context()); identity.APIKey != 0 {
+		w.WriteHeader(http.StatusForbidden)
+		respond.JSON(w, errors.New("API keys can't be managed with an API key"))
+		return user, false
+	}
+
+	return user, true
+}
+
+// ownedAPIKey reads the key in the path, responding with a 404 when it isn't the user's
+func (s *Server) ownedAPIKey(w http.ResponseWriter, r *http.Request, user int64) (store.APIKey, bool) {
+	id, err := pathID(r, "kid")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return store.APIKey{}, false
+	}
+
+	key, err := s.sto.GetAPIKey(id)
+	if err == nil && key.User != user {
+		err = store.ErrNoResults
+	}
+	if err != nil {
+		storeError(w, err)
+		return key, false
+	}
+
+	return key, true
+}
+
+// getAPIKeys lists the user's keys, newest first, without the keys themselves
+func (s *Server) getAPIKeys(w http.ResponseWriter, r *http.Request) {
+	user, ok := apiKeyOwner(w, r)
+	if !ok {
+		return
+	}
+
+	keys, err := s.sto.GetAPIKeys(user)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if keys == nil {
+		keys = []store.APIKey{}
+	}
+
+	respond.JSON(w, keys)
+}
+
+// createAPIKey makes a key for the user, the response is the only time the key is shown
+func (s *Server) createAPIKey(w http.ResponseWriter, r *http.Request) {
+	user, ok := apiKeyOwner(w, r)
+	if !ok {
+		return
+	}
+
+	var key store.APIKey
+	if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if err := store.ValidateAPIKey(&key); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(time.Now()) {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, errors.New("API keys have to expire in the future"))
+		return
+	}
+
+	secret, prefix, hash, err := auth.NewAPIKey()
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	key.User = user
+	key.Prefix = prefix
+
+	id, err := s.sto.CreateAPIKey(key, hash)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if key, err = s.sto.GetAPIKey(id); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	respond.JSON(w, struct {
+		store.APIKey
+		Key string `json:"key"`
+	}{key, secret})
+}
+
+func (s *Server) getAPIKey(w http.ResponseWriter, r *http.Request) {
+	user, ok := apiKeyOwner(w, r)
+	if !ok {
+		return
+	}
+
+	key, ok := s.ownedAPIKey(w, r, user)
+	if !ok {
+		return
+	}
+
+	respond.JSON(w, key)
+}
+
+// putAPIKey renames a key and replaces its scopes, the key itself and its expiry can't be
+// changed
+func (s *Server) putAPIKey(w http.ResponseWriter, r *http.Request) {
+	user, ok := apiKeyOwner(w, r)
+	if !ok {
+		return
+	}
+
+	key, ok := s.ownedAPIKey(w, r, user)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	key.Name, key.Scopes = body.Name, body.Scopes
+	if err := store.ValidateAPIKey(&key); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	if err := s.sto.UpdateAPIKey(key); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	respond.JSON(w, key)
+}
+
+// deleteAPIKey revokes a key, requests made with it stop working right away
+func (s *Server) deleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	user, ok := apiKeyOwner(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := pathID(r, "kid")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.sto.DeleteAPIKey(user, id); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/natethinks/instruu-api/internal/respond"
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Bookmark Functions
+
+// markBookmarked flags the resources the acting user has saved, anonymous requests are left alone
+func (s *Server) markBookmarked(r *http.Request, resources ...*store.Resource) error {
+	user, ok := actingUser(r)
+	if !ok || len(resources) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(resources))
+	for i, resource := range resources {
+		ids[i] = resource.ID
+	}
+
+	bookmarked, err := s.sto.Bookmarked(user, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, resource := range resources {
+		resource.Bookmarked = bookmarked[resource.ID]
+	}
+	return nil
+}
+
+// each returns pointers to every resource so they can be changed in place
+func each(resources []store.Resource) []*store.Resource {
+	pointers := make([]*store.Resource, len(resources))
+	for i := range resources {
+		pointers[i] = &resources[i]
+	}
+	return pointers
+}
+
+// bookmarkOwner checks the user in the path is the acting user, bookmarks are private
+func bookmarkOwner(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	return pathUser(w, r, "Bookmarks can only be used by their owner")
+}
+
+// getBookmarks lists the acting user's saved resources, most recently saved first
+func (s *Server) getBookmarks(w http.ResponseWriter, r *http.Request) {
+	user, ok := bookmarkOwner(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := store.ParsePage(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	resources, err := s.sto.GetBookmarks(user, page)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if resources == nil {
+		resources = []store.Resource{}
+	}
+
+	respond.JSON(w, resources)
+}
+
+func (s *Server) putBookmark(w http.ResponseWriter, r *http.Request) {
+	user, ok := bookmarkOwner(w, r)
+	if !ok {
+		return
+	}
+
+	resourceID, err := pathID(r, "resourceID")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.sto.AddBookmark(user, resourceID); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) deleteBookmark(w http.ResponseWriter, r *http.Request) {
+	user, ok := bookmarkOwner(w, r)
+	if !ok {
+		return
+	}
+
+	resourceID, err := pathID(r, "resourceID")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.sto.RemoveBookmark(user, resourceID); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
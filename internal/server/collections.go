@@ -0,0 +1,313 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/natethinks/instruu-api/internal/respond"
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Collection Functions
+
+// ownedCollection loads the collection in the path and makes sure the acting user owns it,
+// it responds with the error itself when ok is false
+func (s *Server) ownedCollection(w http.ResponseWriter, r *http.Request) (collection store.Collection, ok bool) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return collection, false
+	}
+
+	user, ok := requireUser(w, r)
+	if !ok {
+		return collection, false
+	}
+
+	collection, err = s.sto.GetCollection(id)
+	if err != nil {
+		storeError(w, err)
+		return collection, false
+	}
+
+	if !collection.Visible(user) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return collection, false
+	}
+	if collection.Owner != user {
+		w.WriteHeader(http.StatusForbidden)
+		respond.JSON(w, errors.New("Only the owner can change a collection"))
+		return collection, false
+	}
+
+	return collection, true
+}
+
+// visibleCollection loads the collection in the path if the acting user is allowed to see it
+func (s *Server) visibleCollection(w http.ResponseWriter, r *http.Request) (collection store.Collection, ok bool) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return collection, false
+	}
+
+	collection, err = s.sto.GetCollection(id)
+	if err != nil {
+		storeError(w, err)
+		return collection, false
+	}
+
+	user, _ := actingUser(r)
+	if !collection.Visible(user) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return collection, false
+	}
+
+	return collection, true
+}
+
+// getCollections lists public collections, newest first
+func (s *Server) getCollections(w http.ResponseWriter, r *http.Request) {
+	page, err := store.ParsePage(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	s.respondCollections(w, store.CollectionQuery{PublicOnly: true, Page: page})
+}
+
+// getUserCollections lists the collections of a user, the owner also sees unlisted and private ones
+func (s *Server) getUserCollections(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	page, err := store.ParsePage(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	user, _ := actingUser(r)
+	s.respondCollections(w, store.CollectionQuery{Owner: id, PublicOnly: user != id, Page: page})
+}
+
+func (s *Server) respondCollections(w http.ResponseWriter, query store.CollectionQuery) {
+	collections, err := s.sto.GetCollections(query)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if collections == nil {
+		collections = []store.Collection{}
+	}
+
+	respond.JSON(w, collections)
+}
+
+// createCollection creates an empty collection owned by the acting user
+func (s *Server) createCollection(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	var collection store.Collection
+	if err := json.NewDecoder(r.Body).Decode(&collection); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := store.ValidateCollection(&collection); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+	collection.Owner = user
+
+	id, err := s.sto.CreateCollection(collection)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	respond.JSON(w, map[string]int64{"id": id})
+}
+
+func (s *Server) getCollection(w http.ResponseWriter, r *http.Request) {
+	collection, ok := s.visibleCollection(w, r)
+	if !ok {
+		return
+	}
+	if collection.Items == nil {
+		collection.Items = []store.CollectionItem{}
+	}
+
+	respond.JSON(w, collection)
+}
+
+// putCollection replaces the title, description and visibility of a collection
+func (s *Server) putCollection(w http.ResponseWriter, r *http.Request) {
+	collection, ok := s.ownedCollection(w, r)
+	if !ok {
+		return
+	}
+
+	var edit store.Collection
+	if err := json.NewDecoder(r.Body).Decode(&edit); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := store.ValidateCollection(&edit); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	collection.Title = edit.Title
+	collection.Description = edit.Description
+	collection.Visibility = edit.Visibility
+	if err := s.sto.UpdateCollection(collection); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	respond.JSON(w, collection)
+}
+
+func (s *Server) deleteCollection(w http.ResponseWriter, r *http.Request) {
+	collection, ok := s.ownedCollection(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.sto.DeleteCollection(collection.ID); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Collection Item Functions
+
+func (s *Server) getCollectionItems(w http.ResponseWriter, r *http.Request) {
+	collection, ok := s.visibleCollection(w, r)
+	if !ok {
+		return
+	}
+	if collection.Items == nil {
+		collection.Items = []store.CollectionItem{}
+	}
+
+	respond.JSON(w, collection.Items)
+}
+
+// addCollectionItem appends a resource to the end of a collection
+func (s *Server) addCollectionItem(w http.ResponseWriter, r *http.Request) {
+	collection, ok := s.ownedCollection(w, r)
+	if !ok {
+		return
+	}
+
+	var item store.CollectionItem
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	s.saveCollectionItem(w, collection.ID, item)
+}
+
+// putCollectionItem adds the resource in the path to a collection or updates its note
+func (s *Server) putCollectionItem(w http.ResponseWriter, r *http.Request) {
+	collection, ok := s.ownedCollection(w, r)
+	if !ok {
+		return
+	}
+
+	resourceID, err := pathID(r, "resourceID")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	var item store.CollectionItem
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	item.Resource = resourceID
+
+	s.saveCollectionItem(w, collection.ID, item)
+}
+
+func (s *Server) saveCollectionItem(w http.ResponseWriter, collectionID int64, item store.CollectionItem) {
+	if err := store.ValidateCollectionItems([]store.CollectionItem{item}); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	if err := s.sto.AddCollectionItem(collectionID, item); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// putCollectionItems replaces every item of a collection, it's how items are reordered
+func (s *Server) putCollectionItems(w http.ResponseWriter, r *http.Request) {
+	collection, ok := s.ownedCollection(w, r)
+	if !ok {
+		return
+	}
+
+	var items []store.CollectionItem
+	if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := store.ValidateCollectionItems(items); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	if err := s.sto.SetCollectionItems(collection.ID, items); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) deleteCollectionItem(w http.ResponseWriter, r *http.Request) {
+	collection, ok := s.ownedCollection(w, r)
+	if !ok {
+		return
+	}
+
+	resourceID, err := pathID(r, "resourceID")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.sto.RemoveCollectionItem(collection.ID, resourceID); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/natethinks/instruu-api/internal/respond"
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Comment Functions
+
+// resourceComment loads the comment in the path, making sure it's on the resource in the path
+func (s *Server) resourceComment(w http.ResponseWriter, r *http.Request) (comment store.Comment, ok bool) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return comment, false
+	}
+
+	commentID, err := pathID(r, "commentID")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return comment, false
+	}
+
+	comment, err = s.sto.GetComment(commentID)
+	if err == nil && comment.Resource != id {
+		err = store.ErrNoResults
+	}
+	if err != nil {
+		storeError(w, err)
+		return comment, false
+	}
+
+	return comment, true
+}
+
+// authoredComment loads the comment in the path and makes sure the acting user wrote it
+func (s *Server) authoredComment(w http.ResponseWriter, r *http.Request) (comment store.Comment, ok bool) {
+	user, ok := requireUser(w, r)
+	if !ok {
+		return comment, false
+	}
+
+	comment, ok = s.resourceComment(w, r)
+	if !ok {
+		return comment, false
+	}
+
+	if comment.Deleted {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return comment, false
+	}
+	if comment.Author != user {
+		w.WriteHeader(http.StatusForbidden)
+		respond.JSON(w, errors.New("Only the author can change a comment"))
+		return comment, false
+	}
+
+	return comment, true
+}
+
+// getComments pages through the top level comments of a resource, each with all its replies
+func (s *Server) getComments(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	page, err := store.ParsePage(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	if _, err := s.sto.GetResource(id); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	comments, err := s.sto.GetComments(id, page)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if comments == nil {
+		comments = []store.Comment{}
+	}
+
+	respond.JSON(w, comments)
+}
+
+// createComment adds a comment by the acting user, it's a reply when the body has a parent
+func (s *Server) createComment(w http.ResponseWriter, r *http.Request) {
+	author, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	var comment store.Comment
+	if err := json.NewDecoder(r.Body).Decode(&comment); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	comment.Resource = id
+	comment.Author = author
+
+	if err := store.ValidateCommentBody(comment.Body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	var parent store.Comment
+	if comment.Parent != 0 {
+		parent, err = s.sto.GetComment(comment.Parent)
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+
+		if err := comment.Reply(parent); err == store.ErrNoResults {
+			storeError(w, err)
+			return
+		} else if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			respond.JSON(w, err)
+			return
+		}
+	}
+
+	commentID, err := s.sto.CreateComment(comment)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	// replies notify whoever they reply to, top level comments the resource's submitter
+	notification := store.Notification{Type: store.NotificationComment, Actor: author, Resource: id, Comment: commentID}
+	if comment.Parent != 0 {
+		notification.Type, notification.User = store.NotificationReply, parent.Author
+		s.notify(notification)
+	} else {
+		s.notifySubmitter(notification)
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	respond.JSON(w, map[string]int64{"id": commentID})
+}
+
+func (s *Server) getComment(w http.ResponseWriter, r *http.Request) {
+	comment, ok := s.resourceComment(w, r)
+	if !ok {
+		return
+	}
+
+	respond.JSON(w, comment.Tombstone())
+}
+
+// putComment replaces the body of one of the acting user's comments
+func (s *Server) putComment(w http.ResponseWriter, r *http.Request) {
+	comment, ok := s.authoredComment(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Body string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := store.ValidateCommentBody(body.Body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	if err := s.sto.UpdateComment(comment.ID, body.Body); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	comment, err := s.sto.GetComment(comment.ID)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	respond.JSON(w, comment)
+}
+
+// deleteComment tombstones one of the acting user's comments
+func (s *Server) deleteComment(w http.ResponseWriter, r *http.Request) {
+	comment, ok := s.authoredComment(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.sto.DeleteComment(comment.ID); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
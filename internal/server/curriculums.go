@@ -0,0 +1,408 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/natethinks/instruu-api/internal/respond"
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Curriculum Functions
+
+// ownedCurriculum loads the curriculum in the path and makes sure the acting user owns it,
+// it responds with the error itself when ok is false
+func (s *Server) ownedCurriculum(w http.ResponseWriter, r *http.Request) (curriculum store.Curriculum, ok bool) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return curriculum, false
+	}
+
+	user, ok := requireUser(w, r)
+	if !ok {
+		return curriculum, false
+	}
+
+	curriculum, err = s.sto.GetCurriculum(id)
+	if err != nil {
+		storeError(w, err)
+		return curriculum, false
+	}
+
+	if curriculum.Owner != user {
+		w.WriteHeader(http.StatusForbidden)
+		respond.JSON(w, errors.New("Only the owner can change a curriculum"))
+		return curriculum, false
+	}
+
+	return curriculum, true
+}
+
+// ownedSection loads the section in the path from a curriculum the acting user owns
+func (s *Server) ownedSection(w http.ResponseWriter, r *http.Request) (curriculum store.Curriculum, section *store.Section, ok bool) {
+	curriculum, ok = s.ownedCurriculum(w, r)
+	if !ok {
+		return curriculum, nil, false
+	}
+
+	sectionID, err := pathID(r, "sectionID")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return curriculum, nil, false
+	}
+
+	section, err = curriculum.FindSection(sectionID)
+	if err != nil {
+		storeError(w, err)
+		return curriculum, nil, false
+	}
+
+	return curriculum, section, true
+}
+
+func (s *Server) getCurriculums(w http.ResponseWriter, r *http.Request) {
+	page, err := store.ParsePage(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	s.respondCurriculums(w, store.CurriculumQuery{Page: page})
+}
+
+func (s *Server) getUserCurriculums(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	page, err := store.ParsePage(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	s.respondCurriculums(w, store.CurriculumQuery{Owner: id, Page: page})
+}
+
+func (s *Server) respondCurriculums(w http.ResponseWriter, query store.CurriculumQuery) {
+	curriculums, err := s.sto.GetCurriculums(query)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if curriculums == nil {
+		curriculums = []store.Curriculum{}
+	}
+
+	respond.JSON(w, curriculums)
+}
+
+// createCurriculum creates an empty curriculum owned by the acting user
+func (s *Server) createCurriculum(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	var curriculum store.Curriculum
+	if err := json.NewDecoder(r.Body).Decode(&curriculum); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := store.ValidateCurriculum(curriculum); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+	curriculum.Owner = user
+
+	id, err := s.sto.CreateCurriculum(curriculum)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	respond.JSON(w, map[string]int64{"id": id})
+}
+
+// getCurriculum returns the whole tree of sections and steps
+func (s *Server) getCurriculum(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	curriculum, err := s.sto.GetCurriculum(id)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if curriculum.Sections == nil {
+		curriculum.Sections = []store.Section{}
+	}
+
+	respond.JSON(w, curriculum)
+}
+
+// putCurriculum replaces the title and description of a curriculum
+func (s *Server) putCurriculum(w http.ResponseWriter, r *http.Request) {
+	curriculum, ok := s.ownedCurriculum(w, r)
+	if !ok {
+		return
+	}
+
+	var edit store.Curriculum
+	if err := json.NewDecoder(r.Body).Decode(&edit); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := store.ValidateCurriculum(edit); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	curriculum.Title = edit.Title
+	curriculum.Description = edit.Description
+	if err := s.sto.UpdateCurriculum(curriculum); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	respond.JSON(w, curriculum)
+}
+
+func (s *Server) deleteCurriculum(w http.ResponseWriter, r *http.Request) {
+	curriculum, ok := s.ownedCurriculum(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.sto.DeleteCurriculum(curriculum.ID); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeOrder reads a JSON array of IDs and checks it lists every one of ids exactly once
+func decodeOrder(w http.ResponseWriter, r *http.Request, ids []int64) (order []int64, ok bool) {
+	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return nil, false
+	}
+
+	if err := store.ValidateOrder(ids, order); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return nil, false
+	}
+
+	return order, true
+}
+
+// Curriculum Section Functions
+
+func (s *Server) addCurriculumSection(w http.ResponseWriter, r *http.Request) {
+	curriculum, ok := s.ownedCurriculum(w, r)
+	if !ok {
+		return
+	}
+
+	var section store.Section
+	if err := json.NewDecoder(r.Body).Decode(&section); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := store.ValidateSection(section); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	id, err := s.sto.AddCurriculumSection(curriculum.ID, section)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	respond.JSON(w, map[string]int64{"id": id})
+}
+
+// orderCurriculumSections takes every section ID of the curriculum in their new order
+func (s *Server) orderCurriculumSections(w http.ResponseWriter, r *http.Request) {
+	curriculum, ok := s.ownedCurriculum(w, r)
+	if !ok {
+		return
+	}
+
+	order, ok := decodeOrder(w, r, curriculum.SectionIDs())
+	if !ok {
+		return
+	}
+
+	if err := s.sto.OrderCurriculumSections(curriculum.ID, order); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) putCurriculumSection(w http.ResponseWriter, r *http.Request) {
+	curriculum, section, ok := s.ownedSection(w, r)
+	if !ok {
+		return
+	}
+
+	var edit store.Section
+	if err := json.NewDecoder(r.Body).Decode(&edit); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := store.ValidateSection(edit); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	section.Title = edit.Title
+	section.Description = edit.Description
+	if err := s.sto.UpdateCurriculumSection(curriculum.ID, *section); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	respond.JSON(w, section)
+}
+
+func (s *Server) deleteCurriculumSection(w http.ResponseWriter, r *http.Request) {
+	curriculum, section, ok := s.ownedSection(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.sto.DeleteCurriculumSection(curriculum.ID, section.ID); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Curriculum Step Functions
+
+func (s *Server) addCurriculumStep(w http.ResponseWriter, r *http.Request) {
+	curriculum, section, ok := s.ownedSection(w, r)
+	if !ok {
+		return
+	}
+
+	var step store.Step
+	if err := json.NewDecoder(r.Body).Decode(&step); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := store.ValidateStep(step); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	id, err := s.sto.AddCurriculumStep(curriculum.ID, section.ID, step)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	respond.JSON(w, map[string]int64{"id": id})
+}
+
+// orderCurriculumSteps takes every step ID of the section in their new order
+func (s *Server) orderCurriculumSteps(w http.ResponseWriter, r *http.Request) {
+	curriculum, section, ok := s.ownedSection(w, r)
+	if !ok {
+		return
+	}
+
+	order, ok := decodeOrder(w, r, section.StepIDs())
+	if !ok {
+		return
+	}
+
+	if err := s.sto.OrderCurriculumSteps(curriculum.ID, section.ID, order); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) putCurriculumStep(w http.ResponseWriter, r *http.Request) {
+	curriculum, section, ok := s.ownedSection(w, r)
+	if !ok {
+		return
+	}
+
+	stepID, err := pathID(r, "stepID")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	var step store.Step
+	if err := json.NewDecoder(r.Body).Decode(&step); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	step.ID = stepID
+
+	if err := store.ValidateStep(step); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	if err := s.sto.UpdateCurriculumStep(curriculum.ID, section.ID, step); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	respond.JSON(w, step)
+}
+
+func (s *Server) deleteCurriculumStep(w http.ResponseWriter, r *http.Request) {
+	curriculum, section, ok := s.ownedSection(w, r)
+	if !ok {
+		return
+	}
+
+	stepID, err := pathID(r, "stepID")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.sto.DeleteCurriculumStep(curriculum.ID, section.ID, stepID); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/natethinks/instruu-api/internal/respond"
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Follow Functions
+
+// followOwner checks the user in the path is the acting user, only they can change who
+// they follow
+func followOwner(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	return pathUser(w, r, "Follows can only be changed by their owner")
+}
+
+// getFollows lists the users and tags a user follows, follows are public
+func (s *Server) getFollows(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	follows, err := s.sto.GetFollows(id)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	respond.JSON(w, follows)
+}
+
+func (s *Server) followUser(w http.ResponseWriter, r *http.Request) {
+	follower, ok := followOwner(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := pathID(r, "userID")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if user == follower {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, errors.New("Users can't follow themselves"))
+		return
+	}
+
+	followed, err := s.sto.FollowUser(follower, user)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	// following again is a no-op, only the first follow is worth telling them about
+	if followed {
+		s.notify(store.Notification{User: user, Type: store.NotificationFollow, Actor: follower})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) unfollowUser(w http.ResponseWriter, r *http.Request) {
+	follower, ok := followOwner(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := pathID(r, "userID")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.sto.UnfollowUser(follower, user); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) followTag(w http.ResponseWriter, r *http.Request) {
+	follower, ok := followOwner(w, r)
+	if !ok {
+		return
+	}
+
+	tags, err := store.NormalizeTags([]string{mux.Vars(r)["name"]})
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	if err := s.sto.FollowTag(follower, tags[0]); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) unfollowTag(w http.ResponseWriter, r *http.Request) {
+	follower, ok := followOwner(w, r)
+	if !ok {
+		return
+	}
+
+	tags, err := store.NormalizeTags([]string{mux.Vars(r)["name"]})
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	if err := s.sto.UnfollowTag(follower, tags[0]); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Feed Functions
+
+// getFeed lists what happened recently among the users and tags the acting user follows,
+// newest first. When the page is full the X-Next-Cursor header holds the cursor for the
+// next one
+func (s *Server) getFeed(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	query, err := store.ParseFeedQuery(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	events, err := s.sto.GetFeed(user, query)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if events == nil {
+		events = []store.Event{}
+	}
+
+	if len(events) == query.Limit {
+		w.Header().Set("X-Next-Cursor", strconv.FormatInt(events[len(events)-1].ID, 10))
+	}
+
+	respond.JSON(w, events)
+}
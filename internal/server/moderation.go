@@ -0,0 +1,174 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/natethinks/instruu-api/internal/auth"
+	"github.com/natethinks/instruu-api/internal/respond"
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// actingUser identifies the caller from the JWT CheckJWT verified
+func actingUser(r *http.Request) (int64, bool) {
+	identity, ok := auth.IdentityFrom(r.Context())
+	return identity.ID, ok
+}
+
+// requireUser returns the acting user, responding with a 401 when there isn't one
+func requireUser(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, ok := actingUser(r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		respond.JSON(w, errors.New("Missing or invalid user"))
+	}
+	return id, ok
+}
+
+// pathUser checks the user in the path is the acting user, responding with forbidden as a
+// 403 when it's someone else
+func pathUser(w http.ResponseWriter, r *http.Request, forbidden string) (user int64, ok bool) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return id, false
+	}
+
+	user, ok = requireUser(w, r)
+	if !ok {
+		return user, false
+	}
+	if user != id {
+		w.WriteHeader(http.StatusForbidden)
+		respond.JSON(w, errors.New(forbidden))
+		return user, false
+	}
+
+	return user, true
+}
+
+// Moderation Functions
+
+// getPendingResources lists the moderation queue, oldest submission first
+func (s *Server) getPendingResources(w http.ResponseWriter, r *http.Request) {
+	page, err := store.ParsePage(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	resources, err := s.sto.GetPendingResources(page)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if resources == nil {
+		resources = []store.Resource{}
+	}
+
+	respond.JSON(w, resources)
+}
+
+func (s *Server) approveResource(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	moderator, _ := actingUser(r)
+	approved, err := s.sto.ApproveResource(id, moderator)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	// approving it again isn't news to the submitter
+	if approved {
+		s.notifySubmitter(store.Notification{Type: store.NotificationApproved, Actor: moderator, Resource: id})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// rejectResource turns a submission down, the reason is required since it's shown to the submitter
+func (s *Server) rejectResource(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	body.Reason = strings.TrimSpace(body.Reason)
+	if body.Reason == "" || len(body.Reason) > 1000 {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, errors.New("A reason of at most 1000 characters is required"))
+		return
+	}
+
+	moderator, _ := actingUser(r)
+	if err := s.sto.RejectResource(id, moderator, body.Reason); err != nil {
+		storeError(w, err)
+		return
+	}
+	s.notifySubmitter(store.Notification{
+		Type:     store.NotificationRejected,
+		Actor:    moderator,
+		Resource: id,
+		Message:  body.Reason,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getUserResources lists what a user submitted, the submitter themselves also sees pending
+// and rejected submissions along with the rejection reason
+func (s *Server) getUserResources(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	query, err := store.ParseResourceQuery(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+	query.Submitter = id
+	if caller, ok := actingUser(r); ok && caller == id {
+		query.Approved = nil
+	}
+
+	resources, err := s.sto.GetResources(query)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if resources == nil {
+		resources = []store.Resource{}
+	}
+
+	if err := s.markBookmarked(r, each(resources)...); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if len(resources) == query.Limit {
+		w.Header().Set("X-Next-Cursor", strconv.FormatInt(resources[len(resources)-1].ID, 10))
+	}
+
+	respond.JSON(w, resources)
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/natethinks/instruu-api/internal/respond"
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Notification Functions
+
+// notify creates a notification unless it's about the user's own doing or they turned
+// its type off. It never fails the request that caused it so errors are only logged
+func (s *Server) notify(notification store.Notification) {
+	if notification.User == 0 || notification.User == notification.Actor {
+		return
+	}
+
+	user, err := s.sto.GetUser(notification.User)
+	if err != nil {
+		log.Printf("notifying user %d: %v\n", notification.User, err)
+		return
+	}
+	if !user.Notifications.Enabled(notification.Type) {
+		return
+	}
+
+	if _, err := s.sto.CreateNotification(notification); err != nil {
+		log.Printf("notifying user %d: %v\n", notification.User, err)
+	}
+}
+
+// notifySubmitter notifies whoever submitted the resource of the notification
+func (s *Server) notifySubmitter(notification store.Notification) {
+	resource, err := s.sto.GetResource(notification.Resource)
+	if err != nil {
+		log.Printf("notifying submitter of resource %d: %v\n", notification.Resource, err)
+		return
+	}
+
+	notification.User = resource.Submitter
+	s.notify(notification)
+}
+
+// getNotifications lists the acting user's notifications newest first, along with how
+// many of them are unread
+func (s *Server) getNotifications(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	query, err := store.ParseNotificationQuery(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	notifications, err := s.sto.GetNotifications(user, query)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if notifications == nil {
+		notifications = []store.Notification{}
+	}
+
+	unread, err := s.sto.UnreadNotifications(user)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	respond.JSON(w, store.Notifications{Unread: unread, Notifications: notifications})
+}
+
+func (s *Server) readNotification(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.sto.MarkNotificationRead(user, id); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) readAllNotifications(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.sto.MarkAllNotificationsRead(user); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getNotificationPreferences lists every notification type with whether the acting user
+// has it on
+func (s *Server) getNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	id, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := s.sto.GetUser(id)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	respond.JSON(w, user.Notifications.All())
+}
+
+// putNotificationPreferences replaces the acting user's preferences with a JSON object of
+// types to whether they're on, types left out are on
+func (s *Server) putNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	id, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	var preferences store.NotificationPreferences
+	if err := json.NewDecoder(r.Body).Decode(&preferences); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := store.ValidateNotificationPreferences(preferences); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	if err := s.sto.SetNotificationPreferences(id, preferences); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	respond.JSON(w, preferences.All())
+}
@@ -0,0 +1,256 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/natethinks/instruu-api/internal/auth"
+	"github.com/natethinks/instruu-api/internal/respond"
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// maxUsernameLength caps the usernames made up for users signing up through a provider
+const maxUsernameLength = 32
+
+// OAuth Functions
+
+// pathProvider reads the provider in the path, responding with a 404 when there's no such
+// provider
+func (s *Server) pathProvider(w http.ResponseWriter, r *http.Request) (*auth.Provider, bool) {
+	provider, ok := s.oauth.Provider(mux.Vars(r)["provider"])
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	}
+	return provider, ok
+}
+
+// beginOAuth sends the user to sign in at the provider. With ?link=true the provider's
+// account is linked to the acting user instead of signing in
+func (s *Server) beginOAuth(w http.ResponseWriter, r *http.Request) {
+	provider, ok := s.pathProvider(w, r)
+	if !ok {
+		return
+	}
+
+	var link int64
+	if r.URL.Query().Get("link") == "true" {
+		if link, ok = requireUser(w, r); !ok {
+			return
+		}
+		if identity, _ := auth.IdentityFrom(r.Context()); identity.APIKey != 0 {
+			w.WriteHeader(http.StatusForbidden)
+			respond.JSON(w, errors.New("Accounts can't be linked with an API key"))
+			return
+		}
+	}
+
+	if err := s.oauth.Begin(w, r, provider, link); err != nil {
+		storeError(w, err)
+	}
+}
+
+// oauthCallback finishes a sign in at the provider. It signs in the user the provider's
+// account is linked to, links it to the user who started linking, or signs up a new user
+func (s *Server) oauthCallback(w http.ResponseWriter, r *http.Request) {
+	provider, ok := s.pathProvider(w, r)
+	if !ok {
+		return
+	}
+
+	profile, link, err := s.oauth.Complete(w, r, provider)
+	if err == auth.ErrInvalidState {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	} else if _, denied := err.(*auth.OAuthError); denied {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	} else if err != nil {
+		log.Printf("signing in with %s: %v\n", provider.Name(), err)
+		w.WriteHeader(http.StatusBadGateway)
+		respond.JSON(w, errors.New("Signing in with "+provider.Name()+" failed, try again later"))
+		return
+	}
+
+	id, err := s.sto.GetAccountUser(provider.Name(), profile.Subject)
+	switch {
+	case err == nil && link != 0 && id != link:
+		w.WriteHeader(http.StatusConflict)
+		respond.JSON(w, errors.New("This "+provider.Name()+" account is linked to another user"))
+		return
+	case err == nil:
+	case err != store.ErrNoResults:
+		storeError(w, err)
+		return
+	case link != 0:
+		if !s.linkAccount(w, provider, profile, link) {
+			return
+		}
+		id = link
+	default:
+		if id, ok = s.signUpExternal(w, provider, profile); !ok {
+			return
+		}
+	}
+
+	if link != 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	user, err := s.sto.GetUser(id)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	s.startSession(w, r, user)
+}
+
+// linkAccount links the user's account at the provider to them
+func (s *Server) linkAccount(w http.ResponseWriter, provider *auth.Provider, profile auth.Profile, user int64) bool {
+	err := s.sto.LinkAccount(store.ExternalAccount{
+		Provider: provider.Name(),
+		Subject:  profile.Subject,
+		User:     user,
+		Email:    profile.Email,
+	})
+	if err == store.ErrAccountLinked {
+		w.WriteHeader(http.StatusConflict)
+		respond.JSON(w, errors.New("Another "+provider.Name()+" account is already linked, unlink it first"))
+		return false
+	} else if err != nil {
+		storeError(w, err)
+		return false
+	}
+	return true
+}
+
+// signUpExternal creates a user for someone signing in with a provider for the first time.
+// Only an email the provider verified is kept, any other email could belong to anyone. A
+// verified email that belongs to an existing user isn't linked automatically either, that
+// user has to sign in and link instead
+func (s *Server) signUpExternal(w http.ResponseWriter, provider *auth.Provider, profile auth.Profile) (int64, bool) {
+	var email string
+	if profile.EmailVerified {
+		email = profile.Email
+	}
+
+	if email != "" {
+		_, err := s.sto.GetUserByEmail(email)
+		if err == nil {
+			w.WriteHeader(http.StatusConflict)
+			respond.JSON(w, errors.New("An account already uses this email, sign in to it and link "+provider.Name()+" instead"))
+			return 0, false
+		} else if err != store.ErrNoResults {
+			storeError(w, err)
+			return 0, false
+		}
+	}
+
+	username, err := s.availableUsername(provider, profile)
+	if err != nil {
+		storeError(w, err)
+		return 0, false
+	}
+	// the user signs in through the provider, they can pick a password with a reset
+	password, _, err := auth.NewToken()
+	if err != nil {
+		storeError(w, err)
+		return 0, false
+	}
+
+	user := store.User{Username: username, Email: email, Password: password}
+	user.ID, err = s.sto.CreateExternalUser(user, store.ExternalAccount{
+		Provider: provider.Name(),
+		Subject:  profile.Subject,
+		Email:    profile.Email,
+	})
+	if err == store.ErrAccountLinked || err == store.ErrUsernameExists {
+		// someone else signed up with the account or took the username since they were checked
+		w.WriteHeader(http.StatusConflict)
+		respond.JSON(w, errors.New("Signing up with "+provider.Name()+" clashed with another sign up, try again"))
+		return 0, false
+	} else if err != nil {
+		storeError(w, err)
+		return 0, false
+	}
+
+	// like a regular signup a failed verification doesn't fail it
+	if user.Email != "" {
+		if err := s.sto.VerifyUser(user.ID); err != nil {
+			log.Printf("verifying user %d: %v\n", user.ID, err)
+		}
+	}
+
+	return user.ID, true
+}
+
+// availableUsername makes up a username from the profile, numbering it when it's taken
+func (s *Server) availableUsername(provider *auth.Provider, profile auth.Profile) (string, error) {
+	base := profile.Username
+	if base == "" {
+		base = strings.SplitN(profile.Email, "@", 2)[0]
+	}
+	base = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return -1
+	}, base)
+	if base == "" {
+		base = provider.Name()
+	}
+	if len(base) > maxUsernameLength-3 {
+		base = base[:maxUsernameLength-3]
+	}
+
+	for i := 1; i < 1000; i++ {
+		username := base
+		if i > 1 {
+			username += strconv.Itoa(i)
+		}
+		if err := s.sto.CheckUsername(store.User{Username: username}); err == nil {
+			return username, nil
+		}
+	}
+	return "", errors.New("no username left for " + base)
+}
+
+// getAccounts lists the provider accounts linked to the user
+func (s *Server) getAccounts(w http.ResponseWriter, r *http.Request) {
+	user, ok := pathUser(w, r, "Linked accounts can only be seen by their owner")
+	if !ok {
+		return
+	}
+
+	accounts, err := s.sto.GetAccounts(user)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if accounts == nil {
+		accounts = []store.ExternalAccount{}
+	}
+
+	respond.JSON(w, accounts)
+}
+
+// deleteAccount unlinks the user's account at a provider, they can no longer sign in with it
+func (s *Server) deleteAccount(w http.ResponseWriter, r *http.Request) {
+	user, ok := pathUser(w, r, "Linked accounts can only be unlinked by their owner")
+	if !ok {
+		return
+	}
+
+	if err := s.sto.UnlinkAccount(user, mux.Vars(r)["provider"]); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
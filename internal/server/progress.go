@@ -0,0 +1,146 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/natethinks/instruu-api/internal/respond"
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Progress Functions
+
+// curriculumProgress loads a curriculum and works out how far the learner has got through it
+func (s *Server) curriculumProgress(learner, curriculumID int64) (store.Progress, error) {
+	curriculum, err := s.sto.GetCurriculum(curriculumID)
+	if err != nil {
+		return store.Progress{}, err
+	}
+
+	steps, err := s.sto.GetStepProgress(learner, curriculumID)
+	if err != nil {
+		return store.Progress{}, err
+	}
+
+	return store.ComputeProgress(curriculum, steps), nil
+}
+
+// putStepProgress marks a step as started or completed for the acting user and responds
+// with their progress through the curriculum
+func (s *Server) putStepProgress(w http.ResponseWriter, r *http.Request) {
+	learner, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	stepID, err := pathID(r, "stepID")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := store.ValidateProgressStatus(body.Status); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	if err := s.sto.SetStepProgress(learner, id, stepID, body.Status); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	progress, err := s.curriculumProgress(learner, id)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	respond.JSON(w, progress)
+}
+
+// getCurriculumProgress returns the acting user's progress through a curriculum
+func (s *Server) getCurriculumProgress(w http.ResponseWriter, r *http.Request) {
+	learner, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	progress, err := s.curriculumProgress(learner, id)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	respond.JSON(w, progress)
+}
+
+// getUserProgress summarises every curriculum a user has started, most recently active
+// first, progress is private so only the user themselves can read it
+func (s *Server) getUserProgress(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	learner, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+	if learner != id {
+		w.WriteHeader(http.StatusForbidden)
+		respond.JSON(w, errors.New("Progress can only be read by its owner"))
+		return
+	}
+
+	steps, err := s.sto.GetStepProgress(learner, 0)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	byCurriculum := make(map[int64][]store.StepProgress)
+	for _, step := range steps {
+		byCurriculum[step.Curriculum] = append(byCurriculum[step.Curriculum], step)
+	}
+
+	summary := []store.Progress{}
+	for curriculumID, steps := range byCurriculum {
+		curriculum, err := s.sto.GetCurriculum(curriculumID)
+		if err == store.ErrNoResults {
+			// the curriculum was deleted after the learner started it
+			continue
+		} else if err != nil {
+			storeError(w, err)
+			return
+		}
+
+		summary = append(summary, store.ComputeProgress(curriculum, steps))
+	}
+	sort.Slice(summary, func(i, j int) bool { return summary[i].LastActivity.After(summary[j].LastActivity) })
+
+	respond.JSON(w, summary)
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/auth"
+	"github.com/natethinks/instruu-api/internal/mail"
+	"github.com/natethinks/instruu-api/internal/respond"
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// passwordResetTTL is how long a password reset token stays valid
+const passwordResetTTL = time.Hour
+
+// Password Reset Functions
+
+// sendPasswordReset mails the user a single use token for choosing a new password
+func (s *Server) sendPasswordReset(user store.User) error {
+	token, hash, err := auth.NewToken()
+	if err != nil {
+		return err
+	}
+
+	reset := store.PasswordReset{TokenHash: hash, User: user.ID, ExpiresAt: time.Now().Add(passwordResetTTL)}
+	if err := s.sto.CreatePasswordReset(reset); err != nil {
+		return err
+	}
+
+	msg, err := mail.PasswordReset.Render(user.Email, mail.TokenData{
+		Username: user.Username,
+		Token:    token,
+		Expires:  passwordResetTTL.String(),
+	})
+	if err != nil {
+		return err
+	}
+	return s.options.Mailer.Send(msg)
+}
+
+// forgotPassword mails a reset token to the user with the email in the JSON body. It
+// responds the same whether or not anyone has that email so it can't be used to find out
+// who has an account
+func (s *Server) forgotPassword(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.sto.GetUserByEmail(body.Email)
+	if err == nil {
+		err = s.sendPasswordReset(user)
+	}
+	if err != nil && err != store.ErrNoResults {
+		log.Printf("sending password reset: %v\n", err)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// resetPassword consumes a reset token from the JSON body and sets the new password, every
+// session the user had is revoked so whoever else might be signed in is signed out
+func (s *Server) resetPassword(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if body.Token == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, auth.ErrInvalidToken)
+		return
+	}
+	if err := store.ValidatePassword(body.Password); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	passwordHash := auth.GeneratePasswordHash([]byte(body.Password))
+	if passwordHash == "" {
+		storeError(w, errors.New("hashing the new password failed"))
+		return
+	}
+
+	_, err := s.sto.ResetPassword(auth.HashToken(body.Token), passwordHash)
+	if err == store.ErrNoResults {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, auth.ErrInvalidToken)
+		return
+	} else if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
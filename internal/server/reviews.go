@@ -0,0 +1,110 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/natethinks/instruu-api/internal/respond"
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Review Functions
+
+// getReviews lists the reviews of a resource, most recently updated first
+func (s *Server) getReviews(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	page, err := store.ParsePage(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	if _, err := s.sto.GetResource(id); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	reviews, err := s.sto.GetReviews(id, page)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if reviews == nil {
+		reviews = []store.Review{}
+	}
+
+	respond.JSON(w, reviews)
+}
+
+// putReview creates or replaces the acting user's review of a resource
+func (s *Server) putReview(w http.ResponseWriter, r *http.Request) {
+	reviewer, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	var review store.Review
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	review.Resource = id
+	review.Reviewer = reviewer
+
+	if err := store.ValidateReview(review); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	if err := s.sto.SetReview(review); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	review, err = s.sto.GetReview(id, reviewer)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	// only new reviews are worth telling the submitter about
+	if review.UpdatedAt.Equal(review.CreatedAt) {
+		s.notifySubmitter(store.Notification{Type: store.NotificationReview, Actor: reviewer, Resource: id})
+	}
+
+	respond.JSON(w, review)
+}
+
+// deleteReview removes the acting user's review of a resource
+func (s *Server) deleteReview(w http.ResponseWriter, r *http.Request) {
+	reviewer, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.sto.DeleteReview(id, reviewer); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
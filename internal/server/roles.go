@@ -0,0 +1,73 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/natethinks/instruu-api/internal/respond"
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Role Functions
+
+// userRoles looks up what roles a user was granted for the authorizer
+func (s *Server) userRoles(id int64) ([]string, error) {
+	user, err := s.sto.GetUser(id)
+	return user.Roles, err
+}
+
+// pathRole reads the user and role from the path, responding with a 400 when either is invalid
+func pathRole(w http.ResponseWriter, r *http.Request) (int64, string, bool) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return id, "", false
+	}
+
+	role := mux.Vars(r)["role"]
+	if err := store.ValidateRole(role); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return id, role, false
+	}
+
+	return id, role, true
+}
+
+// grantRole gives a user a role, granting a role they already have does nothing
+func (s *Server) grantRole(w http.ResponseWriter, r *http.Request) {
+	id, role, ok := pathRole(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.sto.SetRole(id, role, true); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeRole takes a role from a user. Admins can't revoke their own admin role so there's
+// always someone left who can grant it
+func (s *Server) revokeRole(w http.ResponseWriter, r *http.Request) {
+	id, role, ok := pathRole(w, r)
+	if !ok {
+		return
+	}
+
+	if admin, _ := actingUser(r); id == admin && role == store.RoleAdmin {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, errors.New("Admins can't revoke their own admin role"))
+		return
+	}
+
+	if err := s.sto.SetRole(id, role, false); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
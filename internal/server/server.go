@@ -1,14 +1,19 @@
 package server
 
 import (
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"strconv"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	"github.com/natethinks/instruu-api/internal/auth"
+	"github.com/natethinks/instruu-api/internal/mail"
 	"github.com/natethinks/instruu-api/internal/respond"
 	"github.com/natethinks/instruu-api/internal/store"
 )
@@ -16,12 +21,52 @@ import (
 // Server abstracts handlers and the store service
 type Server struct {
 	sto     store.Service
+	options Options
+	tokens  *auth.TokenService
+	authz   *auth.Authorizer
+	oauth   *auth.OAuth
 	handler http.Handler
 }
 
+// Options configures a Server, the zero value is enough for tests and local development
+type Options struct {
+	// Secret signs the tokens the server hands out, a random one is used when it's empty
+	// so tokens stop working when the server restarts
+	Secret []byte
+	// Mailer sends mail to users, mail is only logged when it's nil
+	Mailer mail.Mailer
+	// RequireVerified only lets users with a verified email submit resources
+	RequireVerified bool
+	// Tokens configures the JWTs /auth hands out, Secret is used when it has no secret of
+	// its own. Tokens are always checked against the store for revoked sessions and API
+	// keys are always looked up in it
+	Tokens auth.TokenOptions
+	// Providers are the OAuth providers users can sign in with
+	Providers []*auth.Provider
+}
+
 // New creates a new server from a store and populates the handler
-func New(sto store.Service) *Server {
-	s := &Server{sto: sto}
+func New(sto store.Service, options Options) *Server {
+	if len(options.Secret) == 0 {
+		options.Secret = make([]byte, 32)
+		if _, err := rand.Read(options.Secret); err != nil {
+			panic(err)
+		}
+	}
+	if options.Mailer == nil {
+		options.Mailer = mail.Log{}
+	}
+
+	if len(options.Tokens.Secret) == 0 {
+		options.Tokens.Secret = options.Secret
+	}
+
+	s := &Server{sto: sto, options: options}
+	s.options.Tokens.Revoked = s.revoked
+	s.options.Tokens.APIKey = s.apiKeyIdentity
+	s.tokens = auth.NewTokenService(s.options.Tokens)
+	s.authz = auth.NewAuthorizer(s.userRoles)
+	s.oauth = auth.NewOAuth(s.options.Secret, s.options.Providers...)
 
 	router := mux.NewRouter()
 
@@ -31,22 +76,198 @@ func New(sto store.Service) *Server {
 			"POST": http.HandlerFunc(s.auth),
 		})))
 
+	router.Handle("/auth/forgot", handlers.LoggingHandler(os.Stdout, allowedMethods(
+		[]string{"OPTIONS", "POST"},
+		handlers.MethodHandler{
+			"POST": http.HandlerFunc(s.forgotPassword),
+		})))
+
+	router.Handle("/auth/reset", handlers.LoggingHandler(os.Stdout, allowedMethods(
+		[]string{"OPTIONS", "POST"},
+		handlers.MethodHandler{
+			"POST": http.HandlerFunc(s.resetPassword),
+		})))
+
+	router.Handle("/auth/refresh", handlers.LoggingHandler(os.Stdout, allowedMethods(
+		[]string{"OPTIONS", "POST"},
+		handlers.MethodHandler{
+			"POST": http.HandlerFunc(s.refresh),
+		})))
+
+	router.Handle("/auth/logout", handlers.LoggingHandler(os.Stdout, allowedMethods(
+		[]string{"OPTIONS", "POST"},
+		handlers.MethodHandler{
+			"POST": http.HandlerFunc(s.logout),
+		})))
+
+	router.Handle("/auth/oauth/{provider}", handlers.LoggingHandler(os.Stdout, allowedMethods(
+		[]string{"OPTIONS", "GET"},
+		handlers.MethodHandler{
+			"GET": http.HandlerFunc(s.beginOAuth),
+		})))
+
+	router.Handle("/auth/oauth/{provider}/callback", handlers.LoggingHandler(os.Stdout, allowedMethods(
+		[]string{"OPTIONS", "GET"},
+		handlers.MethodHandler{
+			"GET": http.HandlerFunc(s.oauthCallback),
+		})))
+
 	router.Handle("/user", handlers.LoggingHandler(os.Stdout, allowedMethods(
 		[]string{"OPTIONS", "GET", "POST"},
 		handlers.MethodHandler{
-			"GET":  http.HandlerFunc(s.getUsers),
+			"GET":  s.authz.Require(auth.PermissionListUsers, http.HandlerFunc(s.getUsers)),
 			"POST": http.HandlerFunc(s.createUser), // created
 		})))
 
+	// registered ahead of /user/{id} so verify isn't taken for an ID
+	router.Handle("/user/verify", allowedMethods(
+		[]string{"OPTIONS", "POST"},
+		handlers.MethodHandler{
+			"POST": http.HandlerFunc(s.verifyUser),
+		}))
+
+	router.Handle("/user/verify/resend", allowedMethods(
+		[]string{"OPTIONS", "POST"},
+		handlers.MethodHandler{
+			"POST": http.HandlerFunc(s.resendVerification),
+		}))
+
 	router.Handle("/user/{id}", handlers.LoggingHandler(os.Stdout, allowedMethods(
 		[]string{"OPTIONS", "GET", "PUT", "PATCH", "DELETE"},
 		handlers.MethodHandler{
 			"GET": http.HandlerFunc(s.getUser), // created
 			//"PUT":    http.HandlerFunc(s.putUser),
 			"PATCH":  http.HandlerFunc(s.patchUser),
-			"DELETE": http.HandlerFunc(s.deleteUser),
+			"DELETE": s.authz.Require(auth.PermissionDeleteUsers, http.HandlerFunc(s.deleteUser)),
 		})))
 
+	router.Handle("/user/{id}/resource", allowedMethods(
+		[]string{"OPTIONS", "GET"},
+		handlers.MethodHandler{
+			"GET": http.HandlerFunc(s.getUserResources),
+		}))
+
+	router.Handle("/user/{id}/collection", allowedMethods(
+		[]string{"OPTIONS", "GET"},
+		handlers.MethodHandler{
+			"GET": http.HandlerFunc(s.getUserCollections),
+		}))
+
+	router.Handle("/user/{id}/curriculum", allowedMethods(
+		[]string{"OPTIONS", "GET"},
+		handlers.MethodHandler{
+			"GET": http.HandlerFunc(s.getUserCurriculums),
+		}))
+
+	router.Handle("/user/{id}/progress", allowedMethods(
+		[]string{"OPTIONS", "GET"},
+		handlers.MethodHandler{
+			"GET": http.HandlerFunc(s.getUserProgress),
+		}))
+
+	router.Handle("/user/{id}/bookmark", allowedMethods(
+		[]string{"OPTIONS", "GET"},
+		handlers.MethodHandler{
+			"GET": http.HandlerFunc(s.getBookmarks),
+		}))
+
+	router.Handle("/user/{id}/bookmark/{resourceID}", allowedMethods(
+		[]string{"OPTIONS", "PUT", "DELETE"},
+		handlers.MethodHandler{
+			"PUT":    http.HandlerFunc(s.putBookmark),
+			"DELETE": http.HandlerFunc(s.deleteBookmark),
+		}))
+
+	router.Handle("/user/{id}/session", allowedMethods(
+		[]string{"OPTIONS", "GET"},
+		handlers.MethodHandler{
+			"GET": http.HandlerFunc(s.getSessions),
+		}))
+
+	router.Handle("/user/{id}/session/{sid}", allowedMethods(
+		[]string{"OPTIONS", "DELETE"},
+		handlers.MethodHandler{
+			"DELETE": http.HandlerFunc(s.deleteSession),
+		}))
+
+	router.Handle("/user/{id}/apikey", allowedMethods(
+		[]string{"OPTIONS", "GET", "POST"},
+		handlers.MethodHandler{
+			"GET":  http.HandlerFunc(s.getAPIKeys),
+			"POST": http.HandlerFunc(s.createAPIKey),
+		}))
+
+	router.Handle("/user/{id}/apikey/{kid}", allowedMethods(
+		[]string{"OPTIONS", "GET", "PUT", "DELETE"},
+		handlers.MethodHandler{
+			"GET":    http.HandlerFunc(s.getAPIKey),
+			"PUT":    http.HandlerFunc(s.putAPIKey),
+			"DELETE": http.HandlerFunc(s.deleteAPIKey),
+		}))
+
+	router.Handle("/user/{id}/account", allowedMethods(
+		[]string{"OPTIONS", "GET"},
+		handlers.MethodHandler{
+			"GET": http.HandlerFunc(s.getAccounts),
+		}))
+
+	router.Handle("/user/{id}/account/{provider}", allowedMethods(
+		[]string{"OPTIONS", "DELETE"},
+		handlers.MethodHandler{
+			"DELETE": http.HandlerFunc(s.deleteAccount),
+		}))
+
+	router.Handle("/user/{id}/follow", allowedMethods(
+		[]string{"OPTIONS", "GET"},
+		handlers.MethodHandler{
+			"GET": http.HandlerFunc(s.getFollows),
+		}))
+
+	router.Handle("/user/{id}/follow/user/{userID}", allowedMethods(
+		[]string{"OPTIONS", "PUT", "DELETE"},
+		handlers.MethodHandler{
+			"PUT":    http.HandlerFunc(s.followUser),
+			"DELETE": http.HandlerFunc(s.unfollowUser),
+		}))
+
+	router.Handle("/user/{id}/follow/tag/{name}", allowedMethods(
+		[]string{"OPTIONS", "PUT", "DELETE"},
+		handlers.MethodHandler{
+			"PUT":    http.HandlerFunc(s.followTag),
+			"DELETE": http.HandlerFunc(s.unfollowTag),
+		}))
+
+	router.Handle("/notification", allowedMethods(
+		[]string{"OPTIONS", "GET"},
+		handlers.MethodHandler{
+			"GET": http.HandlerFunc(s.getNotifications),
+		}))
+
+	router.Handle("/notification/read", allowedMethods(
+		[]string{"OPTIONS", "PUT"},
+		handlers.MethodHandler{
+			"PUT": http.HandlerFunc(s.readAllNotifications),
+		}))
+
+	router.Handle("/notification/preference", allowedMethods(
+		[]string{"OPTIONS", "GET", "PUT"},
+		handlers.MethodHandler{
+			"GET": http.HandlerFunc(s.getNotificationPreferences),
+			"PUT": http.HandlerFunc(s.putNotificationPreferences),
+		}))
+
+	router.Handle("/notification/{id}/read", allowedMethods(
+		[]string{"OPTIONS", "PUT"},
+		handlers.MethodHandler{
+			"PUT": http.HandlerFunc(s.readNotification),
+		}))
+
+	router.Handle("/feed", allowedMethods(
+		[]string{"OPTIONS", "GET"},
+		handlers.MethodHandler{
+			"GET": http.HandlerFunc(s.getFeed),
+		}))
+
 	router.Handle("/valid/user", handlers.LoggingHandler(os.Stdout, allowedMethods(
 		[]string{"POST"},
 		handlers.MethodHandler{
@@ -67,10 +288,192 @@ func New(sto store.Service) *Server {
 			"GET":    http.HandlerFunc(s.getResource),
 			"PUT":    http.HandlerFunc(s.putResource),
 			"PATCH":  http.HandlerFunc(s.patchResource),
-			"DELETE": http.HandlerFunc(s.deleteResource),
+			"DELETE": s.authz.Require(auth.PermissionDeleteResources, http.HandlerFunc(s.deleteResource)),
+		}))
+
+	router.Handle("/resource/{id}/history", allowedMethods(
+		[]string{"OPTIONS", "GET"},
+		handlers.MethodHandler{
+			"GET": http.HandlerFunc(s.getResourceHistory),
+		}))
+
+	router.Handle("/resource/{id}/revert/{rev}", allowedMethods(
+		[]string{"OPTIONS", "POST"},
+		handlers.MethodHandler{
+			"POST": http.HandlerFunc(s.revertResource),
+		}))
+
+	router.Handle("/resource/{id}/tags", allowedMethods(
+		[]string{"OPTIONS", "PUT"},
+		handlers.MethodHandler{
+			"PUT": http.HandlerFunc(s.putResourceTags),
+		}))
+
+	router.Handle("/resource/{id}/review", allowedMethods(
+		[]string{"OPTIONS", "GET", "PUT", "DELETE"},
+		handlers.MethodHandler{
+			"GET":    http.HandlerFunc(s.getReviews),
+			"PUT":    http.HandlerFunc(s.putReview),
+			"DELETE": http.HandlerFunc(s.deleteReview),
+		}))
+
+	router.Handle("/resource/{id}/vote", allowedMethods(
+		[]string{"OPTIONS", "PUT", "DELETE"},
+		handlers.MethodHandler{
+			"PUT":    http.HandlerFunc(s.putVote),
+			"DELETE": http.HandlerFunc(s.deleteVote),
+		}))
+
+	router.Handle("/resource/{id}/comment", allowedMethods(
+		[]string{"OPTIONS", "GET", "POST"},
+		handlers.MethodHandler{
+			"GET":  http.HandlerFunc(s.getComments),
+			"POST": http.HandlerFunc(s.createComment),
+		}))
+
+	router.Handle("/resource/{id}/comment/{commentID}", allowedMethods(
+		[]string{"OPTIONS", "GET", "PUT", "DELETE"},
+		handlers.MethodHandler{
+			"GET":    http.HandlerFunc(s.getComment),
+			"PUT":    http.HandlerFunc(s.putComment),
+			"DELETE": http.HandlerFunc(s.deleteComment),
 		}))
 
-	s.handler = limitBody(defaultHeaders(router))
+	router.Handle("/collection", allowedMethods(
+		[]string{"OPTIONS", "GET", "POST"},
+		handlers.MethodHandler{
+			"GET":  http.HandlerFunc(s.getCollections),
+			"POST": http.HandlerFunc(s.createCollection),
+		}))
+
+	router.Handle("/collection/{id}", allowedMethods(
+		[]string{"OPTIONS", "GET", "PUT", "DELETE"},
+		handlers.MethodHandler{
+			"GET":    http.HandlerFunc(s.getCollection),
+			"PUT":    http.HandlerFunc(s.putCollection),
+			"DELETE": http.HandlerFunc(s.deleteCollection),
+		}))
+
+	router.Handle("/collection/{id}/item", allowedMethods(
+		[]string{"OPTIONS", "GET", "POST", "PUT"},
+		handlers.MethodHandler{
+			"GET":  http.HandlerFunc(s.getCollectionItems),
+			"POST": http.HandlerFunc(s.addCollectionItem),
+			"PUT":  http.HandlerFunc(s.putCollectionItems),
+		}))
+
+	router.Handle("/collection/{id}/item/{resourceID}", allowedMethods(
+		[]string{"OPTIONS", "PUT", "DELETE"},
+		handlers.MethodHandler{
+			"PUT":    http.HandlerFunc(s.putCollectionItem),
+			"DELETE": http.HandlerFunc(s.deleteCollectionItem),
+		}))
+
+	router.Handle("/curriculum", allowedMethods(
+		[]string{"OPTIONS", "GET", "POST"},
+		handlers.MethodHandler{
+			"GET":  http.HandlerFunc(s.getCurriculums),
+			"POST": http.HandlerFunc(s.createCurriculum),
+		}))
+
+	router.Handle("/curriculum/{id}", allowedMethods(
+		[]string{"OPTIONS", "GET", "PUT", "DELETE"},
+		handlers.MethodHandler{
+			"GET":    http.HandlerFunc(s.getCurriculum),
+			"PUT":    http.HandlerFunc(s.putCurriculum),
+			"DELETE": http.HandlerFunc(s.deleteCurriculum),
+		}))
+
+	router.Handle("/curriculum/{id}/section", allowedMethods(
+		[]string{"OPTIONS", "POST", "PUT"},
+		handlers.MethodHandler{
+			"POST": http.HandlerFunc(s.addCurriculumSection),
+			"PUT":  http.HandlerFunc(s.orderCurriculumSections),
+		}))
+
+	router.Handle("/curriculum/{id}/section/{sectionID}", allowedMethods(
+		[]string{"OPTIONS", "PUT", "DELETE"},
+		handlers.MethodHandler{
+			"PUT":    http.HandlerFunc(s.putCurriculumSection),
+			"DELETE": http.HandlerFunc(s.deleteCurriculumSection),
+		}))
+
+	router.Handle("/curriculum/{id}/section/{sectionID}/step", allowedMethods(
+		[]string{"OPTIONS", "POST", "PUT"},
+		handlers.MethodHandler{
+			"POST": http.HandlerFunc(s.addCurriculumStep),
+			"PUT":  http.HandlerFunc(s.orderCurriculumSteps),
+		}))
+
+	router.Handle("/curriculum/{id}/section/{sectionID}/step/{stepID}", allowedMethods(
+		[]string{"OPTIONS", "PUT", "DELETE"},
+		handlers.MethodHandler{
+			"PUT":    http.HandlerFunc(s.putCurriculumStep),
+			"DELETE": http.HandlerFunc(s.deleteCurriculumStep),
+		}))
+
+	router.Handle("/curriculum/{id}/progress", allowedMethods(
+		[]string{"OPTIONS", "GET"},
+		handlers.MethodHandler{
+			"GET": http.HandlerFunc(s.getCurriculumProgress),
+		}))
+
+	router.Handle("/curriculum/{id}/step/{stepID}/progress", allowedMethods(
+		[]string{"OPTIONS", "PUT"},
+		handlers.MethodHandler{
+			"PUT": http.HandlerFunc(s.putStepProgress),
+		}))
+
+	router.Handle("/moderation/resource", allowedMethods(
+		[]string{"OPTIONS", "GET"},
+		s.authz.Require(auth.PermissionApproveResources, handlers.MethodHandler{
+			"GET": http.HandlerFunc(s.getPendingResources),
+		})))
+
+	router.Handle("/moderation/resource/{id}/approve", allowedMethods(
+		[]string{"OPTIONS", "POST"},
+		s.authz.Require(auth.PermissionApproveResources, handlers.MethodHandler{
+			"POST": http.HandlerFunc(s.approveResource),
+		})))
+
+	router.Handle("/moderation/resource/{id}/reject", allowedMethods(
+		[]string{"OPTIONS", "POST"},
+		s.authz.Require(auth.PermissionApproveResources, handlers.MethodHandler{
+			"POST": http.HandlerFunc(s.rejectResource),
+		})))
+
+	router.Handle("/admin/user/{id}/role/{role}", handlers.LoggingHandler(os.Stdout, allowedMethods(
+		[]string{"OPTIONS", "PUT", "DELETE"},
+		s.authz.Require(auth.PermissionGrantRoles, handlers.MethodHandler{
+			"PUT":    http.HandlerFunc(s.grantRole),
+			"DELETE": http.HandlerFunc(s.revokeRole),
+		}))))
+
+	router.Handle("/search", allowedMethods(
+		[]string{"OPTIONS", "GET"},
+		handlers.MethodHandler{
+			"GET": http.HandlerFunc(s.search),
+		}))
+
+	router.Handle("/tag", allowedMethods(
+		[]string{"OPTIONS", "GET"},
+		handlers.MethodHandler{
+			"GET": http.HandlerFunc(s.getTags),
+		}))
+
+	router.Handle("/tag/{name}/resource", allowedMethods(
+		[]string{"OPTIONS", "GET"},
+		handlers.MethodHandler{
+			"GET": http.HandlerFunc(s.getTaggedResources),
+		}))
+
+	router.Handle("/tag/{name}/merge", allowedMethods(
+		[]string{"OPTIONS", "POST"},
+		handlers.MethodHandler{
+			"POST": s.authz.Require(auth.PermissionMergeTags, http.HandlerFunc(s.mergeTags)),
+		}))
+
+	s.handler = limitBody(defaultHeaders(s.tokens.CheckJWT(router)))
 
 	return s
 }
@@ -90,6 +493,22 @@ func commaify(ss []string) (out string) {
 	return
 }
 
+// pathID parses the named route variable as an ID
+func pathID(r *http.Request, name string) (int64, error) {
+	return strconv.ParseInt(mux.Vars(r)[name], 10, 64)
+}
+
+// storeError responds with a 404 for store.ErrNoResults and a 500 with the error otherwise
+func storeError(w http.ResponseWriter, err error) {
+	if err == store.ErrNoResults {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusInternalServerError)
+	respond.JSON(w, err)
+}
+
 func allowedMethods(methods []string, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Methods", commaify(methods))
@@ -100,23 +519,24 @@ func allowedMethods(methods []string, next http.Handler) http.Handler {
 
 // Auth Functions
 
+// auth trades a username and password for a JWT and the refresh token of a new session,
+// they're in the response and set as cookies so browsers send them along without any help
 func (s *Server) auth(w http.ResponseWriter, r *http.Request) {
 	// grab the username and password from the request
-	var user store.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+	var credentials store.User
+	if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
 		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
 
-	jwt, err := s.sto.Auth(user)
+	user, err := s.sto.Auth(credentials)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		respond.JSON(w, err)
 		return
 	}
 
-	respond.JSON(w, map[string]string{"jwt": jwt})
-	return
+	s.startSession(w, r, user)
 }
 
 // User Functions
@@ -147,9 +567,21 @@ func (s *Server) createUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	id, err := s.sto.CreateUser(user)
-	if err != nil {
+	if err == store.ErrUsernameExists {
+		w.WriteHeader(http.StatusConflict)
 		respond.JSON(w, err)
 		return
+	} else if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	// a failed email doesn't fail the signup, the user can ask for another one
+	user.ID = id
+	if user.Email != "" {
+		if _, err := s.sendVerification(user); err != nil {
+			log.Printf("sending verification to user %d: %v\n", id, err)
+		}
 	}
 
 	respond.JSON(w, map[string]int64{"id": id})
@@ -176,6 +608,26 @@ func (s *Server) getUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// everyone but the user themselves and admins only gets the public profile
+	if caller, ok := actingUser(r); !ok || caller != id {
+		allowed, err := s.authz.Allowed(r, auth.PermissionListUsers)
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		if !allowed {
+			respond.JSON(w, store.PublicUser{
+				ID:        user.ID,
+				Username:  user.Username,
+				FirstName: user.FirstName,
+				LastName:  user.LastName,
+				Verified:  user.Verified,
+			})
+			return
+		}
+	}
+
+	user.Password, user.PasswordHash = "", ""
 	respond.JSON(w, user)
 	return
 }
@@ -190,9 +642,20 @@ func (s *Server) patchUser(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+// deleteUser is only open to admins, see the user:delete permission
 func (s *Server) deleteUser(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
 
-	return
+	if err := s.sto.DeleteUser(id); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
 // Validation Functions
@@ -220,40 +683,306 @@ func (s *Server) checkUsername(w http.ResponseWriter, r *http.Request) {
 //
 //
 
+// getResources lists resources filtered by the query params, see store.ParseResourceQuery.
+// When the page is full the X-Next-Cursor header holds the cursor for the next one
 func (s *Server) getResources(w http.ResponseWriter, r *http.Request) {
+	query, err := store.ParseResourceQuery(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
 
-	return
+	resources, err := s.sto.GetResources(query)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		respond.JSON(w, err)
+		return
+	}
+	if resources == nil {
+		resources = []store.Resource{}
+	}
+
+	if err := s.markBookmarked(r, each(resources)...); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if len(resources) == query.Limit {
+		w.Header().Set("X-Next-Cursor", strconv.FormatInt(resources[len(resources)-1].ID, 10))
+	}
+
+	respond.JSON(w, resources)
 }
 
+// createResource submits a resource, it stays in the moderation queue until it's approved
 func (s *Server) createResource(w http.ResponseWriter, r *http.Request) {
+	var resource store.Resource
+	if err := json.NewDecoder(r.Body).Decode(&resource); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
 
-	return
+	if resource.Name == "" || resource.URL == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, errors.New("A name and url are required"))
+		return
+	}
+
+	if _, err := store.NormalizeTags(resource.Tags); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	// the submitter is whoever is signed in, never whoever the body names
+	if s.options.RequireVerified {
+		if !s.verifiedSubmitter(w, r, &resource) {
+			return
+		}
+	} else {
+		submitter, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+		resource.Submitter = submitter
+	}
+
+	id, err := s.sto.CreateResource(resource)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	respond.JSON(w, map[string]int64{"id": id})
 }
 
 func (s *Server) getResource(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
 
-	return
+	resource, err := s.sto.GetResource(id)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if err := s.markBookmarked(r, &resource); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	respond.JSON(w, resource)
 }
 
-func (s *Server) putResource(w http.ResponseWriter, r *http.Request) {
+// canEdit lets the submitter of a resource change it, anyone else needs permission. It
+// responds with a 403 when the editor can't
+func (s *Server) canEdit(w http.ResponseWriter, r *http.Request, editor int64, resource store.Resource, permission auth.Permission) bool {
+	if resource.Submitter != 0 && resource.Submitter == editor {
+		return true
+	}
 
-	return
+	allowed, err := s.authz.Allowed(r, permission)
+	if err != nil {
+		storeError(w, err)
+		return false
+	}
+	if !allowed {
+		w.WriteHeader(http.StatusForbidden)
+		respond.JSON(w, errors.New("Only the submitter, moderators and admins can change a resource"))
+		return false
+	}
+	return true
+}
+
+// putResource replaces the name, description and url of a resource, the acting user is
+// recorded as the editor of the revision and the resource has to be approved again
+func (s *Server) putResource(w http.ResponseWriter, r *http.Request) {
+	s.editResource(w, r, false)
 }
 
+// patchResource is putResource that keeps the current value of any field left empty
 func (s *Server) patchResource(w http.ResponseWriter, r *http.Request) {
+	s.editResource(w, r, true)
+}
 
-	return
+func (s *Server) editResource(w http.ResponseWriter, r *http.Request, partial bool) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	editor, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	var edit store.Resource
+	if err := json.NewDecoder(r.Body).Decode(&edit); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	resource, err := s.sto.GetResource(id)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if !s.canEdit(w, r, editor, resource, auth.PermissionEditResources) {
+		return
+	}
+
+	if partial {
+		if edit.Name == "" {
+			edit.Name = resource.Name
+		}
+		if edit.Description == "" {
+			edit.Description = resource.Description
+		}
+		if edit.URL == "" {
+			edit.URL = resource.URL
+		}
+	}
+	if edit.Name == "" || edit.URL == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, errors.New("A name and url are required"))
+		return
+	}
+	edit.ID = id
+
+	if err := s.sto.UpdateResource(edit, editor); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	respond.JSON(w, store.WithContent(resource, edit))
+}
+
+func (s *Server) getResourceHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	revisions, err := s.sto.GetRevisions(id)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if revisions == nil {
+		revisions = []store.Revision{}
+	}
+
+	respond.JSON(w, revisions)
 }
 
+// revertResource restores a resource to a revision, the acting user is recorded as the editor
+func (s *Server) revertResource(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	rev, err := pathID(r, "rev")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	editor, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	resource, err := s.sto.GetResource(id)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if !s.canEdit(w, r, editor, resource, auth.PermissionEditResources) {
+		return
+	}
+
+	if err := s.sto.RevertResource(id, rev, editor); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if resource, err = s.sto.GetResource(id); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if err := s.markBookmarked(r, &resource); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	respond.JSON(w, resource)
+}
+
+// deleteResource is only open to moderators and admins, see the resource:delete permission
 func (s *Server) deleteResource(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
 
-	return
+	if err := s.sto.DeleteResource(id); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Search Functions
+
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	query, err := store.ParseSearchQuery(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	results, err := s.sto.Search(query)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		respond.JSON(w, err)
+		return
+	}
+	if results == nil {
+		results = []store.SearchResult{}
+	}
+
+	resources := make([]*store.Resource, len(results))
+	for i := range results {
+		resources[i] = &results[i].Resource
+	}
+	if err := s.markBookmarked(r, resources...); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	respond.JSON(w, results)
 }
 
 func defaultHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Next-Cursor")
 
 		next.ServeHTTP(w, r)
 	})
@@ -0,0 +1,1529 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/auth"
+	"github.com/natethinks/instruu-api/internal/mail"
+	"github.com/natethinks/instruu-api/internal/store"
+	"github.com/natethinks/instruu-api/internal/store/memory"
+)
+
+// authorize signs req in as user with a token from s, user 0 leaves it anonymous
+func authorize(s *Server, req *http.Request, user int64) {
+	if user == 0 {
+		return
+	}
+
+	token, _, err := s.tokens.Issue(auth.Identity{ID: user}, time.Now())
+	if err != nil {
+		panic(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+}
+
+func TestCreateAndGetUser(t *testing.T) {
+	s := New(memory.New(), Options{})
+	ts := httptest.NewServer(s.handler)
+	defer ts.Close()
+
+	res, err := http.Post(ts.URL+"/user", "application/json",
+		strings.NewReader(`{"username":"nate","email":"nate@instruu.com","password":"testing"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	// get returns the raw fields of the user as caller sees them
+	get := func(caller int64) map[string]interface{} {
+		req, err := http.NewRequest("GET", ts.URL+"/user/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		authorize(s, req, caller)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", res.StatusCode)
+		}
+
+		var body struct {
+			Response map[string]interface{} `json:"response"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return body.Response
+	}
+
+	public := get(0)
+	if public["username"] != "nate" {
+		t.Errorf("unexpected user returned: %+v", public)
+	}
+	for _, field := range []string{"email", "roles", "notifications", "password", "PasswordHash"} {
+		if _, ok := public[field]; ok {
+			t.Errorf("expected %s to be left out of the public profile: %+v", field, public)
+		}
+	}
+
+	own := get(1)
+	if own["username"] != "nate" || own["email"] != "nate@instruu.com" || own["password"] != "" || own["PasswordHash"] != "" {
+		t.Errorf("unexpected user returned to its owner: %+v", own)
+	}
+}
+
+func TestCheckUsername(t *testing.T) {
+	ts := httptest.NewServer(New(memory.New(), Options{}).handler)
+	defer ts.Close()
+
+	check := func() int {
+		res, err := http.Post(ts.URL+"/valid/user", "application/json", strings.NewReader(`{"username":"nate"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	if code := check(); code != http.StatusOK {
+		t.Errorf("expected free username to return 200, got %d", code)
+	}
+
+	res, err := http.Post(ts.URL+"/user", "application/json", strings.NewReader(`{"username":"nate","password":"testing"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if code := check(); code != http.StatusConflict {
+		t.Errorf("expected taken username to return 409, got %d", code)
+	}
+
+	res, err = http.Post(ts.URL+"/user", "application/json", strings.NewReader(`{"username":"nate","password":"other"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusConflict {
+		t.Errorf("expected creating a taken username to return 409, got %d", res.StatusCode)
+	}
+}
+
+func TestPutResourceTags(t *testing.T) {
+	sto := memory.New()
+	s := New(sto, Options{})
+	ts := httptest.NewServer(s.handler)
+	defer ts.Close()
+
+	submitter, _ := sto.CreateUser(store.User{Username: "nate", Password: "testing"})
+	other, _ := sto.CreateUser(store.User{Username: "other", Password: "testing"})
+	id, err := sto.CreateResource(store.Resource{Name: "Go Tour", URL: "https://tour.golang.org", Tags: []string{"beginner"}, Submitter: submitter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sto.ApproveResource(id, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	put := func(user int64) int {
+		req, err := http.NewRequest("PUT", ts.URL+"/resource/"+strconv.FormatInt(id, 10)+"/tags",
+			strings.NewReader(`["Go", "tutorial"]`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		authorize(s, req, user)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+	if code := put(other); code != http.StatusForbidden {
+		t.Errorf("expected someone else to be forbidden, got %d", code)
+	}
+	if code := put(submitter); code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", code)
+	}
+
+	resource, err := sto.GetResource(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(resource.Tags, ",") != "go,tutorial" {
+		t.Errorf("unexpected tags after put: %v", resource.Tags)
+	}
+
+	res, err := http.Get(ts.URL + "/tag/go/resource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		Response []store.Resource `json:"response"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Response) != 1 || body.Response[0].ID != id {
+		t.Errorf("unexpected resources for tag: %+v", body.Response)
+	}
+}
+
+func TestGetResourcesRejectsUnknownParams(t *testing.T) {
+	ts := httptest.NewServer(New(memory.New(), Options{}).handler)
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/resource?order=random")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", res.StatusCode)
+	}
+}
+
+func TestEditResource(t *testing.T) {
+	sto := memory.New()
+	s := New(sto, Options{})
+	ts := httptest.NewServer(s.handler)
+	defer ts.Close()
+
+	do := func(method, path, body string, user int64) *http.Response {
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		authorize(s, req, user)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+
+	nate, _ := sto.CreateUser(store.User{Username: "nate", Password: "testing"})
+	other, _ := sto.CreateUser(store.User{Username: "other", Password: "testing"})
+	submission := `{"name": "Go Tour", "url": "https://tour.golang.org", "submitter": ` + strconv.FormatInt(other, 10) + `}`
+
+	if res := do("POST", "/resource", submission, 0); res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected anonymous submissions to be turned away, got %d", res.StatusCode)
+	}
+	if res := do("POST", "/resource", submission, nate); res.StatusCode != http.StatusCreated {
+		t.Fatalf("expected the submission to succeed, got %d", res.StatusCode)
+	}
+	resources, _ := sto.GetResources(store.ResourceQuery{})
+	if len(resources) != 1 || resources[0].Submitter != nate {
+		t.Fatalf("expected the acting user to be the submitter, got %+v", resources)
+	}
+	path := "/resource/" + strconv.FormatInt(resources[0].ID, 10)
+	sto.ApproveResource(resources[0].ID, 1)
+
+	edit := `{"name": "A Tour of Go", "url": "https://tour.golang.org", "submitter": ` + strconv.FormatInt(other, 10) + `}`
+	if res := do("PUT", path, edit, 0); res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected anonymous edits to be turned away, got %d", res.StatusCode)
+	}
+	if res := do("PUT", path, `{"description": "Learn Go"}`, nate); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a put without a name and url to be rejected, got %d", res.StatusCode)
+	}
+	if res := do("PUT", path, edit, nate); res.StatusCode != http.StatusOK {
+		t.Fatalf("expected the edit to succeed, got %d", res.StatusCode)
+	}
+	if resource, _ := sto.GetResource(resources[0].ID); resource.Approved {
+		t.Error("expected the edit to send the resource back to the moderation queue")
+	}
+	if res := do("POST", path+"/revert/1", `{"editor": `+strconv.FormatInt(other, 10)+`}`, nate); res.StatusCode != http.StatusOK {
+		t.Fatalf("expected the revert to succeed, got %d", res.StatusCode)
+	}
+
+	revisions, _ := sto.GetRevisions(resources[0].ID)
+	if len(revisions) != 3 {
+		t.Fatalf("expected the submission, edit and revert as revisions, got %+v", revisions)
+	}
+	for _, revision := range revisions {
+		if revision.Editor != nate {
+			t.Errorf("expected the acting user to be the editor, got %+v", revision)
+		}
+	}
+
+	// only the submitter, moderators and admins can change a resource
+	if res := do("PATCH", path, `{"name": "Tour"}`, other); res.StatusCode != http.StatusForbidden {
+		t.Errorf("expected someone else's edit to be forbidden, got %d", res.StatusCode)
+	}
+	if res := do("POST", path+"/revert/1", "", other); res.StatusCode != http.StatusForbidden {
+		t.Errorf("expected someone else's revert to be forbidden, got %d", res.StatusCode)
+	}
+	sto.SetRole(other, store.RoleModerator, true)
+	if res := do("PATCH", path, `{"name": "Tour"}`, other); res.StatusCode != http.StatusOK {
+		t.Errorf("expected a moderator to be able to edit, got %d", res.StatusCode)
+	}
+}
+
+func TestMergeTags(t *testing.T) {
+	sto := memory.New()
+	s := New(sto, Options{})
+	ts := httptest.NewServer(s.handler)
+	defer ts.Close()
+
+	moderator, _ := sto.CreateUser(store.User{Username: "mod", Password: "testing"})
+	sto.SetRole(moderator, store.RoleModerator, true)
+	nate, _ := sto.CreateUser(store.User{Username: "nate", Password: "testing"})
+	id, _ := sto.CreateResource(store.Resource{Name: "Go Tour", URL: "https://tour.golang.org", Tags: []string{"golang", "tutorial"}})
+	sto.FollowTag(nate, "golang")
+
+	merge := func(tag, body string, user int64) int {
+		req, err := http.NewRequest("POST", ts.URL+"/tag/"+tag+"/merge", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		authorize(s, req, user)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	if code := merge("golang", `{"into": "go"}`, nate); code != http.StatusForbidden {
+		t.Errorf("expected a regular user to be forbidden, got %d", code)
+	}
+	if code := merge("golang", `{"into": "Golang"}`, moderator); code != http.StatusBadRequest {
+		t.Errorf("expected merging a tag into itself to be rejected, got %d", code)
+	}
+	if code := merge("golang", `{"into": "go"}`, moderator); code != http.StatusNoContent {
+		t.Fatalf("expected the merge to succeed, got %d", code)
+	}
+	if code := merge("golang", `{"into": "go"}`, moderator); code != http.StatusNotFound {
+		t.Errorf("expected a tag nothing carries to be missing, got %d", code)
+	}
+
+	if resource, _ := sto.GetResource(id); strings.Join(resource.Tags, ",") != "go,tutorial" {
+		t.Errorf("unexpected tags after merging: %v", resource.Tags)
+	}
+	if follows, _ := sto.GetFollows(nate); strings.Join(follows.Tags, ",") != "go" {
+		t.Errorf("expected followers to follow the merged tag, got %v", follows.Tags)
+	}
+}
+
+func TestModerationQueue(t *testing.T) {
+	sto := memory.New()
+	s := New(sto, Options{})
+	ts := httptest.NewServer(s.handler)
+	defer ts.Close()
+
+	moderator, _ := sto.CreateUser(store.User{Username: "mod", Password: "testing"})
+	sto.SetRole(moderator, store.RoleModerator, true)
+	submitter, _ := sto.CreateUser(store.User{Username: "nate", Password: "testing"})
+	id, _ := sto.CreateResource(store.Resource{Name: "Go Tour", URL: "https://tour.golang.org", Submitter: submitter})
+
+	post := func(path string, user int64, body string) int {
+		req, err := http.NewRequest("POST", ts.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		authorize(s, req, user)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	path := "/moderation/resource/" + strconv.FormatInt(id, 10) + "/reject"
+	if code := post(path, submitter, `{"reason":"spam"}`); code != http.StatusForbidden {
+		t.Errorf("expected a submitter to be forbidden, got %d", code)
+	}
+	if code := post(path, moderator, `{"reason":"Duplicate of an existing resource"}`); code != http.StatusNoContent {
+		t.Fatalf("expected reject to succeed, got %d", code)
+	}
+
+	if resources, _ := sto.GetResources(store.ResourceQuery{Submitter: submitter}); len(resources) != 1 ||
+		resources[0].RejectionReason != "Duplicate of an existing resource" {
+		t.Errorf("rejection reason wasn't stored: %+v", resources)
+	}
+
+	res, err := http.Get(ts.URL + "/resource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		Response []store.Resource `json:"response"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Response) != 0 {
+		t.Errorf("unapproved resource was listed publicly: %+v", body.Response)
+	}
+}
+
+func TestCollectionOwnership(t *testing.T) {
+	sto := memory.New()
+	s := New(sto, Options{})
+	ts := httptest.NewServer(s.handler)
+	defer ts.Close()
+
+	owner, _ := sto.CreateUser(store.User{Username: "nate", Password: "testing"})
+	other, _ := sto.CreateUser(store.User{Username: "sam", Password: "testing"})
+
+	do := func(method, path string, user int64, body string) *http.Response {
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user != 0 {
+			authorize(s, req, user)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+
+	if res := do("POST", "/collection", 0, `{"title":"Learning Go"}`); res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected an anonymous create to return 401, got %d", res.StatusCode)
+	}
+	if res := do("POST", "/collection", owner, `{"title":"Learning Go","visibility":"unlisted"}`); res.StatusCode != http.StatusCreated {
+		t.Fatalf("expected create to return 201, got %d", res.StatusCode)
+	}
+
+	if res := do("PUT", "/collection/1", other, `{"title":"Mine now"}`); res.StatusCode != http.StatusForbidden {
+		t.Errorf("expected another user's edit to return 403, got %d", res.StatusCode)
+	}
+	if res := do("DELETE", "/collection/1", other, ""); res.StatusCode != http.StatusForbidden {
+		t.Errorf("expected another user's delete to return 403, got %d", res.StatusCode)
+	}
+	if res := do("GET", "/collection/1", other, ""); res.StatusCode != http.StatusOK {
+		t.Errorf("expected an unlisted collection to be readable, got %d", res.StatusCode)
+	}
+
+	if res := do("PUT", "/collection/1", owner, `{"title":"Learning Go","visibility":"private"}`); res.StatusCode != http.StatusOK {
+		t.Fatalf("expected the owner's edit to succeed, got %d", res.StatusCode)
+	}
+	if res := do("GET", "/collection/1", other, ""); res.StatusCode != http.StatusNotFound {
+		t.Errorf("expected a private collection to be hidden, got %d", res.StatusCode)
+	}
+	if res := do("GET", "/collection/1", owner, ""); res.StatusCode != http.StatusOK {
+		t.Errorf("expected the owner to see their private collection, got %d", res.StatusCode)
+	}
+}
+
+func TestCurriculumTree(t *testing.T) {
+	sto := memory.New()
+	s := New(sto, Options{})
+	ts := httptest.NewServer(s.handler)
+	defer ts.Close()
+
+	owner, _ := sto.CreateUser(store.User{Username: "nate", Password: "testing"})
+	other, _ := sto.CreateUser(store.User{Username: "sam", Password: "testing"})
+	resource, _ := sto.CreateResource(store.Resource{Name: "Go Tour", URL: "https://tour.golang.org"})
+	id, _ := sto.CreateCurriculum(store.Curriculum{Owner: owner, Title: "Learning Go"})
+	first, _ := sto.AddCurriculumSection(id, store.Section{Title: "Basics"})
+	second, _ := sto.AddCurriculumSection(id, store.Section{Title: "Next steps"})
+
+	do := func(method, path string, user int64, body string) int {
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		authorize(s, req, user)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	path := "/curriculum/" + strconv.FormatInt(id, 10)
+	stepPath := path + "/section/" + strconv.FormatInt(second, 10) + "/step"
+	step := `{"resource":` + strconv.FormatInt(resource, 10) + `,"estimatedMinutes":45}`
+
+	if code := do("POST", stepPath, other, step); code != http.StatusForbidden {
+		t.Errorf("expected another user's step to return 403, got %d", code)
+	}
+	if code := do("POST", stepPath, owner, step); code != http.StatusCreated {
+		t.Fatalf("expected the owner's step to return 201, got %d", code)
+	}
+	if code := do("PUT", path+"/section", owner, `[1]`); code != http.StatusBadRequest {
+		t.Errorf("expected an incomplete order to return 400, got %d", code)
+	}
+	order := "[" + strconv.FormatInt(second, 10) + "," + strconv.FormatInt(first, 10) + "]"
+	if code := do("PUT", path+"/section", owner, order); code != http.StatusNoContent {
+		t.Fatalf("expected reorder to return 204, got %d", code)
+	}
+
+	res, err := http.Get(ts.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		Response store.Curriculum `json:"response"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	sections := body.Response.Sections
+	if len(sections) != 2 || sections[0].ID != second || len(sections[0].Steps) != 1 || body.Response.EstimatedMinutes != 45 {
+		t.Errorf("unexpected curriculum tree: %+v", body.Response)
+	}
+}
+
+func TestUserProgress(t *testing.T) {
+	sto := memory.New()
+	s := New(sto, Options{})
+	ts := httptest.NewServer(s.handler)
+	defer ts.Close()
+
+	learner, _ := sto.CreateUser(store.User{Username: "nate", Password: "testing"})
+	other, _ := sto.CreateUser(store.User{Username: "sam", Password: "testing"})
+	resource, _ := sto.CreateResource(store.Resource{Name: "Go Tour", URL: "https://tour.golang.org"})
+	id, _ := sto.CreateCurriculum(store.Curriculum{Owner: other, Title: "Learning Go"})
+	section, _ := sto.AddCurriculumSection(id, store.Section{Title: "Basics"})
+	first, _ := sto.AddCurriculumStep(id, section, store.Step{Resource: resource})
+	sto.AddCurriculumStep(id, section, store.Step{Resource: resource})
+
+	do := func(method, path string, user int64, body string) *http.Response {
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		authorize(s, req, user)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	stepPath := "/curriculum/" + strconv.FormatInt(id, 10) + "/step/" + strconv.FormatInt(first, 10) + "/progress"
+	res := do("PUT", stepPath, learner, `{"status":"done"}`)
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected an unknown status to return 400, got %d", res.StatusCode)
+	}
+	res = do("PUT", stepPath, learner, `{"status":"completed"}`)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected marking a step to return 200, got %d", res.StatusCode)
+	}
+
+	progressPath := "/user/" + strconv.FormatInt(learner, 10) + "/progress"
+	res = do("GET", progressPath, other, "")
+	res.Body.Close()
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("expected another user's progress to return 403, got %d", res.StatusCode)
+	}
+
+	res = do("GET", progressPath, learner, "")
+	defer res.Body.Close()
+
+	var body struct {
+		Response []store.Progress `json:"response"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Response) != 1 || body.Response[0].Percent != 50 || body.Response[0].Sections[0].Completed != 1 {
+		t.Errorf("unexpected progress summary: %+v", body.Response)
+	}
+}
+
+func TestPutReview(t *testing.T) {
+	sto := memory.New()
+	s := New(sto, Options{})
+	ts := httptest.NewServer(s.handler)
+	defer ts.Close()
+
+	reviewer, _ := sto.CreateUser(store.User{Username: "nate", Password: "testing"})
+	id, _ := sto.CreateResource(store.Resource{Name: "Go Tour", URL: "https://tour.golang.org"})
+	sto.ApproveResource(id, 1)
+
+	put := func(user int64, body string) int {
+		req, err := http.NewRequest("PUT", ts.URL+"/resource/"+strconv.FormatInt(id, 10)+"/review", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user != 0 {
+			authorize(s, req, user)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	if code := put(0, `{"rating":5}`); code != http.StatusUnauthorized {
+		t.Errorf("expected an anonymous review to return 401, got %d", code)
+	}
+	if code := put(reviewer, `{"rating":6}`); code != http.StatusBadRequest {
+		t.Errorf("expected a 6 star rating to return 400, got %d", code)
+	}
+	if code := put(reviewer, `{"rating":4,"text":"A great start"}`); code != http.StatusOK {
+		t.Fatalf("expected the review to be saved, got %d", code)
+	}
+
+	res, err := http.Get(ts.URL + "/resource/" + strconv.FormatInt(id, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		Response store.Resource `json:"response"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Response.Rating != 4 || body.Response.RatingCount != 1 {
+		t.Errorf("unexpected rating on the resource: %+v", body.Response)
+	}
+}
+
+func TestSortHot(t *testing.T) {
+	sto := memory.New()
+	s := New(sto, Options{})
+	ts := httptest.NewServer(s.handler)
+	defer ts.Close()
+
+	first, _ := sto.CreateResource(store.Resource{Name: "Go Tour", URL: "https://tour.golang.org"})
+	second, _ := sto.CreateResource(store.Resource{Name: "Effective Go", URL: "https://golang.org/doc/effective_go"})
+	sto.ApproveResource(first, 1)
+	sto.ApproveResource(second, 1)
+	sto.CreateUser(store.User{Username: "nate", Password: "testing"})
+	sto.CreateUser(store.User{Username: "sam", Password: "testing"})
+
+	for voter := int64(1); voter <= 2; voter++ {
+		req, err := http.NewRequest("PUT", ts.URL+"/resource/"+strconv.FormatInt(first, 10)+"/vote", strings.NewReader(`{"value":1}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		authorize(s, req, voter)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusNoContent {
+			t.Fatalf("expected vote to return 204, got %d", res.StatusCode)
+		}
+	}
+
+	res, err := http.Get(ts.URL + "/resource?sort=hot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		Response []store.Resource `json:"response"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Response) != 2 || body.Response[0].ID != first || body.Response[0].Score != 2 {
+		t.Errorf("expected the upvoted resource first: %+v", body.Response)
+	}
+}
+
+func TestCommentThread(t *testing.T) {
+	sto := memory.New()
+	s := New(sto, Options{})
+	ts := httptest.NewServer(s.handler)
+	defer ts.Close()
+
+	author, _ := sto.CreateUser(store.User{Username: "nate", Password: "testing"})
+	other, _ := sto.CreateUser(store.User{Username: "sam", Password: "testing"})
+	id, _ := sto.CreateResource(store.Resource{Name: "Go Tour", URL: "https://tour.golang.org"})
+	sto.ApproveResource(id, 1)
+
+	do := func(method, path string, user int64, body string) int {
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		authorize(s, req, user)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	path := "/resource/" + strconv.FormatInt(id, 10) + "/comment"
+	if code := do("POST", path, author, `{"body":"Great intro"}`); code != http.StatusCreated {
+		t.Fatalf("expected comment to return 201, got %d", code)
+	}
+	if code := do("POST", path, other, `{"body":"Agreed","parent":1}`); code != http.StatusCreated {
+		t.Fatalf("expected reply to return 201, got %d", code)
+	}
+	if code := do("PUT", path+"/1", other, `{"body":"Not mine"}`); code != http.StatusForbidden {
+		t.Errorf("expected editing another user's comment to return 403, got %d", code)
+	}
+	if code := do("DELETE", path+"/1", author, ""); code != http.StatusNoContent {
+		t.Fatalf("expected delete to return 204, got %d", code)
+	}
+	if code := do("POST", path, other, `{"body":"Too late","parent":1}`); code != http.StatusBadRequest {
+		t.Errorf("expected a reply to a deleted comment to return 400, got %d", code)
+	}
+
+	res, err := http.Get(ts.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		Response []store.Comment `json:"response"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Response) != 1 || body.Response[0].Body != store.DeletedCommentBody ||
+		len(body.Response[0].Replies) != 1 || body.Response[0].Replies[0].Body != "Agreed" {
+		t.Errorf("unexpected thread: %+v", body.Response)
+	}
+}
+
+func TestBookmarks(t *testing.T) {
+	sto := memory.New()
+	s := New(sto, Options{})
+	ts := httptest.NewServer(s.handler)
+	defer ts.Close()
+
+	user, _ := sto.CreateUser(store.User{Username: "nate", Password: "testing"})
+	other, _ := sto.CreateUser(store.User{Username: "sam", Password: "testing"})
+	id, _ := sto.CreateResource(store.Resource{Name: "Go Tour", URL: "https://tour.golang.org"})
+	sto.ApproveResource(id, 1)
+
+	do := func(method, path string, caller int64) *http.Response {
+		req, err := http.NewRequest(method, ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		authorize(s, req, caller)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	path := "/user/" + strconv.FormatInt(user, 10) + "/bookmark"
+	res := do("PUT", path+"/"+strconv.FormatInt(id, 10), other)
+	res.Body.Close()
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("expected bookmarking for another user to return 403, got %d", res.StatusCode)
+	}
+	res = do("PUT", path+"/"+strconv.FormatInt(id, 10), user)
+	res.Body.Close()
+	if res.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected bookmark to return 204, got %d", res.StatusCode)
+	}
+
+	decode := func(res *http.Response) store.Resource {
+		defer res.Body.Close()
+		var body struct {
+			Response store.Resource `json:"response"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return body.Response
+	}
+
+	resourcePath := "/resource/" + strconv.FormatInt(id, 10)
+	if resource := decode(do("GET", resourcePath, user)); !resource.Bookmarked || resource.BookmarkCount != 1 {
+		t.Errorf("expected the resource to be bookmarked for its saver: %+v", resource)
+	}
+	if resource := decode(do("GET", resourcePath, other)); resource.Bookmarked {
+		t.Errorf("expected the resource not to be bookmarked for another user: %+v", resource)
+	}
+
+	res = do("GET", path, user)
+	defer res.Body.Close()
+	var body struct {
+		Response []store.Resource `json:"response"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Response) != 1 || body.Response[0].ID != id {
+		t.Errorf("unexpected bookmarks: %+v", body.Response)
+	}
+}
+
+func TestFeed(t *testing.T) {
+	sto := memory.New()
+	s := New(sto, Options{})
+	ts := httptest.NewServer(s.handler)
+	defer ts.Close()
+
+	follower, _ := sto.CreateUser(store.User{Username: "nate", Password: "testing"})
+	author, _ := sto.CreateUser(store.User{Username: "sam", Password: "testing"})
+
+	do := func(method, path string, caller int64) *http.Response {
+		req, err := http.NewRequest(method, ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		authorize(s, req, caller)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	path := "/user/" + strconv.FormatInt(follower, 10) + "/follow/user/"
+	res := do("PUT", path+strconv.FormatInt(follower, 10), follower)
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected following yourself to return 400, got %d", res.StatusCode)
+	}
+	res = do("PUT", path+strconv.FormatInt(author, 10), author)
+	res.Body.Close()
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("expected following on behalf of someone else to return 403, got %d", res.StatusCode)
+	}
+	res = do("PUT", path+strconv.FormatInt(author, 10), follower)
+	res.Body.Close()
+	if res.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected follow to return 204, got %d", res.StatusCode)
+	}
+
+	for _, title := range []string{"First", "Second", "Third"} {
+		sto.CreateCollection(store.Collection{Owner: author, Title: title, Visibility: store.VisibilityPublic})
+	}
+
+	res = do("GET", "/feed?limit=2", follower)
+	defer res.Body.Close()
+	var body struct {
+		Response []store.Event `json:"response"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Response) != 2 || body.Response[0].Subject != 3 || body.Response[1].Subject != 2 {
+		t.Errorf("unexpected feed: %+v", body.Response)
+	}
+	if cursor := res.Header.Get("X-Next-Cursor"); cursor != strconv.FormatInt(body.Response[1].ID, 10) {
+		t.Errorf("expected the next cursor to be the last event, got %q", cursor)
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	sto := memory.New()
+	s := New(sto, Options{})
+	ts := httptest.NewServer(s.handler)
+	defer ts.Close()
+
+	submitter, _ := sto.CreateUser(store.User{Username: "nate", Password: "testing"})
+	commenter, _ := sto.CreateUser(store.User{Username: "sam", Password: "testing"})
+	id, _ := sto.CreateResource(store.Resource{Name: "Go Tour", URL: "https://tour.golang.org", Submitter: submitter})
+	sto.ApproveResource(id, 1)
+
+	do := func(method, path, body string, caller int64) *http.Response {
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		authorize(s, req, caller)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	notifications := func() store.Notifications {
+		res := do("GET", "/notification", "", submitter)
+		defer res.Body.Close()
+		var body struct {
+			Response store.Notifications `json:"response"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return body.Response
+	}
+
+	commentPath := "/resource/" + strconv.FormatInt(id, 10) + "/comment"
+	do("POST", commentPath, `{"body": "Great tour"}`, commenter).Body.Close()
+	// commenting on your own resource isn't worth a notification
+	do("POST", commentPath, `{"body": "Thanks"}`, submitter).Body.Close()
+
+	got := notifications()
+	if got.Unread != 1 || len(got.Notifications) != 1 {
+		t.Fatalf("unexpected notifications: %+v", got)
+	}
+	if n := got.Notifications[0]; n.Type != store.NotificationComment || n.Actor != commenter || n.Resource != id {
+		t.Errorf("unexpected notification: %+v", n)
+	}
+
+	res := do("PUT", "/notification/preference", `{"comment": false}`, submitter)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected preferences to be saved, got %d", res.StatusCode)
+	}
+	do("POST", commentPath, `{"body": "Still great"}`, commenter).Body.Close()
+
+	res = do("PUT", "/notification/read", "", submitter)
+	res.Body.Close()
+	if res.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected mark all read to return 204, got %d", res.StatusCode)
+	}
+
+	if got := notifications(); got.Unread != 0 || len(got.Notifications) != 1 || !got.Notifications[0].Read {
+		t.Errorf("unexpected notifications: %+v", got)
+	}
+
+	// following again doesn't notify again
+	followPath := "/user/" + strconv.FormatInt(commenter, 10) + "/follow/user/" + strconv.FormatInt(submitter, 10)
+	for i := 0; i < 2; i++ {
+		res := do("PUT", followPath, "", commenter)
+		res.Body.Close()
+		if res.StatusCode != http.StatusNoContent {
+			t.Fatalf("expected follow to return 204, got %d", res.StatusCode)
+		}
+	}
+	if got := notifications(); got.Unread != 1 || got.Notifications[0].Type != store.NotificationFollow {
+		t.Errorf("expected a single follow notification, got %+v", got)
+	}
+
+	// neither does approving again
+	moderator, _ := sto.CreateUser(store.User{Username: "mod", Password: "testing"})
+	sto.SetRole(moderator, store.RoleModerator, true)
+	pending, _ := sto.CreateResource(store.Resource{Name: "Go Blog", URL: "https://blog.golang.org", Submitter: submitter})
+	approvePath := "/moderation/resource/" + strconv.FormatInt(pending, 10) + "/approve"
+	for i := 0; i < 2; i++ {
+		res := do("POST", approvePath, "", moderator)
+		res.Body.Close()
+		if res.StatusCode != http.StatusNoContent {
+			t.Fatalf("expected approve to return 204, got %d", res.StatusCode)
+		}
+	}
+	if got := notifications(); got.Unread != 2 || got.Notifications[0].Type != store.NotificationApproved {
+		t.Errorf("expected a single approval notification, got %+v", got)
+	}
+}
+
+func TestVerifyUser(t *testing.T) {
+	sto := memory.New()
+	mailer := &mail.Capture{}
+	s := New(sto, Options{Mailer: mailer, RequireVerified: true})
+	ts := httptest.NewServer(s.handler)
+	defer ts.Close()
+
+	do := func(method, path, body string, caller int64) *http.Response {
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		authorize(s, req, caller)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+
+	do("POST", "/user", `{"username": "nate", "email": "nate@example.com", "password": "testing", "verified": true}`, 0)
+	if len(mailer.Messages()) != 1 {
+		t.Fatalf("expected a verification email on signup, got %d", len(mailer.Messages()))
+	}
+
+	resource := `{"name": "Go Tour", "url": "https://tour.golang.org"}`
+	if res := do("POST", "/resource", resource, 1); res.StatusCode != http.StatusForbidden {
+		t.Errorf("expected unverified users to be stopped from submitting, got %d", res.StatusCode)
+	}
+	if res := do("POST", "/user/verify/resend", "", 1); res.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected an immediate resend to be throttled, got %d", res.StatusCode)
+	}
+
+	lines := strings.Split(strings.TrimSpace(mailer.Messages()[0].Text), "\n")
+	token := lines[len(lines)-1]
+	if res := do("POST", "/user/verify", `{"token": "`+token+`x"}`, 0); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a tampered token to be rejected, got %d", res.StatusCode)
+	}
+	if res := do("POST", "/user/verify", `{"token": "`+token+`"}`, 0); res.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected verification to succeed, got %d", res.StatusCode)
+	}
+
+	if user, _ := sto.GetUser(1); !user.Verified {
+		t.Error("expected the user to be verified")
+	}
+	if res := do("POST", "/resource", resource, 1); res.StatusCode != http.StatusCreated {
+		t.Errorf("expected verified users to be able to submit, got %d", res.StatusCode)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	sto := memory.New()
+	mailer := &mail.Capture{}
+	ts := httptest.NewServer(New(sto, Options{Mailer: mailer}).handler)
+	defer ts.Close()
+
+	post := func(path, body string) *http.Response {
+		res, err := http.Post(ts.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+
+	sto.CreateUser(store.User{Username: "nate", Email: "nate@example.com", Password: "testing"})
+
+	if res := post("/auth/forgot", `{"email": "nobody@example.com"}`); res.StatusCode != http.StatusAccepted {
+		t.Errorf("expected unknown emails to get a 202 too, got %d", res.StatusCode)
+	}
+	if len(mailer.Messages()) != 0 {
+		t.Fatalf("expected no mail for an unknown email, got %d", len(mailer.Messages()))
+	}
+	if res := post("/auth/forgot", `{"email": "Nate@Example.com"}`); res.StatusCode != http.StatusAccepted {
+		t.Errorf("expected a 202, got %d", res.StatusCode)
+	}
+	if len(mailer.Messages()) != 1 {
+		t.Fatalf("expected a reset email, got %d", len(mailer.Messages()))
+	}
+
+	lines := strings.Split(strings.TrimSpace(mailer.Messages()[0].Text), "\n")
+	token := lines[len(lines)-1]
+	if res := post("/auth/reset", `{"token": "`+token+`", "password": "short"}`); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a short password to be rejected, got %d", res.StatusCode)
+	}
+	if res := post("/auth/reset", `{"token": "`+token+`x", "password": "a new password"}`); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected an unknown token to be rejected, got %d", res.StatusCode)
+	}
+	if res := post("/auth/reset", `{"token": "`+token+`", "password": "a new password"}`); res.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected the reset to succeed, got %d", res.StatusCode)
+	}
+	if res := post("/auth/reset", `{"token": "`+token+`", "password": "another password"}`); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected the token to only work once, got %d", res.StatusCode)
+	}
+
+	if _, err := sto.Auth(store.User{Username: "nate", Password: "testing"}); err == nil {
+		t.Error("expected the old password to stop working")
+	}
+	if _, err := sto.Auth(store.User{Username: "nate", Password: "a new password"}); err != nil {
+		t.Errorf("expected the new password to work, got %v", err)
+	}
+	if revoked, _ := sto.SessionsRevokedAt(1); revoked.IsZero() {
+		t.Error("expected the reset to revoke the user's sessions")
+	}
+}
+
+func TestAuth(t *testing.T) {
+	sto := memory.New()
+	s := New(sto, Options{})
+	ts := httptest.NewServer(s.handler)
+	defer ts.Close()
+
+	id, _ := sto.CreateUser(store.User{Username: "nate", Password: "testing"})
+
+	res, err := http.Post(ts.URL+"/auth", "application/json", strings.NewReader(`{"username": "nate", "password": "testing"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		Response struct {
+			JWT string `json:"jwt"`
+		} `json:"response"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	cookies := res.Cookies()
+	if len(cookies) != 2 || cookies[0].Name != "auth" || cookies[0].Value != body.Response.JWT || body.Response.JWT == "" {
+		t.Fatalf("expected the token in the body and the auth cookie, got %q and %+v", body.Response.JWT, cookies)
+	}
+
+	get := func(token string) int {
+		req, err := http.NewRequest("GET", ts.URL+"/notification", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.AddCookie(&http.Cookie{Name: "auth", Value: token})
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	if code := get(body.Response.JWT); code != http.StatusOK {
+		t.Errorf("expected the token to authenticate, got %d", code)
+	}
+	if code := get(""); code != http.StatusUnauthorized {
+		t.Errorf("expected a 401 without a token, got %d", code)
+	}
+
+	// a token from before the password was reset stops working
+	old, _, _ := s.tokens.Issue(auth.Identity{ID: id, Username: "nate"}, time.Now().Add(-time.Minute))
+	sto.CreatePasswordReset(store.PasswordReset{TokenHash: "hash", User: id, ExpiresAt: time.Now().Add(time.Hour)})
+	if _, err := sto.ResetPassword("hash", "new hash"); err != nil {
+		t.Fatal(err)
+	}
+	if code := get(old); code != http.StatusUnauthorized {
+		t.Errorf("expected a token issued before the reset to be turned away, got %d", code)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	sto := memory.New()
+	ts := httptest.NewServer(New(sto, Options{}).handler)
+	defer ts.Close()
+
+	sto.CreateUser(store.User{Username: "nate", Password: "testing"})
+	sto.CreateUser(store.User{Username: "sam", Password: "testing"})
+
+	type tokens struct {
+		JWT          string `json:"jwt"`
+		RefreshToken string `json:"refreshToken"`
+		Session      int64  `json:"session"`
+	}
+	do := func(method, path, body, jwt string, v interface{}) int {
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if jwt != "" {
+			req.Header.Set("Authorization", "Bearer "+jwt)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		if v != nil && res.StatusCode == http.StatusOK {
+			body := struct {
+				Response interface{} `json:"response"`
+			}{v}
+			if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return res.StatusCode
+	}
+	signIn := func(username string) (signedIn tokens) {
+		if code := do("POST", "/auth", `{"username": "`+username+`", "password": "testing"}`, "", &signedIn); code != http.StatusOK {
+			t.Fatalf("expected %s to sign in, got %d", username, code)
+		}
+		return signedIn
+	}
+
+	laptop, phone := signIn("nate"), signIn("nate")
+
+	var sessions []store.Session
+	if code := do("GET", "/user/1/session", "", laptop.JWT, &sessions); code != http.StatusOK || len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %d %+v", code, sessions)
+	}
+	if sessions[0].ID != phone.Session || sessions[0].Current || !sessions[1].Current {
+		t.Errorf("expected the laptop's session to be marked current: %+v", sessions)
+	}
+	if code := do("GET", "/user/1/session", "", signIn("sam").JWT, nil); code != http.StatusForbidden {
+		t.Errorf("expected other users to be forbidden from listing sessions, got %d", code)
+	}
+
+	// refreshing rotates the refresh token, using the old one again revokes the session
+	var refreshed tokens
+	if code := do("POST", "/auth/refresh", `{"refreshToken": "`+laptop.RefreshToken+`"}`, "", &refreshed); code != http.StatusOK {
+		t.Fatalf("expected the refresh to succeed, got %d", code)
+	}
+	if refreshed.Session != laptop.Session || refreshed.RefreshToken == laptop.RefreshToken {
+		t.Errorf("expected a new refresh token for the same session: %+v", refreshed)
+	}
+	if code := do("POST", "/auth/refresh", `{"refreshToken": "`+laptop.RefreshToken+`"}`, "", nil); code != http.StatusUnauthorized {
+		t.Errorf("expected a reused refresh token to be turned away, got %d", code)
+	}
+	if code := do("POST", "/auth/refresh", `{"refreshToken": "`+refreshed.RefreshToken+`"}`, "", nil); code != http.StatusUnauthorized {
+		t.Errorf("expected the reuse to revoke the session, got %d", code)
+	}
+	if code := do("GET", "/notification", "", refreshed.JWT, nil); code != http.StatusUnauthorized {
+		t.Errorf("expected access tokens of a revoked session to stop working, got %d", code)
+	}
+
+	// revoking a session from another device
+	tablet := signIn("nate")
+	if code := do("DELETE", "/user/1/session/"+strconv.FormatInt(phone.Session, 10), "", tablet.JWT, nil); code != http.StatusNoContent {
+		t.Errorf("expected the phone's session to be revoked, got %d", code)
+	}
+	if code := do("GET", "/notification", "", phone.JWT, nil); code != http.StatusUnauthorized {
+		t.Errorf("expected the phone to be signed out, got %d", code)
+	}
+
+	if code := do("POST", "/auth/logout", "", tablet.JWT, nil); code != http.StatusNoContent {
+		t.Errorf("expected logout to succeed, got %d", code)
+	}
+	if code := do("POST", "/auth/refresh", `{"refreshToken": "`+tablet.RefreshToken+`"}`, "", nil); code != http.StatusUnauthorized {
+		t.Errorf("expected logging out to end the session, got %d", code)
+	}
+}
+
+func TestRoles(t *testing.T) {
+	sto := memory.New()
+	s := New(sto, Options{})
+	ts := httptest.NewServer(s.handler)
+	defer ts.Close()
+
+	admin, _ := sto.CreateUser(store.User{Username: "admin", Password: "testing"})
+	sto.SetRole(admin, store.RoleAdmin, true)
+	user, _ := sto.CreateUser(store.User{Username: "nate", Password: "testing"})
+
+	do := func(method, path string, as int64) int {
+		req, err := http.NewRequest(method, ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		authorize(s, req, as)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	for as, expected := range map[int64]int{0: http.StatusUnauthorized, user: http.StatusForbidden, admin: http.StatusOK} {
+		if code := do("GET", "/user", as); code != expected {
+			t.Errorf("expected user %d listing users to get %d, got %d", as, expected, code)
+		}
+	}
+
+	rolePath := "/admin/user/" + strconv.FormatInt(user, 10) + "/role/"
+	if code := do("PUT", rolePath+store.RoleModerator, user); code != http.StatusForbidden {
+		t.Errorf("expected users to be forbidden from granting themselves roles, got %d", code)
+	}
+	if code := do("PUT", rolePath+"owner", admin); code != http.StatusBadRequest {
+		t.Errorf("expected an unknown role to be rejected, got %d", code)
+	}
+	if code := do("PUT", rolePath+store.RoleModerator, admin); code != http.StatusNoContent {
+		t.Fatalf("expected the admin to grant the moderator role, got %d", code)
+	}
+	if code := do("GET", "/moderation/resource", user); code != http.StatusOK {
+		t.Errorf("expected the new moderator to see the queue, got %d", code)
+	}
+	if code := do("GET", "/user", user); code != http.StatusForbidden {
+		t.Errorf("expected moderators to be forbidden from listing users, got %d", code)
+	}
+
+	if code := do("DELETE", rolePath+store.RoleModerator, admin); code != http.StatusNoContent {
+		t.Fatalf("expected the admin to revoke the moderator role, got %d", code)
+	}
+	if code := do("GET", "/moderation/resource", user); code != http.StatusForbidden {
+		t.Errorf("expected the revoked role to take effect right away, got %d", code)
+	}
+	if code := do("DELETE", "/admin/user/"+strconv.FormatInt(admin, 10)+"/role/"+store.RoleAdmin, admin); code != http.StatusBadRequest {
+		t.Errorf("expected admins to be kept from revoking their own admin role, got %d", code)
+	}
+
+	if code := do("DELETE", "/user/"+strconv.FormatInt(user, 10), user); code != http.StatusForbidden {
+		t.Errorf("expected users to be forbidden from deleting users, got %d", code)
+	}
+	if code := do("DELETE", "/user/"+strconv.FormatInt(user, 10), admin); code != http.StatusNoContent {
+		t.Errorf("expected the admin to delete the user, got %d", code)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	sto := memory.New()
+	s := New(sto, Options{})
+	ts := httptest.NewServer(s.handler)
+	defer ts.Close()
+
+	user, _ := sto.CreateUser(store.User{Username: "nate", Password: "testing"})
+	other, _ := sto.CreateUser(store.User{Username: "sam", Password: "testing"})
+	jwt, _, _ := s.tokens.Issue(auth.Identity{ID: user}, time.Now())
+
+	type apiKey struct {
+		ID         int64      `json:"id"`
+		Key        string     `json:"key"`
+		Prefix     string     `json:"prefix"`
+		Scopes     []string   `json:"scopes"`
+		LastUsedAt *time.Time `json:"lastUsedAt"`
+	}
+	do := func(method, path, body, bearer string, v interface{}) int {
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		if v != nil && res.StatusCode < 300 {
+			body := struct {
+				Response interface{} `json:"response"`
+			}{v}
+			if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return res.StatusCode
+	}
+
+	if code := do("POST", "/user/1/apikey", `{"name": "bulk", "scopes": ["delete"]}`, jwt, nil); code != http.StatusBadRequest {
+		t.Errorf("expected an unknown scope to be rejected, got %d", code)
+	}
+	var readOnly, readWrite apiKey
+	if code := do("POST", "/user/1/apikey", `{"name": "reports", "scopes": ["read"]}`, jwt, &readOnly); code != http.StatusCreated {
+		t.Fatalf("expected the key to be created, got %d", code)
+	}
+	if !strings.HasPrefix(readOnly.Key, auth.APIKeyPrefix) || !strings.HasPrefix(readOnly.Key, readOnly.Prefix) {
+		t.Errorf("expected a prefixed key, got %+v", readOnly)
+	}
+	do("POST", "/user/1/apikey", `{"name": "bulk", "scopes": ["write", "read"]}`, jwt, &readWrite)
+
+	if code := do("GET", "/notification", "", readOnly.Key, nil); code != http.StatusOK {
+		t.Errorf("expected the key to authenticate, got %d", code)
+	}
+	if code := do("PUT", "/notification/read", "", readOnly.Key, nil); code != http.StatusForbidden {
+		t.Errorf("expected a read only key to be forbidden from writing, got %d", code)
+	}
+	if code := do("PUT", "/notification/read", "", readWrite.Key, nil); code != http.StatusNoContent {
+		t.Errorf("expected a write key to write, got %d", code)
+	}
+	if code := do("GET", "/notification", "", auth.APIKeyPrefix+"unknown", nil); code != http.StatusUnauthorized {
+		t.Errorf("expected an unknown key to be turned away, got %d", code)
+	}
+
+	var keys []apiKey
+	if code := do("GET", "/user/1/apikey", "", jwt, &keys); code != http.StatusOK || len(keys) != 2 {
+		t.Fatalf("expected 2 keys, got %d %+v", code, keys)
+	}
+	if keys[1].ID != readOnly.ID || keys[1].Key != "" || keys[1].LastUsedAt == nil {
+		t.Errorf("expected the listed key without its secret and with when it was last used: %+v", keys[1])
+	}
+	if code := do("GET", "/user/1/apikey", "", readWrite.Key, nil); code != http.StatusForbidden {
+		t.Errorf("expected keys to be kept from managing keys, got %d", code)
+	}
+	otherJWT, _, _ := s.tokens.Issue(auth.Identity{ID: other}, time.Now())
+	if code := do("GET", "/user/1/apikey/"+strconv.FormatInt(readOnly.ID, 10), "", otherJWT, nil); code != http.StatusForbidden {
+		t.Errorf("expected other users to be forbidden from seeing keys, got %d", code)
+	}
+
+	path := "/user/1/apikey/" + strconv.FormatInt(readOnly.ID, 10)
+	var updated apiKey
+	if code := do("PUT", path, `{"name": "reports", "scopes": ["read", "write"]}`, jwt, &updated); code != http.StatusOK || len(updated.Scopes) != 2 {
+		t.Errorf("expected the scopes to be replaced, got %d %+v", code, updated)
+	}
+	if code := do("PUT", "/notification/read", "", readOnly.Key, nil); code != http.StatusNoContent {
+		t.Errorf("expected the new scopes to take effect right away, got %d", code)
+	}
+
+	if code := do("DELETE", path, "", jwt, nil); code != http.StatusNoContent {
+		t.Fatalf("expected the key to be deleted, got %d", code)
+	}
+	if code := do("GET", "/notification", "", readOnly.Key, nil); code != http.StatusUnauthorized {
+		t.Errorf("expected a deleted key to stop working, got %d", code)
+	}
+
+	expired := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	if code := do("POST", "/user/1/apikey", `{"name": "old", "scopes": ["read"], "expiresAt": "`+expired+`"}`, jwt, nil); code != http.StatusBadRequest {
+		t.Errorf("expected an expiry in the past to be rejected, got %d", code)
+	}
+}
+
+func TestOAuth(t *testing.T) {
+	// the fake provider works like GitHub, it signs in whoever login is set to once the
+	// PKCE verifier matches the challenge of the sign in
+	var login map[string]interface{}
+	var challenge string
+	fake := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/authorize":
+			challenge = r.URL.Query().Get("code_challenge")
+			http.Redirect(w, r, r.URL.Query().Get("redirect_uri")+"?code=code&state="+r.URL.Query().Get("state"), http.StatusFound)
+		case "/token":
+			sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+			if base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+				json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"access_token": "access"})
+		case "/user":
+			if r.Header.Get("Authorization") != "Bearer access" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(login)
+		}
+	}))
+	defer fake.Close()
+
+	// the provider needs the callback URL before the server exists
+	var handler http.Handler
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { handler.ServeHTTP(w, r) }))
+	defer ts.Close()
+
+	options := auth.GitHub("instruu", "secret", ts.URL+"/auth/oauth/github/callback")
+	options.AuthURL, options.TokenURL, options.UserInfoURL = fake.URL+"/authorize", fake.URL+"/token", fake.URL+"/user"
+	provider, err := auth.NewProvider(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sto := memory.New()
+	mailer := &mail.Capture{}
+	s := New(sto, Options{Providers: []*auth.Provider{provider}, Mailer: mailer})
+	handler = s.handler
+
+	nate, _ := sto.CreateUser(store.User{Username: "nate", Email: "nate@example.com", Password: "testing"})
+
+	noRedirects := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	get := func(url string, user int64, cookie *http.Cookie) *http.Response {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		authorize(s, req, user)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		res, err := noRedirects.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+	// signIn goes through the whole flow as user, linking the account when user isn't 0
+	signIn := func(user int64) int {
+		path := "/auth/oauth/github"
+		if user != 0 {
+			path += "?link=true"
+		}
+		begin := get(ts.URL+path, user, nil)
+		if begin.StatusCode != http.StatusFound {
+			return begin.StatusCode
+		}
+		var cookie *http.Cookie
+		for _, c := range begin.Cookies() {
+			if c.Name == "oauth" {
+				cookie = c
+			}
+		}
+		callback := get(begin.Header.Get("Location"), 0, nil).Header.Get("Location")
+		return get(callback, 0, cookie).StatusCode
+	}
+
+	login = map[string]interface{}{"id": 1, "login": "octocat", "email": "octo@example.com"}
+	if code := signIn(0); code != http.StatusOK {
+		t.Fatalf("expected a new user to be signed up, got %d", code)
+	}
+	octocat, err := sto.GetAccountUser("github", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// GitHub doesn't say whether the email was verified, so it isn't kept
+	if user, _ := sto.GetUser(octocat); user.Username != "octocat" || user.Email != "" || user.Verified || len(mailer.Messages()) != 0 {
+		t.Errorf("expected octocat to be signed up without the unverified email: %+v", user)
+	}
+	if code := signIn(0); code != http.StatusOK {
+		t.Errorf("expected the linked user to sign in, got %d", code)
+	}
+	if users, _ := sto.GetUsers(); len(users) != 2 {
+		t.Errorf("expected signing in again not to sign up again, got %d users", len(users))
+	}
+
+	login = map[string]interface{}{"id": 2, "login": "nate", "email": "nate@example.com"}
+	if code := signIn(nate); code != http.StatusNoContent {
+		t.Fatalf("expected nate to link the account, got %d", code)
+	}
+	if user, _ := sto.GetAccountUser("github", "2"); user != nate {
+		t.Errorf("expected the account to be linked to nate, got %d", user)
+	}
+	if code := get(ts.URL+"/user/"+strconv.FormatInt(nate, 10)+"/account", octocat, nil).StatusCode; code != http.StatusForbidden {
+		t.Errorf("expected other users to be forbidden from listing linked accounts, got %d", code)
+	}
+
+	login = map[string]interface{}{"id": 1, "login": "octocat"}
+	if code := signIn(nate); code != http.StatusConflict {
+		t.Errorf("expected an account linked to someone else to be turned down, got %d", code)
+	}
+	login = map[string]interface{}{"id": 3, "login": "nate"}
+	if code := signIn(0); code != http.StatusOK {
+		t.Fatalf("expected a new user to be signed up, got %d", code)
+	}
+	if user, _ := sto.GetAccountUser("github", "3"); user == 0 {
+		t.Error("expected the account to be linked")
+	} else if stored, _ := sto.GetUser(user); stored.Username != "nate2" {
+		t.Errorf("expected a taken username to be numbered, got %q", stored.Username)
+	}
+
+	// an unverified email of an existing user doesn't get anyone into their account
+	login = map[string]interface{}{"id": 4, "login": "imposter", "email": "nate@example.com"}
+	if code := signIn(0); code != http.StatusOK {
+		t.Fatalf("expected a new user to be signed up, got %d", code)
+	}
+	if user, _ := sto.GetAccountUser("github", "4"); user == 0 || user == nate {
+		t.Errorf("expected the account to belong to a new user, got %d", user)
+	} else if stored, _ := sto.GetUser(user); stored.Email != "" {
+		t.Errorf("expected the unverified email not to be kept, got %q", stored.Email)
+	}
+
+	if code := get(ts.URL+"/auth/oauth/github?link=true", 0, nil).StatusCode; code != http.StatusUnauthorized {
+		t.Errorf("expected linking to need a signed in user, got %d", code)
+	}
+	if code := get(ts.URL+"/auth/oauth/gitlab", 0, nil).StatusCode; code != http.StatusNotFound {
+		t.Errorf("expected an unknown provider to be a 404, got %d", code)
+	}
+	if code := get(ts.URL+"/auth/oauth/github/callback?code=code&state=forged", 0, nil).StatusCode; code != http.StatusBadRequest {
+		t.Errorf("expected a callback without a sign in to be turned away, got %d", code)
+	}
+
+	req, _ := http.NewRequest("DELETE", ts.URL+"/user/"+strconv.FormatInt(nate, 10)+"/account/github", nil)
+	authorize(s, req, nate)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected the account to be unlinked, got %d", res.StatusCode)
+	}
+	if _, err := sto.GetAccountUser("github", "2"); err != store.ErrNoResults {
+		t.Errorf("expected the account to be gone, got %v", err)
+	}
+}
@@ -0,0 +1,253 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/auth"
+	"github.com/natethinks/instruu-api/internal/respond"
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+const (
+	// refreshTokenTTL is how long a session lasts without being refreshed
+	refreshTokenTTL = 30 * 24 * time.Hour
+	// refreshCookieName is the cookie holding the refresh token, it's only sent to /auth
+	refreshCookieName = "refresh"
+)
+
+// tokens is the body of a response that signs a user in
+type tokens struct {
+	JWT          string    `json:"jwt"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	RefreshToken string    `json:"refreshToken"`
+	Session      int64     `json:"session"`
+}
+
+// Session Functions
+
+// revoked checks the token of a request against the store for CheckJWT, tokens stop working
+// when their session is revoked or when they're older than the last time every session of
+// the user was revoked
+func (s *Server) revoked(identity auth.Identity) (bool, error) {
+	revokedAt, err := s.sto.SessionsRevokedAt(identity.ID)
+	if err != nil {
+		return false, err
+	}
+	// iat only has second precision, so a token from the second of the revocation is let
+	// through rather than turning away one issued right after it
+	if identity.IssuedAt.Unix() < revokedAt.Unix() {
+		return true, nil
+	}
+
+	if identity.Session == 0 {
+		return false, nil
+	}
+	session, err := s.sto.GetSession(identity.Session)
+	if err == store.ErrNoResults {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	return session.User != identity.ID || !session.Active(time.Now()), nil
+}
+
+// clientIP is the address the request came from, without the port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// startSession signs user in on a new session for the device making the request
+func (s *Server) startSession(w http.ResponseWriter, r *http.Request, user store.User) {
+	refreshToken, hash, err := auth.NewToken()
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	session := store.Session{
+		User:      user.ID,
+		UserAgent: r.UserAgent(),
+		IP:        clientIP(r),
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	}
+	session.ID, err = s.sto.CreateSession(session, hash)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	s.respondTokens(w, user, session, refreshToken)
+}
+
+// respondTokens issues an access token for the session and responds with it and the
+// refresh token, both are also set as cookies
+func (s *Server) respondTokens(w http.ResponseWriter, user store.User, session store.Session, refreshToken string) {
+	identity := auth.Identity{ID: user.ID, Username: user.Username, Session: session.ID}
+	jwt, expires, err := s.tokens.Issue(identity, time.Now())
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.CookieName,
+		Value:    jwt,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshCookieName,
+		Value:    refreshToken,
+		Path:     "/auth",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	respond.JSON(w, tokens{JWT: jwt, ExpiresAt: expires, RefreshToken: refreshToken, Session: session.ID})
+}
+
+// clearTokens expires the cookies respondTokens sets
+func clearTokens(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Name: auth.CookieName, Path: "/", MaxAge: -1})
+	http.SetCookie(w, &http.Cookie{Name: refreshCookieName, Path: "/auth", MaxAge: -1})
+}
+
+// refreshTokenFrom reads the refresh token from the JSON body, or from the refresh cookie
+// when the body doesn't have one
+func refreshTokenFrom(r *http.Request) string {
+	var body struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	// the body is optional so it not decoding is the same as it being empty
+	json.NewDecoder(r.Body).Decode(&body)
+	if body.RefreshToken != "" {
+		return body.RefreshToken
+	}
+
+	if cookie, err := r.Cookie(refreshCookieName); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// refresh trades a refresh token for a new access token and a new refresh token, the old
+// refresh token stops working. Using it again revokes the session
+func (s *Server) refresh(w http.ResponseWriter, r *http.Request) {
+	refreshToken := refreshTokenFrom(r)
+	if refreshToken == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		respond.JSON(w, auth.ErrInvalidToken)
+		return
+	}
+
+	next, hash, err := auth.NewToken()
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	session, err := s.sto.RotateSession(auth.HashToken(refreshToken), hash, time.Now().Add(refreshTokenTTL))
+	if err == store.ErrNoResults || err == store.ErrReusedToken {
+		if err == store.ErrNoResults {
+			err = auth.ErrInvalidToken
+		}
+		clearTokens(w)
+		w.WriteHeader(http.StatusUnauthorized)
+		respond.JSON(w, err)
+		return
+	} else if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	user, err := s.sto.GetUser(session.User)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	s.respondTokens(w, user, session, next)
+}
+
+// logout revokes the session of the refresh token, or of the access token when there's no
+// refresh token, and clears the cookies
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
+	identity, authenticated := auth.IdentityFrom(r.Context())
+
+	var err error
+	if refreshToken := refreshTokenFrom(r); refreshToken != "" {
+		err = s.sto.RevokeSessionByToken(auth.HashToken(refreshToken))
+	} else if authenticated && identity.Session != 0 {
+		err = s.sto.RevokeSession(identity.ID, identity.Session)
+	} else {
+		w.WriteHeader(http.StatusUnauthorized)
+		respond.JSON(w, errors.New("Missing or invalid token"))
+		return
+	}
+	// an unknown token has no session left to revoke
+	if err != nil && err != store.ErrNoResults {
+		storeError(w, err)
+		return
+	}
+
+	clearTokens(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getSessions lists the devices the user is signed in on, most recently used first
+func (s *Server) getSessions(w http.ResponseWriter, r *http.Request) {
+	user, ok := pathUser(w, r, "Sessions can only be seen by their owner")
+	if !ok {
+		return
+	}
+
+	sessions, err := s.sto.GetSessions(user)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if sessions == nil {
+		sessions = []store.Session{}
+	}
+
+	identity, _ := auth.IdentityFrom(r.Context())
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == identity.Session
+	}
+
+	respond.JSON(w, sessions)
+}
+
+// deleteSession signs the user out of one of their sessions, its access tokens stop
+// working right away
+func (s *Server) deleteSession(w http.ResponseWriter, r *http.Request) {
+	user, ok := pathUser(w, r, "Sessions can only be revoked by their owner")
+	if !ok {
+		return
+	}
+
+	id, err := pathID(r, "sid")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.sto.RevokeSession(user, id); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
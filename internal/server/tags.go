@@ -0,0 +1,152 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/natethinks/instruu-api/internal/auth"
+	"github.com/natethinks/instruu-api/internal/respond"
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Tag Functions
+
+func (s *Server) getTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := s.sto.GetTags()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		respond.JSON(w, err)
+		return
+	}
+	if tags == nil {
+		tags = []store.Tag{}
+	}
+
+	respond.JSON(w, tags)
+}
+
+func (s *Server) getTaggedResources(w http.ResponseWriter, r *http.Request) {
+	tags, err := store.NormalizeTags([]string{mux.Vars(r)["name"]})
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	resources, err := s.sto.GetTaggedResources(tags[0])
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		respond.JSON(w, err)
+		return
+	}
+	if resources == nil {
+		resources = []store.Resource{}
+	}
+
+	if err := s.markBookmarked(r, each(resources)...); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	respond.JSON(w, resources)
+}
+
+// putResourceTags replaces the tags of a resource with the JSON array of names in the body
+func (s *Server) putResourceTags(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	editor, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	var tags []string
+	if err := json.NewDecoder(r.Body).Decode(&tags); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	tags, err = store.NormalizeTags(tags)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	resource, err := s.sto.GetResource(id)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if !s.canEdit(w, r, editor, resource, auth.PermissionEditTags) {
+		return
+	}
+
+	if removed := difference(resource.Tags, tags); len(removed) > 0 {
+		if err := s.sto.RemoveTags(id, removed); err != nil {
+			storeError(w, err)
+			return
+		}
+	}
+
+	if added := difference(tags, resource.Tags); len(added) > 0 {
+		if err := s.sto.AddTags(id, added); err != nil {
+			storeError(w, err)
+			return
+		}
+	}
+
+	respond.JSON(w, tags)
+}
+
+// mergeTags folds the tag in the path into the tag named by the body, see the tag:merge
+// permission
+func (s *Server) mergeTags(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Into string `json:"into"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	tags, err := store.NormalizeTags([]string{mux.Vars(r)["name"], body.Into})
+	if err == nil && len(tags) != 2 {
+		err = errors.New("A tag can't be merged into itself")
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	if err := s.sto.MergeTags(mux.Vars(r)["name"], body.Into); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// difference returns the strings in a that aren't in b
+func difference(a, b []string) []string {
+	inB := make(map[string]bool, len(b))
+	for _, s := range b {
+		inB[s] = true
+	}
+
+	var out []string
+	for _, s := range a {
+		if !inB[s] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/auth"
+	"github.com/natethinks/instruu-api/internal/mail"
+	"github.com/natethinks/instruu-api/internal/respond"
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// verificationResendInterval is how long a user has to wait between verification emails
+const verificationResendInterval = 5 * time.Minute
+
+// Verification Functions
+
+// sendVerification mails the user a verification token, it returns false without sending
+// anything when the user was sent one too recently
+func (s *Server) sendVerification(user store.User) (bool, error) {
+	if user.Email == "" {
+		return false, errors.New("the user has no email to verify")
+	}
+
+	claimed, err := s.sto.ClaimVerificationSend(user.ID, verificationResendInterval)
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	token, err := auth.NewVerificationToken(s.options.Secret, user, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	msg, err := mail.Verify.Render(user.Email, mail.TokenData{
+		Username: user.Username,
+		Token:    token,
+		Expires:  auth.VerificationTTL.String(),
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, s.options.Mailer.Send(msg)
+}
+
+// verifyUser consumes a verification token from the JSON body, verifying an already
+// verified user again does nothing
+func (s *Server) verifyUser(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	id, email, err := auth.ParseVerificationToken(s.options.Secret, body.Token)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, err)
+		return
+	}
+
+	user, err := s.sto.GetUser(id)
+	if err != nil && err != store.ErrNoResults {
+		storeError(w, err)
+		return
+	}
+	// the token only verifies the email it was sent to
+	if err == store.ErrNoResults || user.Email != email {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, auth.ErrInvalidToken)
+		return
+	}
+
+	if !user.Verified {
+		if err := s.sto.VerifyUser(id); err != nil {
+			storeError(w, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resendVerification mails the acting user a new verification token, at most once every
+// verificationResendInterval
+func (s *Server) resendVerification(w http.ResponseWriter, r *http.Request) {
+	id, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := s.sto.GetUser(id)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+
+	if user.Verified {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, errors.New("Email is already verified"))
+		return
+	}
+	if user.Email == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, errors.New("There's no email to verify"))
+		return
+	}
+
+	sent, err := s.sendVerification(user)
+	if err != nil {
+		storeError(w, err)
+		return
+	}
+	if !sent {
+		w.Header().Set("Retry-After", strconv.Itoa(int(verificationResendInterval/time.Second)))
+		w.WriteHeader(http.StatusTooManyRequests)
+		respond.JSON(w, errors.New("A verification email was sent recently, try again later"))
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// verifiedSubmitter checks the acting user has a verified email and makes them the
+// submitter of the resource, responding with a 403 when they aren't verified
+func (s *Server) verifiedSubmitter(w http.ResponseWriter, r *http.Request, resource *store.Resource) bool {
+	id, ok := requireUser(w, r)
+	if !ok {
+		return false
+	}
+
+	user, err := s.sto.GetUser(id)
+	if err != nil && err != store.ErrNoResults {
+		storeError(w, err)
+		return false
+	}
+	if err == store.ErrNoResults || !user.Verified {
+		w.WriteHeader(http.StatusForbidden)
+		respond.JSON(w, errors.New("Verify your email before submitting resources"))
+		return false
+	}
+
+	resource.Submitter = id
+	return true
+}
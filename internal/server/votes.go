@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/natethinks/instruu-api/internal/respond"
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Vote Functions
+
+// putVote records the acting user's upvote or downvote, a user only ever has one vote
+// per resource so voting again replaces it
+func (s *Server) putVote(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Value int `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := store.ValidateVote(body.Value); err != nil || body.Value == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		respond.JSON(w, errors.New("value must be 1 to upvote or -1 to downvote"))
+		return
+	}
+
+	s.vote(w, r, body.Value)
+}
+
+// deleteVote withdraws the acting user's vote
+func (s *Server) deleteVote(w http.ResponseWriter, r *http.Request) {
+	s.vote(w, r, 0)
+}
+
+func (s *Server) vote(w http.ResponseWriter, r *http.Request, value int) {
+	voter, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.sto.Vote(id, voter, value); err != nil {
+		storeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
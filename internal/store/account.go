@@ -0,0 +1,20 @@
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrAccountLinked is returned when an external account is already linked to another user,
+// or the user already linked another account of the same provider
+var ErrAccountLinked = errors.New("account is already linked")
+
+// ExternalAccount links an account at an OAuth provider, identified by Subject, to User so
+// they can sign in with it
+type ExternalAccount struct {
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	User      int64     `json:"user"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// API key scopes limit what a key can do on behalf of its user
+const (
+	// ScopeRead allows GET, HEAD and OPTIONS requests
+	ScopeRead = "read"
+	// ScopeWrite allows every other method
+	ScopeWrite = "write"
+	// ScopeAdmin lets the key use the permissions of the user's roles
+	ScopeAdmin = "admin"
+)
+
+// APIKeyScopes lists every scope a key can be given
+var APIKeyScopes = []string{ScopeAdmin, ScopeRead, ScopeWrite}
+
+// APIKey lets scripts authenticate as User without signing in, only a hash of the key is
+// ever stored. Prefix is the start of the key so users can tell their keys apart
+type APIKey struct {
+	ID         int64      `json:"id"`
+	User       int64      `json:"user"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// Active reports whether the key can still be used at now
+func (k APIKey) Active(now time.Time) bool {
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// HasScope reports whether the key was given scope
+func (k APIKey) HasScope(scope string) bool {
+	for _, granted := range k.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateAPIKey checks the name and scopes of a key, and normalizes the scopes the way
+// NormalizeTags does for tags
+func ValidateAPIKey(key *APIKey) error {
+	key.Name = strings.TrimSpace(key.Name)
+	if key.Name == "" || len(key.Name) > 100 {
+		return fmt.Errorf("API keys need a name of at most 100 characters")
+	}
+	if len(key.Scopes) == 0 {
+		return fmt.Errorf("API keys need at least one scope")
+	}
+
+	seen := make(map[string]bool, len(key.Scopes))
+	scopes := make([]string, 0, len(key.Scopes))
+	for _, scope := range key.Scopes {
+		known := false
+		for _, s := range APIKeyScopes {
+			known = known || s == scope
+		}
+		if !known {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	sort.Strings(scopes)
+	key.Scopes = scopes
+	return nil
+}
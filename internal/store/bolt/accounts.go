@@ -0,0 +1,143 @@
+package bolt
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+
+	bbolt "go.etcd.io/bbolt"
+)
+
+// accountKey keys an external account by provider then subject
+func accountKey(provider, subject string) []byte {
+	return []byte(provider + "\x00" + subject)
+}
+
+// providerAccounts calls fn with every account linked at provider
+func providerAccounts(tx *bbolt.Tx, provider string, fn func(account store.ExternalAccount) error) error {
+	prefix := accountKey(provider, "")
+	c := tx.Bucket(externalAccountsBucket).Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		var account store.ExternalAccount
+		if err := json.Unmarshal(v, &account); err != nil {
+			return err
+		}
+		if err := fn(account); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// External Account Functions
+
+func (s *service) LinkAccount(account store.ExternalAccount) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return linkAccount(tx, account)
+	})
+}
+
+// CreateExternalUser leaves it to the transaction to drop the user when linking fails
+func (s *service) CreateExternalUser(user store.User, account store.ExternalAccount) (id int64, err error) {
+	user = newUser(user)
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		if id, err = createUser(tx, user); err != nil {
+			return err
+		}
+		account.User = id
+		return linkAccount(tx, account)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func linkAccount(tx *bbolt.Tx, account store.ExternalAccount) error {
+	users := tx.Bucket(usersBucket)
+	if users.Get(itob(account.User)) == nil {
+		return store.ErrNoResults
+	}
+
+	// links left behind by deleted users don't count
+	err := providerAccounts(tx, account.Provider, func(linked store.ExternalAccount) error {
+		if linked.User == account.User ||
+			(linked.Subject == account.Subject && users.Get(itob(linked.User)) != nil) {
+			return store.ErrAccountLinked
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	account.CreatedAt = time.Now()
+	data, err := json.Marshal(account)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(externalAccountsBucket).Put(accountKey(account.Provider, account.Subject), data)
+}
+
+func (s *service) GetAccountUser(provider, subject string) (user int64, err error) {
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(externalAccountsBucket).Get(accountKey(provider, subject))
+		if data == nil {
+			return store.ErrNoResults
+		}
+
+		var account store.ExternalAccount
+		if err := json.Unmarshal(data, &account); err != nil {
+			return err
+		}
+		if tx.Bucket(usersBucket).Get(itob(account.User)) == nil {
+			return store.ErrNoResults
+		}
+		user = account.User
+		return nil
+	})
+	return user, err
+}
+
+func (s *service) GetAccounts(user int64) (accounts []store.ExternalAccount, err error) {
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(externalAccountsBucket).ForEach(func(k, v []byte) error {
+			var account store.ExternalAccount
+			if err := json.Unmarshal(v, &account); err != nil {
+				return err
+			}
+			if account.User == user {
+				accounts = append(accounts, account)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].CreatedAt.Before(accounts[j].CreatedAt) })
+	return accounts, nil
+}
+
+func (s *service) UnlinkAccount(user int64, provider string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		var subject string
+		err := providerAccounts(tx, provider, func(account store.ExternalAccount) error {
+			if account.User == user {
+				subject = account.Subject
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if subject == "" {
+			return store.ErrNoResults
+		}
+		return tx.Bucket(externalAccountsBucket).Delete(accountKey(provider, subject))
+	})
+}
@@ -0,0 +1,139 @@
+package bolt
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+
+	bbolt "go.etcd.io/bbolt"
+)
+
+// apiKey is how a key is stored, the hash is kept to clear the index when it's deleted
+type apiKey struct {
+	store.APIKey
+	TokenHash string `json:"tokenHash"`
+}
+
+// API Key Functions
+
+func (s *service) CreateAPIKey(key store.APIKey, tokenHash string) (id int64, err error) {
+	key.CreatedAt = time.Now()
+	key.LastUsedAt = nil
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		if tx.Bucket(usersBucket).Get(itob(key.User)) == nil {
+			return store.ErrNoResults
+		}
+
+		b := tx.Bucket(apiKeysBucket)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		key.ID = int64(seq)
+		if err := put(b, key.ID, apiKey{APIKey: key, TokenHash: tokenHash}); err != nil {
+			return err
+		}
+
+		return tx.Bucket(apiKeyHashIndexBucket).Put([]byte(tokenHash), itob(key.ID))
+	})
+	return key.ID, err
+}
+
+func (s *service) GetAPIKey(id int64) (store.APIKey, error) {
+	var stored apiKey
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return get(tx.Bucket(apiKeysBucket), id, &stored)
+	})
+	if err != nil {
+		return store.APIKey{ID: id}, err
+	}
+	return stored.APIKey, nil
+}
+
+func (s *service) GetAPIKeys(user int64) (keys []store.APIKey, err error) {
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(apiKeysBucket).ForEach(func(k, v []byte) error {
+			var stored apiKey
+			if err := json.Unmarshal(v, &stored); err != nil {
+				return err
+			}
+			if stored.User == user {
+				keys = append(keys, stored.APIKey)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID > keys[j].ID })
+	return keys, nil
+}
+
+func (s *service) UpdateAPIKey(key store.APIKey) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(apiKeysBucket)
+
+		var stored apiKey
+		if err := get(b, key.ID, &stored); err != nil {
+			return err
+		}
+		if stored.User != key.User {
+			return store.ErrNoResults
+		}
+
+		stored.Name = key.Name
+		stored.Scopes = key.Scopes
+		return put(b, key.ID, stored)
+	})
+}
+
+func (s *service) DeleteAPIKey(user, id int64) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(apiKeysBucket)
+
+		var stored apiKey
+		if err := get(b, id, &stored); err != nil {
+			return err
+		}
+		if stored.User != user {
+			return store.ErrNoResults
+		}
+
+		if err := tx.Bucket(apiKeyHashIndexBucket).Delete([]byte(stored.TokenHash)); err != nil {
+			return err
+		}
+		return b.Delete(itob(id))
+	})
+}
+
+func (s *service) UseAPIKey(tokenHash string) (key store.APIKey, err error) {
+	now := time.Now()
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		id := tx.Bucket(apiKeyHashIndexBucket).Get([]byte(tokenHash))
+		if id == nil {
+			return store.ErrNoResults
+		}
+
+		b := tx.Bucket(apiKeysBucket)
+		var stored apiKey
+		if err := get(b, btoi(id), &stored); err != nil {
+			return err
+		}
+		if !stored.Active(now) || tx.Bucket(usersBucket).Get(itob(stored.User)) == nil {
+			return store.ErrNoResults
+		}
+
+		stored.LastUsedAt = &now
+		key = stored.APIKey
+		return put(b, stored.ID, stored)
+	})
+	if err != nil {
+		return store.APIKey{}, err
+	}
+	return key, nil
+}
@@ -0,0 +1,729 @@
+package bolt
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/auth"
+	"github.com/natethinks/instruu-api/internal/store"
+
+	"github.com/pkg/errors"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// bucket names match the layout of the server.db that ships with the repo
+var (
+	usersBucket       = []byte("Users")
+	resourcesBucket   = []byte("Resources")
+	collectionsBucket = []byte("Collections")
+	curriculumsBucket = []byte("Curriculums")
+
+	// secondary indexes mapping a unique value back to a record ID
+	usernameIndexBucket    = []byte("UsernameIndex")
+	resourceURLIndexBucket = []byte("ResourceURLIndex")
+
+	// revisions are keyed by resource ID followed by revision number
+	resourceRevisionsBucket = []byte("ResourceRevisions")
+
+	// only the sequence of this bucket is used, it hands out section and step IDs
+	curriculumNodesBucket = []byte("CurriculumNodes")
+	// progress is keyed by learner ID followed by step ID
+	curriculumProgressBucket = []byte("CurriculumProgress")
+	// reviews are keyed by resource ID followed by reviewer ID
+	reviewsBucket = []byte("Reviews")
+	// votes are keyed by resource ID followed by voter ID
+	votesBucket = []byte("Votes")
+
+	commentsBucket = []byte("Comments")
+	// resource comments index comment IDs by resource ID followed by comment ID
+	resourceCommentsIndexBucket = []byte("ResourceCommentsIndex")
+
+	// bookmarks are keyed by user ID followed by resource ID and hold when it was saved
+	bookmarksBucket = []byte("Bookmarks")
+
+	// follows are keyed by follower ID followed by the user ID or tag and hold when the
+	// follow started
+	followedUsersBucket = []byte("FollowedUsers")
+	followedTagsBucket  = []byte("FollowedTags")
+	// events are keyed by a sequence so they're stored oldest first
+	eventsBucket = []byte("Events")
+
+	// notifications are keyed by user ID followed by notification ID
+	notificationsBucket = []byte("Notifications")
+
+	// verification sent holds when each user was last sent a verification email
+	verificationSentBucket = []byte("VerificationSent")
+	// password resets are keyed by token hash
+	passwordResetsBucket = []byte("PasswordResets")
+	// sessions revoked holds when each user's sessions were last revoked
+	sessionsRevokedBucket = []byte("SessionsRevoked")
+
+	// outbox is keyed by a sequence so mail is sent oldest first
+	outboxBucket = []byte("Outbox")
+
+	sessionsBucket = []byte("Sessions")
+	// refresh tokens are keyed by token hash and point at their session
+	refreshTokensBucket = []byte("RefreshTokens")
+
+	apiKeysBucket = []byte("APIKeys")
+	// api key hash index maps the hash of each key's token to its ID
+	apiKeyHashIndexBucket = []byte("APIKeyHashIndex")
+
+	// external accounts are keyed by provider followed by a zero byte and the subject
+	externalAccountsBucket = []byte("ExternalAccounts")
+)
+
+// buckets are created when the database is opened
+var buckets = [][]byte{
+	usersBucket, resourcesBucket, collectionsBucket, curriculumsBucket,
+	usernameIndexBucket, resourceURLIndexBucket,
+	resourceRevisionsBucket, curriculumNodesBucket, curriculumProgressBucket,
+	reviewsBucket, votesBucket, commentsBucket, resourceCommentsIndexBucket,
+	bookmarksBucket, followedUsersBucket, followedTagsBucket, eventsBucket,
+	notificationsBucket, verificationSentBucket, passwordResetsBucket, sessionsRevokedBucket,
+	outboxBucket, sessionsBucket, refreshTokensBucket, apiKeysBucket, apiKeyHashIndexBucket,
+	externalAccountsBucket,
+}
+
+type service struct {
+	db *bbolt.DB
+}
+
+// New opens (or creates) the bolt database at path and returns a store.Service
+func New(path string) (store.Service, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, errors.Wrap(err, "opening bolt database")
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range buckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return errors.Wrapf(err, "creating %s bucket", name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &service{db: db}, nil
+}
+
+// itob encodes an ID big endian so keys sort in insertion order
+func itob(id int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(id))
+	return b
+}
+
+func btoi(b []byte) int64 {
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+// get decodes the record stored under id, returning store.ErrNoResults when it's missing
+func get(b *bbolt.Bucket, id int64, v interface{}) error {
+	data := b.Get(itob(id))
+	if data == nil {
+		return store.ErrNoResults
+	}
+	return json.Unmarshal(data, v)
+}
+
+func put(b *bbolt.Bucket, id int64, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return b.Put(itob(id), data)
+}
+
+// Authentication Functions
+
+func (s *service) Auth(user store.User) (store.User, error) {
+	var stored store.User
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		id := tx.Bucket(usernameIndexBucket).Get([]byte(user.Username))
+		if id == nil {
+			return store.ErrNoResults
+		}
+		return get(tx.Bucket(usersBucket), btoi(id), &stored)
+	})
+	if err != nil {
+		return store.User{}, err
+	}
+
+	if !auth.VerifyPassword(stored.PasswordHash, []byte(user.Password)) {
+		return store.User{}, errors.New("Incorrect Password")
+	}
+
+	return public(stored), nil
+}
+
+// User Functions
+
+// CreateUser rejects duplicate usernames since the username index can only point at one user
+func (s *service) CreateUser(user store.User) (id int64, err error) {
+	user = newUser(user)
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		id, err = createUser(tx, user)
+		return err
+	})
+	return id, err
+}
+
+// newUser hashes the password of a user about to be created and clears what a new user
+// can't set themselves
+func newUser(user store.User) store.User {
+	user.PasswordHash = auth.GeneratePasswordHash([]byte(user.Password))
+	user.Password = ""
+	user.Roles = nil
+	user.Verified = false
+	user.Notifications = nil
+	return user
+}
+
+// createUser stores a user that comes from newUser
+func createUser(tx *bbolt.Tx, user store.User) (int64, error) {
+	index := tx.Bucket(usernameIndexBucket)
+	if index.Get([]byte(user.Username)) != nil {
+		return 0, store.ErrUsernameExists
+	}
+
+	b := tx.Bucket(usersBucket)
+	seq, err := b.NextSequence()
+	if err != nil {
+		return 0, err
+	}
+	user.ID = int64(seq)
+
+	if err := put(b, user.ID, user); err != nil {
+		return 0, err
+	}
+	return user.ID, index.Put([]byte(user.Username), itob(user.ID))
+}
+
+func (s *service) GetUser(id int64) (user store.User, err error) {
+	user = store.User{ID: id}
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		return get(tx.Bucket(usersBucket), id, &user)
+	})
+	return public(user), err
+}
+
+// PatchUser only overwrites the fields that are set on user
+func (s *service) PatchUser(user store.User) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(usersBucket)
+		index := tx.Bucket(usernameIndexBucket)
+
+		var stored store.User
+		if err := get(b, user.ID, &stored); err != nil {
+			return err
+		}
+
+		if user.Username != "" && user.Username != stored.Username {
+			if index.Get([]byte(user.Username)) != nil {
+				return errors.New("Username exists, cannot update")
+			}
+			if err := index.Delete([]byte(stored.Username)); err != nil {
+				return err
+			}
+			if err := index.Put([]byte(user.Username), itob(stored.ID)); err != nil {
+				return err
+			}
+			stored.Username = user.Username
+		}
+		if user.Email != "" {
+			stored.Email = user.Email
+		}
+		if user.FirstName != "" {
+			stored.FirstName = user.FirstName
+		}
+		if user.LastName != "" {
+			stored.LastName = user.LastName
+		}
+		if user.Password != "" {
+			stored.PasswordHash = auth.GeneratePasswordHash([]byte(user.Password))
+		}
+
+		return put(b, stored.ID, stored)
+	})
+}
+
+func (s *service) DeleteUser(id int64) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(usersBucket)
+
+		var stored store.User
+		if err := get(b, id, &stored); err != nil {
+			return err
+		}
+
+		if err := tx.Bucket(usernameIndexBucket).Delete([]byte(stored.Username)); err != nil {
+			return err
+		}
+		return b.Delete(itob(id))
+	})
+}
+
+func (s *service) GetUsers() (users []store.User, err error) {
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(usersBucket).ForEach(func(k, v []byte) error {
+			var user store.User
+			if err := json.Unmarshal(v, &user); err != nil {
+				return err
+			}
+			users = append(users, public(user))
+			return nil
+		})
+	})
+	if err == nil && len(users) == 0 {
+		err = store.ErrNoResults
+	}
+	return users, err
+}
+
+func (s *service) CheckUsername(user store.User) error {
+	return s.db.View(func(tx *bbolt.Tx) error {
+		if tx.Bucket(usernameIndexBucket).Get([]byte(user.Username)) != nil {
+			return store.ErrUsernameExists
+		}
+		return nil
+	})
+}
+
+func (s *service) SetRole(id int64, role string, granted bool) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(usersBucket)
+
+		var stored store.User
+		if err := get(b, id, &stored); err != nil {
+			return err
+		}
+
+		stored.Roles = store.SetRole(stored.Roles, role, granted)
+		return put(b, id, stored)
+	})
+}
+
+func (s *service) VerifyUser(id int64) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(usersBucket)
+
+		var stored store.User
+		if err := get(b, id, &stored); err != nil {
+			return err
+		}
+
+		stored.Verified = true
+		return put(b, id, stored)
+	})
+}
+
+func (s *service) ClaimVerificationSend(id int64, interval time.Duration) (claimed bool, err error) {
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		if tx.Bucket(usersBucket).Get(itob(id)) == nil {
+			return store.ErrNoResults
+		}
+
+		b := tx.Bucket(verificationSentBucket)
+		now := time.Now()
+		if sent := b.Get(itob(id)); sent != nil && now.Sub(time.Unix(0, btoi(sent))) < interval {
+			return nil
+		}
+
+		claimed = true
+		return b.Put(itob(id), itob(now.UnixNano()))
+	})
+	return claimed, err
+}
+
+// GetUserByEmail walks every user since emails aren't indexed, the lowest ID wins
+func (s *service) GetUserByEmail(email string) (user store.User, err error) {
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(usersBucket).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var stored store.User
+			if err := json.Unmarshal(v, &stored); err != nil {
+				return err
+			}
+			if email != "" && strings.EqualFold(stored.Email, email) {
+				user = stored
+				return nil
+			}
+		}
+		return store.ErrNoResults
+	})
+	return public(user), err
+}
+
+func (s *service) Close() error {
+	return s.db.Close()
+}
+
+// public strips the credentials from a stored user before it's handed out
+func public(user store.User) store.User {
+	user.Password = ""
+	user.PasswordHash = ""
+	return user
+}
+
+// Resource Functions
+
+func (s *service) CreateResource(resource store.Resource) (id int64, err error) {
+	if resource.Tags, err = store.NormalizeTags(resource.Tags); err != nil {
+		return id, err
+	}
+	resource.Approved = false
+	resource.Rejected = false
+	resource.RejectionReason = ""
+	resource.ModeratedBy, resource.ModeratedAt, resource.ApprovedAt = 0, nil, nil
+	resource.Deleted = false
+	resource.Rating, resource.RatingCount = 0, 0
+	resource.Score, resource.BookmarkCount = 0, 0
+	resource.Bookmarked = false
+	resource.CreatedAt = time.Now()
+	resource.Hot = store.HotScore(0, resource.CreatedAt)
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		index := tx.Bucket(resourceURLIndexBucket)
+		if index.Get([]byte(resource.URL)) != nil {
+			return errors.New("Resource URL already exists")
+		}
+
+		b := tx.Bucket(resourcesBucket)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		resource.ID = int64(seq)
+
+		if err := put(b, resource.ID, resource); err != nil {
+			return err
+		}
+		if err := index.Put([]byte(resource.URL), itob(resource.ID)); err != nil {
+			return err
+		}
+
+		// the submitted content is the first revision
+		return recordRevision(tx, resource.ID, resource.Submitter, store.Diff(store.Resource{}, resource))
+	})
+	return resource.ID, err
+}
+
+func (s *service) GetResource(id int64) (resource store.Resource, err error) {
+	resource = store.Resource{ID: id}
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		if err := get(tx.Bucket(resourcesBucket), id, &resource); err != nil {
+			return err
+		}
+		if resource.Deleted {
+			return store.ErrNoResults
+		}
+		return nil
+	})
+	return resource, err
+}
+
+func (s *service) GetResources(query store.ResourceQuery) ([]store.Resource, error) {
+	var resources []store.Resource
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(resourcesBucket).ForEach(func(k, v []byte) error {
+			var resource store.Resource
+			if err := json.Unmarshal(v, &resource); err != nil {
+				return err
+			}
+			resources = append(resources, resource)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return query.Apply(resources), nil
+}
+
+// filterResources returns every live resource that keep returns true for, in ID order
+func (s *service) filterResources(keep func(store.Resource) bool) (resources []store.Resource, err error) {
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(resourcesBucket).ForEach(func(k, v []byte) error {
+			var resource store.Resource
+			if err := json.Unmarshal(v, &resource); err != nil {
+				return err
+			}
+			if !resource.Deleted && keep(resource) {
+				resources = append(resources, resource)
+			}
+			return nil
+		})
+	})
+	return resources, err
+}
+
+// DeleteResource only flags the resource as deleted, the URL stays claimed
+func (s *service) DeleteResource(id int64) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(resourcesBucket)
+
+		var stored store.Resource
+		if err := get(b, id, &stored); err != nil {
+			return err
+		}
+		if stored.Deleted {
+			return store.ErrNoResults
+		}
+
+		stored.Deleted = true
+		return put(b, id, stored)
+	})
+}
+
+// Moderation Functions
+
+// GetPendingResources lists live resources that haven't been approved or rejected, oldest first
+func (s *service) GetPendingResources(page store.Page) ([]store.Resource, error) {
+	pending, err := s.filterResources(func(resource store.Resource) bool {
+		return !resource.Approved && !resource.Rejected
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := page.Paginate(len(pending))
+	return pending[start:end], nil
+}
+
+func (s *service) ApproveResource(id, moderator int64) (bool, error) {
+	return s.moderate(id, moderator, true, "")
+}
+
+func (s *service) RejectResource(id, moderator int64, reason string) error {
+	_, err := s.moderate(id, moderator, false, reason)
+	return err
+}
+
+// moderate reports whether it changed the resource, approving an approved resource doesn't
+func (s *service) moderate(id, moderator int64, approved bool, reason string) (changed bool, err error) {
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(resourcesBucket)
+
+		var stored store.Resource
+		if err := get(b, id, &stored); err != nil {
+			return err
+		}
+		if stored.Deleted {
+			return store.ErrNoResults
+		}
+		if approved && stored.Approved {
+			return nil
+		}
+		changed = true
+
+		// only the first approval makes the feed, edits send a resource back to be approved again
+		now := time.Now()
+		if approved && stored.ApprovedAt == nil {
+			if err := record(tx, store.EventResourceApproved, stored.Submitter, id, stored.Tags); err != nil {
+				return err
+			}
+			stored.ApprovedAt = &now
+		}
+
+		stored.Approved = approved
+		stored.Rejected = !approved
+		stored.RejectionReason = reason
+		stored.ModeratedBy, stored.ModeratedAt = moderator, &now
+		return put(b, id, stored)
+	})
+	return changed && err == nil, err
+}
+
+// Search Functions
+
+// Search scans every resource, bolt has no full text index
+func (s *service) Search(query store.SearchQuery) ([]store.SearchResult, error) {
+	resources, err := s.filterResources(func(store.Resource) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+
+	return store.NaiveSearch(resources, query), nil
+}
+
+// Tag Functions
+
+// updateTags applies change to the tags of a live resource
+func (s *service) updateTags(resourceID int64, change func(tags []string) []string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(resourcesBucket)
+
+		var stored store.Resource
+		if err := get(b, resourceID, &stored); err != nil {
+			return err
+		}
+		if stored.Deleted {
+			return store.ErrNoResults
+		}
+
+		stored.Tags = change(stored.Tags)
+		return put(b, resourceID, stored)
+	})
+}
+
+func (s *service) AddTags(resourceID int64, tags []string) error {
+	tags, err := store.NormalizeTags(tags)
+	if err != nil {
+		return err
+	}
+
+	return s.updateTags(resourceID, func(existing []string) []string {
+		merged, _ := store.NormalizeTags(append(existing, tags...))
+		return merged
+	})
+}
+
+func (s *service) RemoveTags(resourceID int64, tags []string) error {
+	tags, err := store.NormalizeTags(tags)
+	if err != nil {
+		return err
+	}
+
+	remove := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		remove[tag] = true
+	}
+
+	return s.updateTags(resourceID, func(existing []string) []string {
+		kept := make([]string, 0, len(existing))
+		for _, tag := range existing {
+			if !remove[tag] {
+				kept = append(kept, tag)
+			}
+		}
+		return kept
+	})
+}
+
+// GetTags only knows about tags that are on an approved resource, so every count is at least one
+func (s *service) GetTags() ([]store.Tag, error) {
+	counts := make(map[string]int64)
+	_, err := s.filterResources(func(resource store.Resource) bool {
+		if !resource.Approved {
+			return false
+		}
+		for _, tag := range resource.Tags {
+			counts[tag]++
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]store.Tag, 0, len(counts))
+	for name, count := range counts {
+		tags = append(tags, store.Tag{Name: name, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Name < tags[j].Name
+	})
+
+	return tags, nil
+}
+
+// GetTaggedResources only lists approved resources
+func (s *service) GetTaggedResources(tag string) ([]store.Resource, error) {
+	return s.filterResources(func(resource store.Resource) bool {
+		if !resource.Approved {
+			return false
+		}
+		for _, t := range resource.Tags {
+			if t == tag {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (s *service) MergeTags(from, into string) error {
+	from, err := normalizeTag(from)
+	if err != nil {
+		return err
+	}
+	if into, err = normalizeTag(into); err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(resourcesBucket)
+
+		// collect first, a bucket can't be written while it's iterated
+		var retagged []store.Resource
+		err := b.ForEach(func(k, v []byte) error {
+			var resource store.Resource
+			if err := json.Unmarshal(v, &resource); err != nil {
+				return err
+			}
+			for _, tag := range resource.Tags {
+				if tag == from && !resource.Deleted {
+					retagged = append(retagged, resource)
+					break
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if len(retagged) == 0 {
+			return store.ErrNoResults
+		}
+
+		for _, resource := range retagged {
+			tags := make([]string, 0, len(resource.Tags))
+			for _, tag := range resource.Tags {
+				if tag != from {
+					tags = append(tags, tag)
+				}
+			}
+			resource.Tags, _ = store.NormalizeTags(append(tags, into))
+			if err := put(b, resource.ID, resource); err != nil {
+				return err
+			}
+		}
+
+		follows := tx.Bucket(followedTagsBucket)
+		var followed [][]byte
+		err = follows.ForEach(func(k, v []byte) error {
+			if string(k[8:]) == from {
+				followed = append(followed, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range followed {
+			key := followedTagKey(btoi(k[:8]), into)
+			if follows.Get(key) == nil {
+				if err := follows.Put(key, append([]byte(nil), follows.Get(k)...)); err != nil {
+					return err
+				}
+			}
+			if err := follows.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
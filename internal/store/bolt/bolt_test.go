@@ -0,0 +1,463 @@
+package bolt
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+	"github.com/natethinks/instruu-api/internal/store/storetest"
+)
+
+func newTestStore(t *testing.T) (store.Service, func()) {
+	dir, err := ioutil.TempDir("", "instruu-bolt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sto, err := New(filepath.Join(dir, "server.db"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return sto, func() {
+		sto.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestUsernameIndex(t *testing.T) {
+	sto, cleanup := newTestStore(t)
+	defer cleanup()
+
+	id, err := sto.CreateUser(store.User{Username: "nate", Password: "testing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sto.CreateUser(store.User{Username: "nate", Password: "testing"}); err == nil {
+		t.Error("duplicate username was accepted")
+	}
+
+	if err := sto.PatchUser(store.User{ID: id, Username: "nathan"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sto.CheckUsername(store.User{Username: "nate"}); err != nil {
+		t.Errorf("old username still claimed after rename: %v", err)
+	}
+	if _, err := sto.Auth(store.User{Username: "nathan", Password: "testing"}); err != nil {
+		t.Errorf("auth after rename: %v", err)
+	}
+}
+
+func TestDeleteResource(t *testing.T) {
+	sto, cleanup := newTestStore(t)
+	defer cleanup()
+
+	id, err := sto.CreateResource(store.Resource{Name: "Go Tour", URL: "https://tour.golang.org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sto.DeleteResource(id); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sto.GetResource(id); err != store.ErrNoResults {
+		t.Errorf("expected ErrNoResults for deleted resource, got %v", err)
+	}
+}
+
+func TestRevertResource(t *testing.T) {
+	sto, cleanup := newTestStore(t)
+	defer cleanup()
+
+	id, _ := sto.CreateResource(store.Resource{Name: "Go Tour", URL: "https://tour.golang.org", Submitter: 1})
+	sto.ApproveResource(id, 2)
+
+	if err := sto.UpdateResource(store.Resource{ID: id, Name: "Go Tour", URL: "https://go.dev/tour"}, 2); err != nil {
+		t.Fatal(err)
+	}
+	if pending, _ := sto.GetPendingResources(store.Page{Limit: 10}); len(pending) != 1 || pending[0].ID != id {
+		t.Errorf("expected the edited resource back in the moderation queue, got %+v", pending)
+	}
+
+	if err := sto.RevertResource(id, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	resource, _ := sto.GetResource(id)
+	if resource.URL != "https://tour.golang.org" {
+		t.Errorf("unexpected url after revert: %s", resource.URL)
+	}
+
+	if _, err := sto.CreateResource(store.Resource{URL: "https://go.dev/tour"}); err != nil {
+		t.Errorf("reverted url is still claimed: %v", err)
+	}
+}
+
+func TestStoreTags(t *testing.T) {
+	sto, cleanup := newTestStore(t)
+	defer cleanup()
+
+	storetest.Tags(t, sto)
+}
+
+func TestStoreDeleteUser(t *testing.T) {
+	sto, cleanup := newTestStore(t)
+	defer cleanup()
+
+	storetest.DeleteUser(t, sto)
+}
+
+func TestStoreUsernames(t *testing.T) {
+	sto, cleanup := newTestStore(t)
+	defer cleanup()
+
+	storetest.Usernames(t, sto)
+}
+
+func TestStoreExternalUser(t *testing.T) {
+	sto, cleanup := newTestStore(t)
+	defer cleanup()
+
+	storetest.ExternalUser(t, sto)
+}
+
+func TestStoreModeration(t *testing.T) {
+	sto, cleanup := newTestStore(t)
+	defer cleanup()
+
+	storetest.Moderation(t, sto)
+}
+
+func TestMergeTags(t *testing.T) {
+	sto, cleanup := newTestStore(t)
+	defer cleanup()
+
+	follower, _ := sto.CreateUser(store.User{Username: "nate", Password: "testing"})
+	both, _ := sto.CreateUser(store.User{Username: "sam", Password: "testing"})
+	sto.FollowTag(follower, "golang")
+	sto.FollowTag(both, "golang")
+	sto.FollowTag(both, "go")
+	id, _ := sto.CreateResource(store.Resource{Name: "Go Tour", URL: "https://tour.golang.org", Tags: []string{"golang", "go"}})
+
+	if err := sto.MergeTags("Golang", "go"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sto.MergeTags("golang", "go"); err != store.ErrNoResults {
+		t.Errorf("expected a tag nothing carries to be missing, got %v", err)
+	}
+
+	if resource, _ := sto.GetResource(id); len(resource.Tags) != 1 || resource.Tags[0] != "go" {
+		t.Errorf("unexpected tags after merging: %v", resource.Tags)
+	}
+	for _, user := range []int64{follower, both} {
+		if follows, _ := sto.GetFollows(user); len(follows.Tags) != 1 || follows.Tags[0] != "go" {
+			t.Errorf("expected user %d to follow the merged tag, got %v", user, follows.Tags)
+		}
+	}
+}
+
+func TestCurriculumTree(t *testing.T) {
+	sto, cleanup := newTestStore(t)
+	defer cleanup()
+
+	resource, _ := sto.CreateResource(store.Resource{Name: "Go Tour", URL: "https://tour.golang.org"})
+	id, err := sto.CreateCurriculum(store.Curriculum{Owner: 1, Title: "Learning Go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	basics, _ := sto.AddCurriculumSection(id, store.Section{Title: "Basics"})
+	next, _ := sto.AddCurriculumSection(id, store.Section{Title: "Next steps"})
+	step, err := sto.AddCurriculumStep(id, basics, store.Step{Resource: resource, EstimatedMinutes: 90})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sto.AddCurriculumStep(id, basics, store.Step{Resource: 99}); err != store.ErrNoResults {
+		t.Errorf("expected a missing resource to return ErrNoResults, got %v", err)
+	}
+
+	if err := sto.OrderCurriculumSections(id, []int64{next, basics}); err != nil {
+		t.Fatal(err)
+	}
+
+	curriculum, err := sto.GetCurriculum(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(curriculum.Sections) != 2 || curriculum.Sections[1].ID != basics ||
+		curriculum.Sections[1].Steps[0].ID != step || curriculum.EstimatedMinutes != 90 {
+		t.Errorf("unexpected curriculum tree: %+v", curriculum)
+	}
+}
+
+func TestBookmarkCount(t *testing.T) {
+	sto, cleanup := newTestStore(t)
+	defer cleanup()
+
+	id, _ := sto.CreateResource(store.Resource{Name: "Go Tour", URL: "https://tour.golang.org"})
+	for _, user := range []int64{1, 2, 2} {
+		if err := sto.AddBookmark(user, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sto.RemoveBookmark(1, id); err != nil {
+		t.Fatal(err)
+	}
+	if err := sto.RemoveBookmark(1, id); err != store.ErrNoResults {
+		t.Errorf("expected removing a missing bookmark to return ErrNoResults, got %v", err)
+	}
+
+	resource, err := sto.GetResource(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resource.BookmarkCount != 1 {
+		t.Errorf("expected 1 bookmark, got %d", resource.BookmarkCount)
+	}
+
+	bookmarks, err := sto.GetBookmarks(2, store.Page{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bookmarks) != 1 || !bookmarks[0].Bookmarked {
+		t.Errorf("unexpected bookmarks: %+v", bookmarks)
+	}
+}
+
+func TestFeed(t *testing.T) {
+	sto, cleanup := newTestStore(t)
+	defer cleanup()
+
+	follower, _ := sto.CreateUser(store.User{Username: "nate", Password: "testing"})
+	author, _ := sto.CreateUser(store.User{Username: "sam", Password: "testing"})
+	if followed, err := sto.FollowUser(follower, author); err != nil || !followed {
+		t.Fatalf("FollowUser() = %v, %v", followed, err)
+	}
+	if followed, err := sto.FollowUser(follower, author); err != nil || followed {
+		t.Errorf("expected following again to be a no-op, got %v, %v", followed, err)
+	}
+	if err := sto.FollowTag(follower, " Go "); err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := sto.CreateCollection(store.Collection{Owner: author, Title: "First", Visibility: store.VisibilityPublic})
+	sto.CreateCollection(store.Collection{Owner: author, Title: "Hidden", Visibility: store.VisibilityPrivate})
+	deleted, _ := sto.CreateCollection(store.Collection{Owner: author, Title: "Deleted", Visibility: store.VisibilityPublic})
+	sto.DeleteCollection(deleted)
+	// resources show up for followers of their tags whoever submitted them
+	resource, _ := sto.CreateResource(store.Resource{Name: "Go Tour", URL: "https://tour.golang.org", Tags: []string{"go"}})
+	sto.ApproveResource(resource, 1)
+	sto.ApproveResource(resource, 1)
+
+	events, err := sto.GetFeed(follower, store.FeedQuery{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Type != store.EventResourceApproved || events[0].Subject != resource {
+		t.Fatalf("unexpected first page: %+v", events)
+	}
+
+	events, err = sto.GetFeed(follower, store.FeedQuery{Limit: 10, Cursor: events[0].ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Type != store.EventCollectionCreated || events[0].Subject != first {
+		t.Errorf("unexpected second page: %+v", events)
+	}
+
+	follows, err := sto.GetFollows(follower)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(follows.Users) != 1 || follows.Users[0] != author || len(follows.Tags) != 1 || follows.Tags[0] != "go" {
+		t.Errorf("unexpected follows: %+v", follows)
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	sto, cleanup := newTestStore(t)
+	defer cleanup()
+
+	first, _ := sto.CreateUser(store.User{Username: "nate", Password: "testing"})
+	second, _ := sto.CreateUser(store.User{Username: "sam", Password: "testing"})
+
+	// interleave so each user's notifications sit between the other's
+	var ids []int64
+	for _, user := range []int64{first, second, first, second} {
+		id, err := sto.CreateNotification(store.Notification{User: user, Type: store.NotificationFollow})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := sto.MarkNotificationRead(second, ids[0]); err != store.ErrNoResults {
+		t.Errorf("expected marking someone else's notification to return ErrNoResults, got %v", err)
+	}
+	if err := sto.MarkNotificationRead(first, ids[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	notifications, err := sto.GetNotifications(first, store.NotificationQuery{Page: store.Page{Limit: 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notifications) != 2 || notifications[0].ID != ids[2] || notifications[1].ID != ids[0] || !notifications[1].Read {
+		t.Errorf("unexpected notifications: %+v", notifications)
+	}
+
+	if err := sto.MarkAllNotificationsRead(second); err != nil {
+		t.Fatal(err)
+	}
+	for user, expected := range map[int64]int64{first: 1, second: 0} {
+		if unread, err := sto.UnreadNotifications(user); err != nil || unread != expected {
+			t.Errorf("expected %d unread for user %d, got %d (%v)", expected, user, unread, err)
+		}
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	sto, cleanup := newTestStore(t)
+	defer cleanup()
+
+	id, _ := sto.CreateUser(store.User{Username: "nate", Email: "nate@example.com", Password: "testing"})
+
+	expires := time.Now().Add(time.Hour)
+	for _, hash := range []string{"first", "second"} {
+		if err := sto.CreatePasswordReset(store.PasswordReset{TokenHash: hash, User: id, ExpiresAt: expires}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sto.CreatePasswordReset(store.PasswordReset{TokenHash: "old", User: id, ExpiresAt: time.Now().Add(-time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sto.ResetPassword("old", "hash"); err != store.ErrNoResults {
+		t.Errorf("expected an expired reset to return ErrNoResults, got %v", err)
+	}
+	if user, err := sto.ResetPassword("first", "hash"); err != nil || user != id {
+		t.Fatalf("expected the reset to be for user %d, got %d (%v)", id, user, err)
+	}
+	if _, err := sto.ResetPassword("second", "hash"); err != store.ErrNoResults {
+		t.Errorf("expected the user's other resets to be consumed too, got %v", err)
+	}
+
+	if revoked, err := sto.SessionsRevokedAt(id); err != nil || revoked.IsZero() {
+		t.Errorf("expected the user's sessions to be revoked, got %v (%v)", revoked, err)
+	}
+	if user, err := sto.GetUserByEmail("NATE@example.com"); err != nil || user.ID != id {
+		t.Errorf("expected the email to match user %d ignoring case, got %d (%v)", id, user.ID, err)
+	}
+}
+
+func TestSessionRotation(t *testing.T) {
+	sto, cleanup := newTestStore(t)
+	defer cleanup()
+
+	user, _ := sto.CreateUser(store.User{Username: "nate", Password: "testing"})
+	expires := time.Now().Add(time.Hour)
+	id, err := sto.CreateSession(store.Session{User: user, ExpiresAt: expires}, "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := sto.RotateSession("first", "second", expires.Add(time.Hour))
+	if err != nil || session.ID != id || !session.ExpiresAt.Equal(expires.Add(time.Hour)) {
+		t.Fatalf("expected the session to be extended, got %+v (%v)", session, err)
+	}
+	if _, err := sto.RotateSession("unknown", "third", expires); err != store.ErrNoResults {
+		t.Errorf("expected an unknown token to return ErrNoResults, got %v", err)
+	}
+	if _, err := sto.RotateSession("first", "third", expires); err != store.ErrReusedToken {
+		t.Errorf("expected reusing a token to return ErrReusedToken, got %v", err)
+	}
+
+	if session, err := sto.GetSession(id); err != nil || session.RevokedAt == nil {
+		t.Errorf("expected the reuse to revoke the session, got %+v (%v)", session, err)
+	}
+	if _, err := sto.RotateSession("second", "third", expires); err != store.ErrNoResults {
+		t.Errorf("expected the current token of a revoked session to stop working, got %v", err)
+	}
+	if sessions, err := sto.GetSessions(user); err != nil || len(sessions) != 0 {
+		t.Errorf("expected no active sessions, got %+v (%v)", sessions, err)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	sto, cleanup := newTestStore(t)
+	defer cleanup()
+
+	user, _ := sto.CreateUser(store.User{Username: "nate", Password: "testing"})
+	id, err := sto.CreateAPIKey(store.APIKey{User: user, Name: "bulk", Scopes: []string{store.ScopeRead}}, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := time.Now().Add(-time.Minute)
+	sto.CreateAPIKey(store.APIKey{User: user, Name: "old", Scopes: []string{store.ScopeRead}, ExpiresAt: &expired}, "expired")
+
+	key, err := sto.UseAPIKey("hash")
+	if err != nil || key.ID != id || key.LastUsedAt == nil {
+		t.Errorf("UseAPIKey() = %+v, %v", key, err)
+	}
+	if _, err := sto.UseAPIKey("expired"); err != store.ErrNoResults {
+		t.Errorf("expected an expired key to be unusable, got %v", err)
+	}
+
+	if err := sto.DeleteAPIKey(user+1, id); err != store.ErrNoResults {
+		t.Errorf("expected other users to be kept from deleting the key, got %v", err)
+	}
+	if err := sto.DeleteAPIKey(user, id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sto.UseAPIKey("hash"); err != store.ErrNoResults {
+		t.Errorf("expected a deleted key to be unusable, got %v", err)
+	}
+	if keys, _ := sto.GetAPIKeys(user); len(keys) != 1 || keys[0].Name != "old" {
+		t.Errorf("expected only the expired key to be left, got %+v", keys)
+	}
+}
+
+func TestExternalAccounts(t *testing.T) {
+	sto, cleanup := newTestStore(t)
+	defer cleanup()
+
+	nate, _ := sto.CreateUser(store.User{Username: "nate", Password: "testing"})
+	sam, _ := sto.CreateUser(store.User{Username: "sam", Password: "testing"})
+
+	if err := sto.LinkAccount(store.ExternalAccount{Provider: "github", Subject: "1", User: nate}); err != nil {
+		t.Fatal(err)
+	}
+	// providers whose names start the same don't mix up their accounts
+	if err := sto.LinkAccount(store.ExternalAccount{Provider: "git", Subject: "1", User: nate}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sto.LinkAccount(store.ExternalAccount{Provider: "github", Subject: "1", User: sam}); err != store.ErrAccountLinked {
+		t.Errorf("expected an account to only be linked once, got %v", err)
+	}
+	if err := sto.LinkAccount(store.ExternalAccount{Provider: "github", Subject: "2", User: nate}); err != store.ErrAccountLinked {
+		t.Errorf("expected a user to only link one account per provider, got %v", err)
+	}
+
+	if user, err := sto.GetAccountUser("github", "1"); err != nil || user != nate {
+		t.Errorf("GetAccountUser() = %d, %v", user, err)
+	}
+	if err := sto.UnlinkAccount(nate, "git"); err != nil {
+		t.Fatal(err)
+	}
+	if accounts, _ := sto.GetAccounts(nate); len(accounts) != 1 || accounts[0].Provider != "github" {
+		t.Errorf("expected only the github account to be left, got %+v", accounts)
+	}
+
+	// the account is free again once its user is deleted
+	sto.DeleteUser(nate)
+	if err := sto.LinkAccount(store.ExternalAccount{Provider: "github", Subject: "1", User: sam}); err != nil {
+		t.Errorf("expected the account of a deleted user to be linkable, got %v", err)
+	}
+}
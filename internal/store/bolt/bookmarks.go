@@ -0,0 +1,118 @@
+package bolt
+
+import (
+	"bytes"
+	"sort"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+
+	bbolt "go.etcd.io/bbolt"
+)
+
+// Bookmark Functions
+
+func bookmarkKey(user, resourceID int64) []byte {
+	return append(itob(user), itob(resourceID)...)
+}
+
+// countBookmark adjusts the bookmark count stored on a resource
+func countBookmark(tx *bbolt.Tx, resourceID, delta int64) error {
+	b := tx.Bucket(resourcesBucket)
+
+	var resource store.Resource
+	if err := get(b, resourceID, &resource); err != nil {
+		return err
+	}
+	resource.BookmarkCount += delta
+	return put(b, resourceID, resource)
+}
+
+func (s *service) AddBookmark(user, resourceID int64) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		if ok, err := resourceLive(tx, resourceID); err != nil {
+			return err
+		} else if !ok {
+			return store.ErrNoResults
+		}
+
+		b := tx.Bucket(bookmarksBucket)
+		key := bookmarkKey(user, resourceID)
+		if b.Get(key) != nil {
+			return nil
+		}
+
+		if err := b.Put(key, itob(time.Now().UnixNano())); err != nil {
+			return err
+		}
+		return countBookmark(tx, resourceID, 1)
+	})
+}
+
+func (s *service) RemoveBookmark(user, resourceID int64) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bookmarksBucket)
+		key := bookmarkKey(user, resourceID)
+		if b.Get(key) == nil {
+			return store.ErrNoResults
+		}
+
+		if err := b.Delete(key); err != nil {
+			return err
+		}
+		return countBookmark(tx, resourceID, -1)
+	})
+}
+
+// GetBookmarks lists a user's saved resources, most recently saved first
+func (s *service) GetBookmarks(user int64, page store.Page) (resources []store.Resource, err error) {
+	savedAt := make(map[int64]int64)
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(resourcesBucket)
+
+		prefix := itob(user)
+		c := tx.Bucket(bookmarksBucket).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var resource store.Resource
+			if err := get(b, btoi(k[len(prefix):]), &resource); err != nil {
+				return err
+			}
+			if resource.Deleted {
+				continue
+			}
+
+			resource.Bookmarked = true
+			savedAt[resource.ID] = btoi(v)
+			resources = append(resources, resource)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(resources, func(i, j int) bool {
+		a, b := savedAt[resources[i].ID], savedAt[resources[j].ID]
+		if a != b {
+			return a > b
+		}
+		return resources[i].ID > resources[j].ID
+	})
+
+	start, end := page.Paginate(len(resources))
+	return resources[start:end], nil
+}
+
+func (s *service) Bookmarked(user int64, resourceIDs []int64) (bookmarked map[int64]bool, err error) {
+	bookmarked = make(map[int64]bool)
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bookmarksBucket)
+		for _, id := range resourceIDs {
+			if b.Get(bookmarkKey(user, id)) != nil {
+				bookmarked[id] = true
+			}
+		}
+		return nil
+	})
+	return bookmarked, err
+}
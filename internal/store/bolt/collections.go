@@ -0,0 +1,218 @@
+package bolt
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+
+	bbolt "go.etcd.io/bbolt"
+)
+
+// Collection Functions
+
+// collections are stored with their items inline in the Collections bucket
+
+func (s *service) CreateCollection(collection store.Collection) (id int64, err error) {
+	if err := store.ValidateCollection(&collection); err != nil {
+		return id, err
+	}
+	collection.CreatedAt = time.Now()
+	collection.Items = nil
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(collectionsBucket)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		collection.ID = int64(seq)
+
+		if collection.Visibility == store.VisibilityPublic {
+			if err := record(tx, store.EventCollectionCreated, collection.Owner, collection.ID, nil); err != nil {
+				return err
+			}
+		}
+		return put(b, collection.ID, collection)
+	})
+	return collection.ID, err
+}
+
+// liveItems drops items whose resource has been deleted
+func liveItems(tx *bbolt.Tx, items []store.CollectionItem) ([]store.CollectionItem, error) {
+	live := make([]store.CollectionItem, 0, len(items))
+	for _, item := range items {
+		ok, err := resourceLive(tx, item.Resource)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			live = append(live, item)
+		}
+	}
+	return live, nil
+}
+
+func resourceLive(tx *bbolt.Tx, id int64) (bool, error) {
+	var resource store.Resource
+	err := get(tx.Bucket(resourcesBucket), id, &resource)
+	if err == store.ErrNoResults {
+		return false, nil
+	}
+	return err == nil && !resource.Deleted, err
+}
+
+func (s *service) GetCollection(id int64) (collection store.Collection, err error) {
+	collection = store.Collection{ID: id}
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		if err := get(tx.Bucket(collectionsBucket), id, &collection); err != nil {
+			return err
+		}
+
+		collection.Items, err = liveItems(tx, collection.Items)
+		collection.ItemCount = len(collection.Items)
+		return err
+	})
+	return collection, err
+}
+
+// GetCollections lists collections newest first without their items
+func (s *service) GetCollections(query store.CollectionQuery) (collections []store.Collection, err error) {
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(collectionsBucket).Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var collection store.Collection
+			if err := json.Unmarshal(v, &collection); err != nil {
+				return err
+			}
+			if query.Owner != 0 && collection.Owner != query.Owner {
+				continue
+			}
+			if query.PublicOnly && collection.Visibility != store.VisibilityPublic {
+				continue
+			}
+
+			items, err := liveItems(tx, collection.Items)
+			if err != nil {
+				return err
+			}
+			collection.ItemCount = len(items)
+			collection.Items = nil
+			collections = append(collections, collection)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := query.Page.Paginate(len(collections))
+	return collections[start:end], nil
+}
+
+// updateCollection applies change to a stored collection
+func (s *service) updateCollection(id int64, change func(tx *bbolt.Tx, collection *store.Collection) error) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(collectionsBucket)
+
+		var stored store.Collection
+		if err := get(b, id, &stored); err != nil {
+			return err
+		}
+
+		if err := change(tx, &stored); err != nil {
+			return err
+		}
+		return put(b, id, stored)
+	})
+}
+
+// UpdateCollection changes the title, description and visibility, the owner never changes
+func (s *service) UpdateCollection(collection store.Collection) error {
+	if err := store.ValidateCollection(&collection); err != nil {
+		return err
+	}
+
+	return s.updateCollection(collection.ID, func(tx *bbolt.Tx, stored *store.Collection) error {
+		// collections made public later are announced like new ones
+		if stored.Visibility != store.VisibilityPublic && collection.Visibility == store.VisibilityPublic {
+			if err := record(tx, store.EventCollectionCreated, stored.Owner, stored.ID, nil); err != nil {
+				return err
+			}
+		}
+
+		stored.Title = collection.Title
+		stored.Description = collection.Description
+		stored.Visibility = collection.Visibility
+		return nil
+	})
+}
+
+func (s *service) DeleteCollection(id int64) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(collectionsBucket)
+		if b.Get(itob(id)) == nil {
+			return store.ErrNoResults
+		}
+		return b.Delete(itob(id))
+	})
+}
+
+func (s *service) AddCollectionItem(collectionID int64, item store.CollectionItem) error {
+	if err := store.ValidateCollectionItems([]store.CollectionItem{item}); err != nil {
+		return err
+	}
+
+	return s.updateCollection(collectionID, func(tx *bbolt.Tx, collection *store.Collection) error {
+		if ok, err := resourceLive(tx, item.Resource); err != nil {
+			return err
+		} else if !ok {
+			return store.ErrNoResults
+		}
+
+		for i := range collection.Items {
+			if collection.Items[i].Resource == item.Resource {
+				collection.Items[i].Note = item.Note
+				return nil
+			}
+		}
+		collection.Items = append(collection.Items, item)
+		return nil
+	})
+}
+
+func (s *service) RemoveCollectionItem(collectionID, resourceID int64) error {
+	return s.updateCollection(collectionID, func(tx *bbolt.Tx, collection *store.Collection) error {
+		items := make([]store.CollectionItem, 0, len(collection.Items))
+		for _, item := range collection.Items {
+			if item.Resource != resourceID {
+				items = append(items, item)
+			}
+		}
+		if len(items) == len(collection.Items) {
+			return store.ErrNoResults
+		}
+
+		collection.Items = items
+		return nil
+	})
+}
+
+func (s *service) SetCollectionItems(collectionID int64, items []store.CollectionItem) error {
+	if err := store.ValidateCollectionItems(items); err != nil {
+		return err
+	}
+
+	return s.updateCollection(collectionID, func(tx *bbolt.Tx, collection *store.Collection) error {
+		for _, item := range items {
+			if ok, err := resourceLive(tx, item.Resource); err != nil {
+				return err
+			} else if !ok {
+				return store.ErrNoResults
+			}
+		}
+
+		collection.Items = items
+		return nil
+	})
+}
@@ -0,0 +1,125 @@
+package bolt
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+
+	bbolt "go.etcd.io/bbolt"
+)
+
+// Comment Functions
+
+func (s *service) CreateComment(comment store.Comment) (id int64, err error) {
+	if err := store.ValidateCommentBody(comment.Body); err != nil {
+		return id, err
+	}
+	comment.Thread, comment.Depth = 0, 0
+	comment.CreatedAt = time.Now()
+	comment.EditedAt = nil
+	comment.Deleted = false
+	comment.Replies = nil
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		if ok, err := resourceLive(tx, comment.Resource); err != nil {
+			return err
+		} else if !ok {
+			return store.ErrNoResults
+		}
+
+		b := tx.Bucket(commentsBucket)
+		if comment.Parent != 0 {
+			var parent store.Comment
+			if err := get(b, comment.Parent, &parent); err != nil {
+				return err
+			}
+			if err := comment.Reply(parent); err != nil {
+				return err
+			}
+		}
+
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		comment.ID = int64(seq)
+		if comment.Thread == 0 {
+			comment.Thread = comment.ID
+		}
+
+		if err := put(b, comment.ID, comment); err != nil {
+			return err
+		}
+		return tx.Bucket(resourceCommentsIndexBucket).Put(append(itob(comment.Resource), itob(comment.ID)...), []byte{})
+	})
+	return comment.ID, err
+}
+
+func (s *service) GetComment(id int64) (comment store.Comment, err error) {
+	comment = store.Comment{ID: id}
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		return get(tx.Bucket(commentsBucket), id, &comment)
+	})
+	return comment, err
+}
+
+func (s *service) GetComments(resourceID int64, page store.Page) (threads []store.Comment, err error) {
+	var comments []store.Comment
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(commentsBucket)
+
+		prefix := itob(resourceID)
+		c := tx.Bucket(resourceCommentsIndexBucket).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			var comment store.Comment
+			if err := get(b, btoi(k[len(prefix):]), &comment); err != nil {
+				return err
+			}
+			comments = append(comments, comment)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return store.PageThreads(comments, page), nil
+}
+
+// updateComment applies change to a comment that hasn't been deleted
+func (s *service) updateComment(id int64, change func(comment *store.Comment)) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(commentsBucket)
+
+		var comment store.Comment
+		if err := get(b, id, &comment); err != nil {
+			return err
+		}
+		if comment.Deleted {
+			return store.ErrNoResults
+		}
+
+		change(&comment)
+		return put(b, id, comment)
+	})
+}
+
+func (s *service) UpdateComment(id int64, body string) error {
+	if err := store.ValidateCommentBody(body); err != nil {
+		return err
+	}
+
+	return s.updateComment(id, func(comment *store.Comment) {
+		now := time.Now()
+		comment.Body = body
+		comment.EditedAt = &now
+	})
+}
+
+func (s *service) DeleteComment(id int64) error {
+	return s.updateComment(id, func(comment *store.Comment) {
+		comment.Deleted = true
+		comment.Body = ""
+	})
+}
@@ -0,0 +1,247 @@
+package bolt
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+
+	bbolt "go.etcd.io/bbolt"
+)
+
+// Curriculum Functions
+
+// curriculums are stored as one document holding every section and step in the
+// Curriculums bucket, steps keep pointing at deleted resources so the owner can see
+// what needs replacing
+
+func (s *service) CreateCurriculum(curriculum store.Curriculum) (id int64, err error) {
+	if err := store.ValidateCurriculum(curriculum); err != nil {
+		return id, err
+	}
+	curriculum.CreatedAt = time.Now()
+	curriculum.Sections = nil
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(curriculumsBucket)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		curriculum.ID = int64(seq)
+
+		if err := record(tx, store.EventCurriculumCreated, curriculum.Owner, curriculum.ID, nil); err != nil {
+			return err
+		}
+		return put(b, curriculum.ID, curriculum)
+	})
+	return curriculum.ID, err
+}
+
+func (s *service) GetCurriculum(id int64) (curriculum store.Curriculum, err error) {
+	curriculum = store.Curriculum{ID: id}
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		return get(tx.Bucket(curriculumsBucket), id, &curriculum)
+	})
+	curriculum.Totals()
+	return curriculum, err
+}
+
+// GetCurriculums lists curriculums newest first without their sections
+func (s *service) GetCurriculums(query store.CurriculumQuery) (curriculums []store.Curriculum, err error) {
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(curriculumsBucket).Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var curriculum store.Curriculum
+			if err := json.Unmarshal(v, &curriculum); err != nil {
+				return err
+			}
+			if query.Owner != 0 && curriculum.Owner != query.Owner {
+				continue
+			}
+
+			curriculum.Totals()
+			curriculum.Sections = nil
+			curriculums = append(curriculums, curriculum)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := query.Page.Paginate(len(curriculums))
+	return curriculums[start:end], nil
+}
+
+// updateCurriculum applies change to a stored curriculum
+func (s *service) updateCurriculum(id int64, change func(tx *bbolt.Tx, curriculum *store.Curriculum) error) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(curriculumsBucket)
+
+		var stored store.Curriculum
+		if err := get(b, id, &stored); err != nil {
+			return err
+		}
+
+		if err := change(tx, &stored); err != nil {
+			return err
+		}
+		return put(b, id, stored)
+	})
+}
+
+// nextCurriculumNodeID hands out an ID for a new section or step
+func nextCurriculumNodeID(tx *bbolt.Tx) (int64, error) {
+	seq, err := tx.Bucket(curriculumNodesBucket).NextSequence()
+	return int64(seq), err
+}
+
+// UpdateCurriculum changes the title and description, the owner never changes
+func (s *service) UpdateCurriculum(curriculum store.Curriculum) error {
+	if err := store.ValidateCurriculum(curriculum); err != nil {
+		return err
+	}
+
+	return s.updateCurriculum(curriculum.ID, func(tx *bbolt.Tx, stored *store.Curriculum) error {
+		stored.Title = curriculum.Title
+		stored.Description = curriculum.Description
+		return nil
+	})
+}
+
+func (s *service) DeleteCurriculum(id int64) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(curriculumsBucket)
+		if b.Get(itob(id)) == nil {
+			return store.ErrNoResults
+		}
+		return b.Delete(itob(id))
+	})
+}
+
+// Curriculum Section Functions
+
+func (s *service) AddCurriculumSection(curriculumID int64, section store.Section) (id int64, err error) {
+	if err := store.ValidateSection(section); err != nil {
+		return id, err
+	}
+
+	err = s.updateCurriculum(curriculumID, func(tx *bbolt.Tx, curriculum *store.Curriculum) error {
+		id, err = nextCurriculumNodeID(tx)
+		if err != nil {
+			return err
+		}
+
+		curriculum.Sections = append(curriculum.Sections, store.Section{
+			ID:          id,
+			Title:       section.Title,
+			Description: section.Description,
+			Steps:       []store.Step{},
+		})
+		return nil
+	})
+	return id, err
+}
+
+func (s *service) UpdateCurriculumSection(curriculumID int64, section store.Section) error {
+	if err := store.ValidateSection(section); err != nil {
+		return err
+	}
+
+	return s.updateCurriculum(curriculumID, func(tx *bbolt.Tx, curriculum *store.Curriculum) error {
+		stored, err := curriculum.FindSection(section.ID)
+		if err != nil {
+			return err
+		}
+		stored.Title = section.Title
+		stored.Description = section.Description
+		return nil
+	})
+}
+
+func (s *service) DeleteCurriculumSection(curriculumID, sectionID int64) error {
+	return s.updateCurriculum(curriculumID, func(tx *bbolt.Tx, curriculum *store.Curriculum) error {
+		return curriculum.RemoveSection(sectionID)
+	})
+}
+
+func (s *service) OrderCurriculumSections(curriculumID int64, order []int64) error {
+	return s.updateCurriculum(curriculumID, func(tx *bbolt.Tx, curriculum *store.Curriculum) error {
+		return curriculum.OrderSections(order)
+	})
+}
+
+// Curriculum Step Functions
+
+func (s *service) AddCurriculumStep(curriculumID, sectionID int64, step store.Step) (id int64, err error) {
+	if err := store.ValidateStep(step); err != nil {
+		return id, err
+	}
+
+	err = s.updateCurriculum(curriculumID, func(tx *bbolt.Tx, curriculum *store.Curriculum) error {
+		section, err := curriculum.FindSection(sectionID)
+		if err != nil {
+			return err
+		}
+		if ok, err := resourceLive(tx, step.Resource); err != nil {
+			return err
+		} else if !ok {
+			return store.ErrNoResults
+		}
+
+		id, err = nextCurriculumNodeID(tx)
+		if err != nil {
+			return err
+		}
+		step.ID = id
+		section.Steps = append(section.Steps, step)
+		return record(tx, store.EventCurriculumUpdated, curriculum.Owner, curriculum.ID, nil)
+	})
+	return id, err
+}
+
+func (s *service) UpdateCurriculumStep(curriculumID, sectionID int64, step store.Step) error {
+	if err := store.ValidateStep(step); err != nil {
+		return err
+	}
+
+	return s.updateCurriculum(curriculumID, func(tx *bbolt.Tx, curriculum *store.Curriculum) error {
+		section, err := curriculum.FindSection(sectionID)
+		if err != nil {
+			return err
+		}
+		stored, err := section.FindStep(step.ID)
+		if err != nil {
+			return err
+		}
+		if ok, err := resourceLive(tx, step.Resource); err != nil {
+			return err
+		} else if !ok {
+			return store.ErrNoResults
+		}
+
+		*stored = step
+		return nil
+	})
+}
+
+func (s *service) DeleteCurriculumStep(curriculumID, sectionID, stepID int64) error {
+	return s.updateCurriculum(curriculumID, func(tx *bbolt.Tx, curriculum *store.Curriculum) error {
+		section, err := curriculum.FindSection(sectionID)
+		if err != nil {
+			return err
+		}
+		return section.RemoveStep(stepID)
+	})
+}
+
+func (s *service) OrderCurriculumSteps(curriculumID, sectionID int64, order []int64) error {
+	return s.updateCurriculum(curriculumID, func(tx *bbolt.Tx, curriculum *store.Curriculum) error {
+		section, err := curriculum.FindSection(sectionID)
+		if err != nil {
+			return err
+		}
+		return section.OrderSteps(order)
+	})
+}
@@ -0,0 +1,194 @@
+package bolt
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+
+	bbolt "go.etcd.io/bbolt"
+)
+
+// Follow Functions
+
+func followedUserKey(follower, user int64) []byte {
+	return append(itob(follower), itob(user)...)
+}
+
+func followedTagKey(follower int64, tag string) []byte {
+	return append(itob(follower), tag...)
+}
+
+// normalizeTag normalizes a single tag the way tags on resources are
+func normalizeTag(tag string) (string, error) {
+	tags, err := store.NormalizeTags([]string{tag})
+	if err != nil {
+		return "", err
+	}
+	return tags[0], nil
+}
+
+func (s *service) FollowUser(follower, user int64) (followed bool, err error) {
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		if tx.Bucket(usersBucket).Get(itob(user)) == nil {
+			return store.ErrNoResults
+		}
+
+		b := tx.Bucket(followedUsersBucket)
+		key := followedUserKey(follower, user)
+		if b.Get(key) != nil {
+			return nil
+		}
+		followed = true
+		return b.Put(key, itob(time.Now().UnixNano()))
+	})
+	return followed && err == nil, err
+}
+
+func (s *service) UnfollowUser(follower, user int64) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return deleteKey(tx.Bucket(followedUsersBucket), followedUserKey(follower, user))
+	})
+}
+
+func (s *service) FollowTag(follower int64, tag string) error {
+	tag, err := normalizeTag(tag)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(followedTagsBucket)
+		key := followedTagKey(follower, tag)
+		if b.Get(key) != nil {
+			return nil
+		}
+		return b.Put(key, itob(time.Now().UnixNano()))
+	})
+}
+
+func (s *service) UnfollowTag(follower int64, tag string) error {
+	tag, err := normalizeTag(tag)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return deleteKey(tx.Bucket(followedTagsBucket), followedTagKey(follower, tag))
+	})
+}
+
+// deleteKey deletes a key, returning store.ErrNoResults when it's missing
+func deleteKey(b *bbolt.Bucket, key []byte) error {
+	if b.Get(key) == nil {
+		return store.ErrNoResults
+	}
+	return b.Delete(key)
+}
+
+func (s *service) GetFollows(follower int64) (follows store.Follows, err error) {
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		follows = getFollows(tx, follower)
+		return nil
+	})
+	return follows, err
+}
+
+// getFollows reads what follower follows, keys sort users by ID and tags by name
+func getFollows(tx *bbolt.Tx, follower int64) store.Follows {
+	follows := store.Follows{Users: []int64{}, Tags: []string{}}
+	prefix := itob(follower)
+
+	c := tx.Bucket(followedUsersBucket).Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		follows.Users = append(follows.Users, btoi(k[len(prefix):]))
+	}
+
+	c = tx.Bucket(followedTagsBucket).Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		follows.Tags = append(follows.Tags, string(k[len(prefix):]))
+	}
+	return follows
+}
+
+// Feed Functions
+
+// record appends an event to the activity log
+func record(tx *bbolt.Tx, eventType string, actor, subject int64, tags []string) error {
+	b := tx.Bucket(eventsBucket)
+	seq, err := b.NextSequence()
+	if err != nil {
+		return err
+	}
+
+	return put(b, int64(seq), store.Event{
+		ID:        int64(seq),
+		Type:      eventType,
+		Actor:     actor,
+		Subject:   subject,
+		Tags:      tags,
+		CreatedAt: time.Now(),
+	})
+}
+
+// eventLive reports whether the subject of an event can still be shown
+func eventLive(tx *bbolt.Tx, event store.Event) (bool, error) {
+	var err error
+	switch event.Type {
+	case store.EventResourceApproved:
+		var resource store.Resource
+		if err = get(tx.Bucket(resourcesBucket), event.Subject, &resource); err == nil {
+			return resource.Approved && !resource.Deleted, nil
+		}
+	case store.EventCollectionCreated:
+		var collection store.Collection
+		if err = get(tx.Bucket(collectionsBucket), event.Subject, &collection); err == nil {
+			return collection.Visibility == store.VisibilityPublic, nil
+		}
+	case store.EventCurriculumCreated, store.EventCurriculumUpdated:
+		return tx.Bucket(curriculumsBucket).Get(itob(event.Subject)) != nil, nil
+	}
+	if err == store.ErrNoResults {
+		err = nil
+	}
+	return false, err
+}
+
+// GetFeed walks the log backwards from the cursor
+func (s *service) GetFeed(user int64, query store.FeedQuery) (events []store.Event, err error) {
+	events = []store.Event{}
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		follows := getFollows(tx, user)
+
+		c := tx.Bucket(eventsBucket).Cursor()
+		k, v := c.Last()
+		if query.Cursor != 0 {
+			// start just before the cursor, or at the end when the cursor is past it
+			if k, _ = c.Seek(itob(query.Cursor)); k != nil {
+				k, v = c.Prev()
+			} else {
+				k, v = c.Last()
+			}
+		}
+		for ; k != nil && len(events) < query.Limit; k, v = c.Prev() {
+			var event store.Event
+			if err := json.Unmarshal(v, &event); err != nil {
+				return err
+			}
+			if !follows.Match(event) {
+				continue
+			}
+
+			live, err := eventLive(tx, event)
+			if err != nil {
+				return err
+			}
+			if live {
+				events = append(events, event)
+			}
+		}
+		return nil
+	})
+	return events, err
+}
@@ -0,0 +1,168 @@
+package bolt
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+
+	bbolt "go.etcd.io/bbolt"
+)
+
+// Notification Functions
+
+func notificationKey(user, id int64) []byte {
+	return append(itob(user), itob(id)...)
+}
+
+func (s *service) CreateNotification(notification store.Notification) (id int64, err error) {
+	notification.Read = false
+	notification.CreatedAt = time.Now()
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		if tx.Bucket(usersBucket).Get(itob(notification.User)) == nil {
+			return store.ErrNoResults
+		}
+
+		b := tx.Bucket(notificationsBucket)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		notification.ID = int64(seq)
+
+		data, err := json.Marshal(notification)
+		if err != nil {
+			return err
+		}
+		return b.Put(notificationKey(notification.User, notification.ID), data)
+	})
+	return notification.ID, err
+}
+
+// eachNotification calls fn with a user's notifications, newest first, until it returns false
+func eachNotification(tx *bbolt.Tx, user int64, fn func(notification store.Notification) bool) error {
+	prefix := itob(user)
+	c := tx.Bucket(notificationsBucket).Cursor()
+
+	// start at the last key of the user, or at the end when they're the last user
+	k, v := c.Seek(itob(user + 1))
+	if k == nil {
+		k, v = c.Last()
+	} else {
+		k, v = c.Prev()
+	}
+	for ; k != nil && bytes.HasPrefix(k, prefix); k, v = c.Prev() {
+		var notification store.Notification
+		if err := json.Unmarshal(v, &notification); err != nil {
+			return err
+		}
+		notification.User = user
+		if !fn(notification) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// GetNotifications lists a user's notifications, newest first
+func (s *service) GetNotifications(user int64, query store.NotificationQuery) (notifications []store.Notification, err error) {
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		return eachNotification(tx, user, func(notification store.Notification) bool {
+			if !query.UnreadOnly || !notification.Read {
+				notifications = append(notifications, notification)
+			}
+			return true
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := query.Page.Paginate(len(notifications))
+	return notifications[start:end], nil
+}
+
+func (s *service) UnreadNotifications(user int64) (unread int64, err error) {
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		return eachNotification(tx, user, func(notification store.Notification) bool {
+			if !notification.Read {
+				unread++
+			}
+			return true
+		})
+	})
+	return unread, err
+}
+
+// markRead marks the notification stored under key as read
+func markRead(b *bbolt.Bucket, key []byte) error {
+	data := b.Get(key)
+	if data == nil {
+		return store.ErrNoResults
+	}
+
+	var notification store.Notification
+	if err := json.Unmarshal(data, &notification); err != nil {
+		return err
+	}
+	if notification.Read {
+		return nil
+	}
+
+	notification.Read = true
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	return b.Put(key, data)
+}
+
+func (s *service) MarkNotificationRead(user, id int64) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return markRead(tx.Bucket(notificationsBucket), notificationKey(user, id))
+	})
+}
+
+func (s *service) MarkAllNotificationsRead(user int64) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		var unread [][]byte
+		err := eachNotification(tx, user, func(notification store.Notification) bool {
+			if !notification.Read {
+				unread = append(unread, notificationKey(user, notification.ID))
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+
+		// keys are collected first since a bucket can't be written to while a cursor walks it
+		b := tx.Bucket(notificationsBucket)
+		for _, key := range unread {
+			if err := markRead(b, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *service) SetNotificationPreferences(id int64, preferences store.NotificationPreferences) error {
+	if err := store.ValidateNotificationPreferences(preferences); err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(usersBucket)
+
+		var stored store.User
+		if err := get(b, id, &stored); err != nil {
+			return err
+		}
+
+		stored.Notifications = preferences
+		return put(b, id, stored)
+	})
+}
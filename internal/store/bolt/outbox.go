@@ -0,0 +1,87 @@
+package bolt
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+
+	bbolt "go.etcd.io/bbolt"
+)
+
+// Outbox Functions
+
+func (s *service) QueueMail(mail store.OutboxMail) (id int64, err error) {
+	mail.Attempts = 0
+	mail.LastError = ""
+	mail.CreatedAt = time.Now()
+	mail.NextAttemptAt = mail.CreatedAt
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(outboxBucket)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		mail.ID = int64(seq)
+		return put(b, mail.ID, mail)
+	})
+	return mail.ID, err
+}
+
+func (s *service) ClaimMail(limit int, lease time.Duration) (due []store.OutboxMail, err error) {
+	now := time.Now()
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(outboxBucket)
+		c := b.Cursor()
+		for k, v := c.First(); k != nil && len(due) < limit; k, v = c.Next() {
+			var mail store.OutboxMail
+			if err := json.Unmarshal(v, &mail); err != nil {
+				return err
+			}
+			if !mail.NextAttemptAt.IsZero() && !mail.NextAttemptAt.After(now) {
+				due = append(due, mail)
+			}
+		}
+
+		// written after the walk since a bucket can't be written to while a cursor walks it
+		for i := range due {
+			due[i].Attempts++
+			due[i].NextAttemptAt = now.Add(lease)
+			if err := put(b, due[i].ID, due[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return due, nil
+}
+
+func (s *service) DeleteMail(id int64) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(outboxBucket)
+		if b.Get(itob(id)) == nil {
+			return store.ErrNoResults
+		}
+		return b.Delete(itob(id))
+	})
+}
+
+func (s *service) RetryMail(id int64, lastError string, retryAt time.Time) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(outboxBucket)
+
+		var mail store.OutboxMail
+		if err := get(b, id, &mail); err != nil {
+			return err
+		}
+
+		mail.LastError = lastError
+		mail.NextAttemptAt = retryAt
+		return put(b, id, mail)
+	})
+}
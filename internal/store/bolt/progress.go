@@ -0,0 +1,74 @@
+package bolt
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+
+	bbolt "go.etcd.io/bbolt"
+)
+
+// Progress Functions
+
+// progressKey groups progress by learner and then step
+func progressKey(learner, stepID int64) []byte {
+	return append(itob(learner), itob(stepID)...)
+}
+
+func (s *service) SetStepProgress(learner, curriculumID, stepID int64, status string) error {
+	if err := store.ValidateProgressStatus(status); err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		var curriculum store.Curriculum
+		if err := get(tx.Bucket(curriculumsBucket), curriculumID, &curriculum); err != nil {
+			return err
+		}
+		if _, err := curriculum.FindStep(stepID); err != nil {
+			return err
+		}
+
+		b := tx.Bucket(curriculumProgressBucket)
+		key := progressKey(learner, stepID)
+
+		var existing *store.StepProgress
+		if data := b.Get(key); data != nil {
+			existing = &store.StepProgress{}
+			if err := json.Unmarshal(data, existing); err != nil {
+				return err
+			}
+		}
+
+		progress := store.MarkProgress(existing, status, time.Now())
+		progress.Curriculum, progress.Step = curriculumID, stepID
+		data, err := json.Marshal(progress)
+		if err != nil {
+			return err
+		}
+		return b.Put(key, data)
+	})
+}
+
+func (s *service) GetStepProgress(learner, curriculumID int64) (progress []store.StepProgress, err error) {
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		prefix := itob(learner)
+		c := tx.Bucket(curriculumProgressBucket).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var p store.StepProgress
+			if err := json.Unmarshal(v, &p); err != nil {
+				return err
+			}
+			if curriculumID != 0 && p.Curriculum != curriculumID {
+				continue
+			}
+
+			p.Learner = learner
+			progress = append(progress, p)
+		}
+		return nil
+	})
+	return progress, err
+}
@@ -0,0 +1,122 @@
+package bolt
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+
+	bbolt "go.etcd.io/bbolt"
+)
+
+// Password Reset Functions
+
+// deleteResets removes every reset for which drop returns true
+func deleteResets(b *bbolt.Bucket, drop func(reset store.PasswordReset) bool) error {
+	// keys are collected first since a bucket can't be written to while it's iterated
+	var keys [][]byte
+	err := b.ForEach(func(k, v []byte) error {
+		var reset store.PasswordReset
+		if err := json.Unmarshal(v, &reset); err != nil {
+			return err
+		}
+		if drop(reset) {
+			keys = append(keys, k)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := b.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *service) CreatePasswordReset(reset store.PasswordReset) error {
+	now := time.Now()
+	reset.CreatedAt = now
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		if tx.Bucket(usersBucket).Get(itob(reset.User)) == nil {
+			return store.ErrNoResults
+		}
+
+		b := tx.Bucket(passwordResetsBucket)
+		err := deleteResets(b, func(stored store.PasswordReset) bool {
+			return now.After(stored.ExpiresAt)
+		})
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(reset)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(reset.TokenHash), data)
+	})
+}
+
+func (s *service) ResetPassword(tokenHash, passwordHash string) (id int64, err error) {
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(passwordResetsBucket)
+		now := time.Now()
+
+		data := b.Get([]byte(tokenHash))
+		if data == nil {
+			return store.ErrNoResults
+		}
+		var reset store.PasswordReset
+		if err := json.Unmarshal(data, &reset); err != nil {
+			return err
+		}
+		if now.After(reset.ExpiresAt) {
+			return store.ErrNoResults
+		}
+
+		users := tx.Bucket(usersBucket)
+		var user store.User
+		if err := get(users, reset.User, &user); err != nil {
+			return err
+		}
+
+		err := deleteResets(b, func(stored store.PasswordReset) bool {
+			return stored.User == reset.User
+		})
+		if err != nil {
+			return err
+		}
+
+		user.PasswordHash = passwordHash
+		if err := put(users, user.ID, user); err != nil {
+			return err
+		}
+
+		if err := revokeSessions(tx, user.ID, now); err != nil {
+			return err
+		}
+
+		id = user.ID
+		return tx.Bucket(sessionsRevokedBucket).Put(itob(user.ID), itob(now.UnixNano()))
+	})
+	return id, err
+}
+
+func (s *service) SessionsRevokedAt(user int64) (revoked time.Time, err error) {
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		if tx.Bucket(usersBucket).Get(itob(user)) == nil {
+			return store.ErrNoResults
+		}
+
+		if at := tx.Bucket(sessionsRevokedBucket).Get(itob(user)); at != nil {
+			revoked = time.Unix(0, btoi(at))
+		}
+		return nil
+	})
+	return revoked, err
+}
@@ -0,0 +1,133 @@
+package bolt
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+
+	bbolt "go.etcd.io/bbolt"
+)
+
+// Review Functions
+
+// reviewKey groups reviews by resource and then reviewer
+func reviewKey(resourceID, reviewer int64) []byte {
+	return append(itob(resourceID), itob(reviewer)...)
+}
+
+// loadReviews reads every review of a resource
+func loadReviews(tx *bbolt.Tx, resourceID int64) (reviews []store.Review, err error) {
+	prefix := itob(resourceID)
+	c := tx.Bucket(reviewsBucket).Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		var review store.Review
+		if err := json.Unmarshal(v, &review); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews, nil
+}
+
+// updateRating recomputes the average rating stored on a resource
+func updateRating(tx *bbolt.Tx, resourceID int64) error {
+	reviews, err := loadReviews(tx, resourceID)
+	if err != nil {
+		return err
+	}
+
+	ratings := make([]int, len(reviews))
+	for i, review := range reviews {
+		ratings[i] = review.Rating
+	}
+
+	b := tx.Bucket(resourcesBucket)
+	var resource store.Resource
+	if err := get(b, resourceID, &resource); err != nil {
+		return err
+	}
+	resource.Rating, resource.RatingCount = store.AverageRating(ratings)
+	return put(b, resourceID, resource)
+}
+
+func (s *service) SetReview(review store.Review) error {
+	if err := store.ValidateReview(review); err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		if ok, err := resourceLive(tx, review.Resource); err != nil {
+			return err
+		} else if !ok {
+			return store.ErrNoResults
+		}
+
+		b := tx.Bucket(reviewsBucket)
+		key := reviewKey(review.Resource, review.Reviewer)
+
+		review.UpdatedAt = time.Now()
+		review.CreatedAt = review.UpdatedAt
+		if data := b.Get(key); data != nil {
+			var existing store.Review
+			if err := json.Unmarshal(data, &existing); err != nil {
+				return err
+			}
+			review.CreatedAt = existing.CreatedAt
+		}
+
+		data, err := json.Marshal(review)
+		if err != nil {
+			return err
+		}
+		if err := b.Put(key, data); err != nil {
+			return err
+		}
+		return updateRating(tx, review.Resource)
+	})
+}
+
+func (s *service) GetReview(resourceID, reviewer int64) (review store.Review, err error) {
+	review = store.Review{Resource: resourceID, Reviewer: reviewer}
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(reviewsBucket).Get(reviewKey(resourceID, reviewer))
+		if data == nil {
+			return store.ErrNoResults
+		}
+		return json.Unmarshal(data, &review)
+	})
+	return review, err
+}
+
+// GetReviews lists the reviews of a resource, most recently updated first
+func (s *service) GetReviews(resourceID int64, page store.Page) (reviews []store.Review, err error) {
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		reviews, err = loadReviews(tx, resourceID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(reviews, func(i, j int) bool { return reviews[i].UpdatedAt.After(reviews[j].UpdatedAt) })
+
+	start, end := page.Paginate(len(reviews))
+	return reviews[start:end], nil
+}
+
+func (s *service) DeleteReview(resourceID, reviewer int64) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(reviewsBucket)
+		key := reviewKey(resourceID, reviewer)
+		if b.Get(key) == nil {
+			return store.ErrNoResults
+		}
+
+		if err := b.Delete(key); err != nil {
+			return err
+		}
+		return updateRating(tx, resourceID)
+	})
+}
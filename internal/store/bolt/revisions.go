@@ -0,0 +1,130 @@
+package bolt
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+
+	"github.com/pkg/errors"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// revisionKey sorts revisions by resource and then revision number
+func revisionKey(resourceID, revision int64) []byte {
+	return append(itob(resourceID), itob(revision)...)
+}
+
+// loadRevisions reads the history of a resource in revision order
+func loadRevisions(tx *bbolt.Tx, resourceID int64) (revisions []store.Revision, err error) {
+	prefix := itob(resourceID)
+	c := tx.Bucket(resourceRevisionsBucket).Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		var revision store.Revision
+		if err := json.Unmarshal(v, &revision); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, nil
+}
+
+func recordRevision(tx *bbolt.Tx, resourceID, editor int64, changes map[string]store.Change) error {
+	revisions, err := loadRevisions(tx, resourceID)
+	if err != nil {
+		return err
+	}
+
+	revision := store.Revision{
+		Revision:  int64(len(revisions) + 1),
+		Resource:  resourceID,
+		Editor:    editor,
+		CreatedAt: time.Now(),
+		Changes:   changes,
+	}
+
+	data, err := json.Marshal(revision)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(resourceRevisionsBucket).Put(revisionKey(resourceID, revision.Revision), data)
+}
+
+// updateContent writes the editable fields of content to a live resource and records what changed
+func updateContent(tx *bbolt.Tx, id int64, content store.Resource, editor int64) error {
+	b := tx.Bucket(resourcesBucket)
+	index := tx.Bucket(resourceURLIndexBucket)
+
+	var stored store.Resource
+	if err := get(b, id, &stored); err != nil {
+		return err
+	}
+	if stored.Deleted {
+		return store.ErrNoResults
+	}
+
+	changes := store.Diff(stored, content)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	if content.URL != stored.URL {
+		if index.Get([]byte(content.URL)) != nil {
+			return errors.New("Resource URL already exists")
+		}
+		if err := index.Delete([]byte(stored.URL)); err != nil {
+			return err
+		}
+		if err := index.Put([]byte(content.URL), itob(id)); err != nil {
+			return err
+		}
+	}
+
+	if err := put(b, id, store.WithContent(stored, content)); err != nil {
+		return err
+	}
+	return recordRevision(tx, id, editor, changes)
+}
+
+// UpdateResource edits the name, description and url of a resource as editor, the original
+// submitter always stays the same and every change is kept as a revision
+func (s *service) UpdateResource(resource store.Resource, editor int64) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return updateContent(tx, resource.ID, resource, editor)
+	})
+}
+
+func (s *service) GetRevisions(resourceID int64) (revisions []store.Revision, err error) {
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		var resource store.Resource
+		if err := get(tx.Bucket(resourcesBucket), resourceID, &resource); err != nil {
+			return err
+		}
+		if resource.Deleted {
+			return store.ErrNoResults
+		}
+
+		revisions, err = loadRevisions(tx, resourceID)
+		return err
+	})
+	return revisions, err
+}
+
+// RevertResource restores the content a resource had at revision, the revert is recorded as
+// a new revision so it can be undone as well
+func (s *service) RevertResource(resourceID, revision, editor int64) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		history, err := loadRevisions(tx, resourceID)
+		if err != nil {
+			return err
+		}
+
+		content, err := store.Replay(history, revision)
+		if err != nil {
+			return err
+		}
+
+		return updateContent(tx, resourceID, content, editor)
+	})
+}
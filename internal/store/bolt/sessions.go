@@ -0,0 +1,213 @@
+package bolt
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+
+	bbolt "go.etcd.io/bbolt"
+)
+
+// refreshToken is what's kept of a refresh token, tokens that were rotated out are kept to
+// catch them being used again
+type refreshToken struct {
+	Session int64 `json:"session"`
+	Rotated bool  `json:"rotated"`
+}
+
+// Session Functions
+
+func (s *service) CreateSession(session store.Session, tokenHash string) (id int64, err error) {
+	session.CreatedAt = time.Now()
+	session.LastUsedAt = session.CreatedAt
+	session.RevokedAt = nil
+	session.Current = false
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		if tx.Bucket(usersBucket).Get(itob(session.User)) == nil {
+			return store.ErrNoResults
+		}
+
+		b := tx.Bucket(sessionsBucket)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		session.ID = int64(seq)
+		if err := put(b, session.ID, session); err != nil {
+			return err
+		}
+
+		return putRefreshToken(tx, tokenHash, refreshToken{Session: session.ID})
+	})
+	return session.ID, err
+}
+
+func putRefreshToken(tx *bbolt.Tx, tokenHash string, token refreshToken) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(refreshTokensBucket).Put([]byte(tokenHash), data)
+}
+
+// getRefreshToken returns store.ErrNoResults for unknown tokens
+func getRefreshToken(tx *bbolt.Tx, tokenHash string) (token refreshToken, err error) {
+	data := tx.Bucket(refreshTokensBucket).Get([]byte(tokenHash))
+	if data == nil {
+		return token, store.ErrNoResults
+	}
+	err = json.Unmarshal(data, &token)
+	return token, err
+}
+
+func (s *service) GetSession(id int64) (session store.Session, err error) {
+	session = store.Session{ID: id}
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		return get(tx.Bucket(sessionsBucket), id, &session)
+	})
+	return session, err
+}
+
+func (s *service) GetSessions(user int64) (sessions []store.Session, err error) {
+	now := time.Now()
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(sessionsBucket).ForEach(func(k, v []byte) error {
+			var session store.Session
+			if err := json.Unmarshal(v, &session); err != nil {
+				return err
+			}
+			if session.User == user && session.Active(now) {
+				sessions = append(sessions, session)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].LastUsedAt.Equal(sessions[j].LastUsedAt) {
+			return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+		}
+		return sessions[i].ID > sessions[j].ID
+	})
+	return sessions, nil
+}
+
+func (s *service) RotateSession(tokenHash, newHash string, expiresAt time.Time) (session store.Session, err error) {
+	now := time.Now()
+	reused := false
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		token, err := getRefreshToken(tx, tokenHash)
+		if err != nil {
+			return err
+		}
+
+		b := tx.Bucket(sessionsBucket)
+		if err := get(b, token.Session, &session); err != nil {
+			return err
+		}
+		if !session.Active(now) {
+			return store.ErrNoResults
+		}
+
+		if token.Rotated {
+			// the revocation has to be committed, so the error is only returned after
+			reused = true
+			session.RevokedAt = &now
+			return put(b, session.ID, session)
+		}
+
+		token.Rotated = true
+		if err := putRefreshToken(tx, tokenHash, token); err != nil {
+			return err
+		}
+		if err := putRefreshToken(tx, newHash, refreshToken{Session: session.ID}); err != nil {
+			return err
+		}
+
+		session.LastUsedAt = now
+		session.ExpiresAt = expiresAt
+		return put(b, session.ID, session)
+	})
+	if err == nil && reused {
+		err = store.ErrReusedToken
+	}
+	if err != nil {
+		return store.Session{}, err
+	}
+	return session, nil
+}
+
+// revokeSession keeps when a session was first revoked when it is revoked again
+func revokeSession(b *bbolt.Bucket, session store.Session, now time.Time) error {
+	if session.RevokedAt != nil {
+		return nil
+	}
+	session.RevokedAt = &now
+	return put(b, session.ID, session)
+}
+
+// revokeSessions revokes every session of a user
+func revokeSessions(tx *bbolt.Tx, user int64, now time.Time) error {
+	b := tx.Bucket(sessionsBucket)
+
+	// collected first since a bucket can't be written to while it's iterated
+	var sessions []store.Session
+	err := b.ForEach(func(k, v []byte) error {
+		var session store.Session
+		if err := json.Unmarshal(v, &session); err != nil {
+			return err
+		}
+		if session.User == user {
+			sessions = append(sessions, session)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if err := revokeSession(b, session, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *service) RevokeSession(user, id int64) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(sessionsBucket)
+
+		var session store.Session
+		if err := get(b, id, &session); err != nil {
+			return err
+		}
+		if session.User != user {
+			return store.ErrNoResults
+		}
+		return revokeSession(b, session, time.Now())
+	})
+}
+
+func (s *service) RevokeSessionByToken(tokenHash string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		token, err := getRefreshToken(tx, tokenHash)
+		if err != nil {
+			return err
+		}
+
+		b := tx.Bucket(sessionsBucket)
+		var session store.Session
+		if err := get(b, token.Session, &session); err != nil {
+			return err
+		}
+		return revokeSession(b, session, time.Now())
+	})
+}
@@ -0,0 +1,55 @@
+package bolt
+
+import (
+	"github.com/natethinks/instruu-api/internal/store"
+
+	bbolt "go.etcd.io/bbolt"
+)
+
+// Vote Functions
+
+// votes are stored as a single signed byte under voteKey
+
+func voteKey(resourceID, voter int64) []byte {
+	return append(itob(resourceID), itob(voter)...)
+}
+
+func (s *service) Vote(resourceID, voter int64, value int) error {
+	if err := store.ValidateVote(value); err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		resources := tx.Bucket(resourcesBucket)
+
+		var resource store.Resource
+		if err := get(resources, resourceID, &resource); err != nil {
+			return err
+		}
+		if resource.Deleted {
+			return store.ErrNoResults
+		}
+
+		b := tx.Bucket(votesBucket)
+		key := voteKey(resourceID, voter)
+
+		previous := 0
+		if data := b.Get(key); len(data) == 1 {
+			previous = int(int8(data[0]))
+		}
+
+		var err error
+		if value == 0 {
+			err = b.Delete(key)
+		} else {
+			err = b.Put(key, []byte{byte(int8(value))})
+		}
+		if err != nil {
+			return err
+		}
+
+		resource.Score += int64(value - previous)
+		resource.Hot = store.HotScore(resource.Score, resource.CreatedAt)
+		return put(resources, resourceID, resource)
+	})
+}
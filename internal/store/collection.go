@@ -0,0 +1,82 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// Collection visibilities, unlisted collections can be read by anyone with the link but
+// aren't listed, private collections are only visible to their owner
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// Collection is a user curated, ordered list of resources
+type Collection struct {
+	ID          int64            `json:"id"`
+	Owner       int64            `json:"owner"`
+	Title       string           `json:"title"`
+	Description string           `json:"description"`
+	Visibility  string           `json:"visibility"`
+	CreatedAt   time.Time        `json:"createdAt"`
+	ItemCount   int              `json:"itemCount"`
+	Items       []CollectionItem `json:"items,omitempty"`
+}
+
+// CollectionItem is a resource in a collection with the owner's note about it
+type CollectionItem struct {
+	Resource int64  `json:"resource"`
+	Note     string `json:"note"`
+}
+
+// CollectionQuery lists collections, listings never include items
+type CollectionQuery struct {
+	// Owner limits the listing to one user's collections when it's set
+	Owner int64
+	// PublicOnly leaves out unlisted and private collections
+	PublicOnly bool
+	Page
+}
+
+// Visible reports whether user can read the collection, 0 is an anonymous user
+func (c Collection) Visible(user int64) bool {
+	return c.Visibility != VisibilityPrivate || (user != 0 && c.Owner == user)
+}
+
+// ValidateCollection checks the editable fields of a collection, an empty visibility
+// defaults to private
+func ValidateCollection(c *Collection) error {
+	if c.Title == "" || len(c.Title) > 256 {
+		return fmt.Errorf("a title of at most 256 characters is required")
+	}
+
+	switch c.Visibility {
+	case "":
+		c.Visibility = VisibilityPrivate
+	case VisibilityPublic, VisibilityUnlisted, VisibilityPrivate:
+	default:
+		return fmt.Errorf("visibility must be %s, %s or %s", VisibilityPublic, VisibilityUnlisted, VisibilityPrivate)
+	}
+	return nil
+}
+
+// ValidateCollectionItems checks an ordered list of items, a resource can only be in a
+// collection once
+func ValidateCollectionItems(items []CollectionItem) error {
+	seen := make(map[int64]bool, len(items))
+	for _, item := range items {
+		if item.Resource <= 0 {
+			return fmt.Errorf("every item needs a resource")
+		}
+		if seen[item.Resource] {
+			return fmt.Errorf("resource %d is in the collection more than once", item.Resource)
+		}
+		if len(item.Note) > 1000 {
+			return fmt.Errorf("notes can be at most 1000 characters")
+		}
+		seen[item.Resource] = true
+	}
+	return nil
+}
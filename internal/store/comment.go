@@ -0,0 +1,123 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// MaxCommentDepth is how deep replies can nest, top level comments have a depth of 0
+const MaxCommentDepth = 5
+
+// DeletedCommentBody replaces the body of a deleted comment, deleted comments stay as
+// tombstones so the replies under them keep their place in the thread
+const DeletedCommentBody = "[deleted]"
+
+// Comment is part of a discussion thread on a resource
+type Comment struct {
+	ID       int64 `json:"id"`
+	Resource int64 `json:"resource"`
+	// Parent is 0 for top level comments, Thread is the ID of the top level comment
+	Parent    int64      `json:"parent"`
+	Thread    int64      `json:"thread"`
+	Depth     int        `json:"depth"`
+	Author    int64      `json:"author"`
+	Body      string     `json:"body"`
+	Deleted   bool       `json:"deleted"`
+	CreatedAt time.Time  `json:"createdAt"`
+	EditedAt  *time.Time `json:"editedAt"`
+	Replies   []Comment  `json:"replies,omitempty"`
+}
+
+// ValidateCommentBody checks the text of a comment
+func ValidateCommentBody(body string) error {
+	if body == "" || len(body) > 10000 {
+		return fmt.Errorf("a comment of at most 10000 characters is required")
+	}
+	return nil
+}
+
+// Reply fills in where a reply to parent sits in the thread
+func (c *Comment) Reply(parent Comment) error {
+	if parent.Resource != c.Resource {
+		return ErrNoResults
+	}
+	if parent.Deleted {
+		return fmt.Errorf("deleted comments can't be replied to")
+	}
+	if parent.Depth >= MaxCommentDepth {
+		return fmt.Errorf("replies can only nest %d deep", MaxCommentDepth)
+	}
+
+	c.Parent = parent.ID
+	c.Thread = parent.Thread
+	c.Depth = parent.Depth + 1
+	return nil
+}
+
+// Tombstone hides what a deleted comment said and who said it
+func (c Comment) Tombstone() Comment {
+	if c.Deleted {
+		c.Author = 0
+		c.Body = DeletedCommentBody
+		c.EditedAt = nil
+	}
+	return c
+}
+
+// Threads nests replies under the top level comments they belong to, top level comments
+// keep their order and replies are oldest first
+func Threads(roots, replies []Comment) []Comment {
+	children := make(map[int64][]Comment)
+	for _, reply := range replies {
+		children[reply.Parent] = append(children[reply.Parent], reply)
+	}
+	for _, siblings := range children {
+		sort.Slice(siblings, func(i, j int) bool { return siblings[i].ID < siblings[j].ID })
+	}
+
+	var nest func(c Comment) Comment
+	nest = func(c Comment) Comment {
+		c = c.Tombstone()
+		for _, child := range children[c.ID] {
+			c.Replies = append(c.Replies, nest(child))
+		}
+		return c
+	}
+
+	threads := make([]Comment, len(roots))
+	for i, root := range roots {
+		threads[i] = nest(root)
+	}
+	return threads
+}
+
+// PageThreads picks a page of threads, newest first, from every comment on a resource,
+// it's what stores without a query language use to answer GetComments
+func PageThreads(comments []Comment, page Page) []Comment {
+	var roots, replies []Comment
+	for _, c := range comments {
+		if c.Parent == 0 {
+			roots = append(roots, c)
+		} else {
+			replies = append(replies, c)
+		}
+	}
+	sort.Slice(roots, func(i, j int) bool { return roots[i].ID > roots[j].ID })
+
+	start, end := page.Paginate(len(roots))
+	roots = roots[start:end]
+
+	inPage := make(map[int64]bool, len(roots))
+	for _, root := range roots {
+		inPage[root.ID] = true
+	}
+	var pageReplies []Comment
+	for _, reply := range replies {
+		if inPage[reply.Thread] {
+			pageReplies = append(pageReplies, reply)
+		}
+	}
+
+	return Threads(roots, pageReplies)
+}
@@ -0,0 +1,44 @@
+package store
+
+import "testing"
+
+func TestPageThreads(t *testing.T) {
+	comments := []Comment{
+		{ID: 1, Thread: 1, Author: 7, Body: "first", Deleted: true},
+		{ID: 2, Thread: 2, Body: "second"},
+		{ID: 3, Thread: 1, Parent: 1, Depth: 1, Body: "reply"},
+		{ID: 4, Thread: 1, Parent: 3, Depth: 2, Body: "nested reply"},
+	}
+
+	threads := PageThreads(comments, Page{Limit: 1, Offset: 1})
+	if len(threads) != 1 || threads[0].ID != 1 {
+		t.Fatalf("unexpected page: %+v", threads)
+	}
+
+	thread := threads[0]
+	if thread.Body != DeletedCommentBody || thread.Author != 0 {
+		t.Errorf("deleted comment wasn't tombstoned: %+v", thread)
+	}
+	if len(thread.Replies) != 1 || len(thread.Replies[0].Replies) != 1 || thread.Replies[0].Replies[0].ID != 4 {
+		t.Errorf("replies weren't nested: %+v", thread.Replies)
+	}
+}
+
+func TestReplyDepth(t *testing.T) {
+	parent := Comment{ID: 9, Resource: 1, Thread: 2, Depth: MaxCommentDepth}
+
+	reply := Comment{Resource: 1}
+	if err := reply.Reply(parent); err == nil {
+		t.Error("expected a reply past the depth limit to be rejected")
+	}
+
+	parent.Depth--
+	if err := reply.Reply(parent); err != nil || reply.Depth != MaxCommentDepth || reply.Thread != 2 {
+		t.Errorf("unexpected reply: %+v, %v", reply, err)
+	}
+
+	other := Comment{Resource: 5}
+	if err := other.Reply(parent); err != ErrNoResults {
+		t.Errorf("expected a reply from another resource to be rejected, got %v", err)
+	}
+}
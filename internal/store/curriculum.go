@@ -0,0 +1,202 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// Curriculum is a learning path made of ordered sections, each holding ordered steps
+type Curriculum struct {
+	ID          int64     `json:"id"`
+	Owner       int64     `json:"owner"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"createdAt"`
+	StepCount   int       `json:"stepCount"`
+	// EstimatedMinutes only counts required steps
+	EstimatedMinutes int       `json:"estimatedMinutes"`
+	Sections         []Section `json:"sections,omitempty"`
+}
+
+// Section is a titled group of steps in a curriculum
+type Section struct {
+	ID               int64  `json:"id"`
+	Title            string `json:"title"`
+	Description      string `json:"description"`
+	EstimatedMinutes int    `json:"estimatedMinutes"`
+	Steps            []Step `json:"steps"`
+}
+
+// Step points a learner at a resource
+type Step struct {
+	ID               int64  `json:"id"`
+	Resource         int64  `json:"resource"`
+	Note             string `json:"note"`
+	EstimatedMinutes int    `json:"estimatedMinutes"`
+	Optional         bool   `json:"optional"`
+}
+
+// CurriculumQuery lists curriculums, listings never include sections
+type CurriculumQuery struct {
+	// Owner limits the listing to one user's curriculums when it's set
+	Owner int64
+	Page
+}
+
+// ValidateCurriculum checks the editable fields of a curriculum
+func ValidateCurriculum(c Curriculum) error {
+	if c.Title == "" || len(c.Title) > 256 {
+		return fmt.Errorf("a title of at most 256 characters is required")
+	}
+	return nil
+}
+
+// ValidateSection checks the editable fields of a section
+func ValidateSection(s Section) error {
+	if s.Title == "" || len(s.Title) > 256 {
+		return fmt.Errorf("a title of at most 256 characters is required")
+	}
+	return nil
+}
+
+// ValidateStep checks the editable fields of a step
+func ValidateStep(s Step) error {
+	if s.Resource <= 0 {
+		return fmt.Errorf("a step needs a resource")
+	}
+	if s.EstimatedMinutes < 0 {
+		return fmt.Errorf("estimated minutes can't be negative")
+	}
+	if len(s.Note) > 1000 {
+		return fmt.Errorf("notes can be at most 1000 characters")
+	}
+	return nil
+}
+
+// ValidateOrder checks that order lists every one of ids exactly once
+func ValidateOrder(ids, order []int64) error {
+	if len(order) != len(ids) {
+		return fmt.Errorf("the new order must list all %d ids", len(ids))
+	}
+
+	known := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		known[id] = true
+	}
+	for _, id := range order {
+		if !known[id] {
+			return fmt.Errorf("id %d is unknown or listed more than once", id)
+		}
+		delete(known, id)
+	}
+	return nil
+}
+
+// Totals fills in the step count and estimated minutes of the curriculum and its sections
+func (c *Curriculum) Totals() {
+	c.StepCount, c.EstimatedMinutes = 0, 0
+	for i := range c.Sections {
+		section := &c.Sections[i]
+		section.EstimatedMinutes = 0
+		for _, step := range section.Steps {
+			if !step.Optional {
+				section.EstimatedMinutes += step.EstimatedMinutes
+			}
+		}
+		c.StepCount += len(section.Steps)
+		c.EstimatedMinutes += section.EstimatedMinutes
+	}
+}
+
+// SectionIDs returns the IDs of the sections in order
+func (c Curriculum) SectionIDs() []int64 {
+	ids := make([]int64, len(c.Sections))
+	for i, section := range c.Sections {
+		ids[i] = section.ID
+	}
+	return ids
+}
+
+// StepIDs returns the IDs of the steps in order
+func (s Section) StepIDs() []int64 {
+	ids := make([]int64, len(s.Steps))
+	for i, step := range s.Steps {
+		ids[i] = step.ID
+	}
+	return ids
+}
+
+// The methods below edit a curriculum held as a single document, stores that keep the
+// whole tree in one record share them
+
+// FindSection returns the section with the given ID
+func (c *Curriculum) FindSection(id int64) (*Section, error) {
+	for i := range c.Sections {
+		if c.Sections[i].ID == id {
+			return &c.Sections[i], nil
+		}
+	}
+	return nil, ErrNoResults
+}
+
+// RemoveSection removes a section and its steps
+func (c *Curriculum) RemoveSection(id int64) error {
+	for i := range c.Sections {
+		if c.Sections[i].ID == id {
+			c.Sections = append(c.Sections[:i], c.Sections[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNoResults
+}
+
+// OrderSections rearranges the sections to match order
+func (c *Curriculum) OrderSections(order []int64) error {
+	if err := ValidateOrder(c.SectionIDs(), order); err != nil {
+		return err
+	}
+
+	sections := make([]Section, len(order))
+	for i, id := range order {
+		section, _ := c.FindSection(id)
+		sections[i] = *section
+	}
+	c.Sections = sections
+	return nil
+}
+
+// FindStep returns the step with the given ID
+func (s *Section) FindStep(id int64) (*Step, error) {
+	for i := range s.Steps {
+		if s.Steps[i].ID == id {
+			return &s.Steps[i], nil
+		}
+	}
+	return nil, ErrNoResults
+}
+
+// RemoveStep removes a step from the section
+func (s *Section) RemoveStep(id int64) error {
+	for i := range s.Steps {
+		if s.Steps[i].ID == id {
+			s.Steps = append(s.Steps[:i], s.Steps[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNoResults
+}
+
+// OrderSteps rearranges the steps to match order
+func (s *Section) OrderSteps(order []int64) error {
+	if err := ValidateOrder(s.StepIDs(), order); err != nil {
+		return err
+	}
+
+	steps := make([]Step, len(order))
+	for i, id := range order {
+		step, _ := s.FindStep(id)
+		steps[i] = *step
+	}
+	s.Steps = steps
+	return nil
+}
@@ -0,0 +1,32 @@
+package store
+
+import "testing"
+
+func TestOrderSections(t *testing.T) {
+	c := Curriculum{Sections: []Section{{ID: 1}, {ID: 2}, {ID: 3}}}
+
+	for _, order := range [][]int64{{1, 2}, {1, 2, 2}, {1, 2, 4}} {
+		if err := c.OrderSections(order); err == nil {
+			t.Errorf("expected order %v to be rejected", order)
+		}
+	}
+
+	if err := c.OrderSections([]int64{3, 1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if ids := c.SectionIDs(); ids[0] != 3 || ids[1] != 1 || ids[2] != 2 {
+		t.Errorf("unexpected order: %v", ids)
+	}
+}
+
+func TestCurriculumTotals(t *testing.T) {
+	c := Curriculum{Sections: []Section{
+		{Steps: []Step{{EstimatedMinutes: 30}, {EstimatedMinutes: 15, Optional: true}}},
+		{Steps: []Step{{EstimatedMinutes: 45}}},
+	}}
+	c.Totals()
+
+	if c.StepCount != 3 || c.EstimatedMinutes != 75 || c.Sections[0].EstimatedMinutes != 30 {
+		t.Errorf("unexpected totals: %+v", c)
+	}
+}
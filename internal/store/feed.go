@@ -0,0 +1,95 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Event types, Subject is the ID of the resource, collection or curriculum the type names
+const (
+	EventResourceApproved  = "resource.approved"
+	EventCollectionCreated = "collection.created"
+	EventCurriculumCreated = "curriculum.created"
+	EventCurriculumUpdated = "curriculum.updated"
+)
+
+// Event is something a user did that shows up in the feeds of their followers, resource
+// events also show up for followers of the resource's tags
+type Event struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	Actor     int64     `json:"actor"`
+	Subject   int64     `json:"subject"`
+	Tags      []string  `json:"tags,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Follows are the users and tags someone follows
+type Follows struct {
+	Users []int64  `json:"users"`
+	Tags  []string `json:"tags"`
+}
+
+// Match reports whether an event belongs in the feed of someone with these follows
+func (f Follows) Match(event Event) bool {
+	for _, user := range f.Users {
+		if event.Actor == user {
+			return true
+		}
+	}
+	for _, tag := range f.Tags {
+		for _, t := range event.Tags {
+			if t == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FeedQuery pages through a feed, newest events first
+type FeedQuery struct {
+	Limit int
+	// Cursor is the ID of the last event of the previous page
+	Cursor int64
+}
+
+// ParseFeedQuery validates the limit and cursor url query parameters, anything else is
+// rejected
+func ParseFeedQuery(params map[string][]string) (FeedQuery, error) {
+	query := FeedQuery{Limit: DefaultLimit}
+
+	for param, values := range params {
+		if len(values) > 1 {
+			return query, &QueryError{param, "can only be given once"}
+		}
+		value := ""
+		if len(values) > 0 {
+			value = values[0]
+		}
+
+		var err error
+		switch param {
+		case "limit":
+			query.Limit, err = parseLimit(value)
+		case "cursor":
+			query.Cursor, err = strconv.ParseInt(value, 10, 64)
+			if err == nil && query.Cursor <= 0 {
+				err = fmt.Errorf("must be a positive ID")
+			}
+		default:
+			err = fmt.Errorf("unknown parameter")
+		}
+		if err != nil {
+			return query, &QueryError{param, err.Error()}
+		}
+	}
+
+	return query, nil
+}
+
+// AfterCursor reports whether an event belongs on the page after the cursor
+func (q FeedQuery) AfterCursor(event Event) bool {
+	return q.Cursor == 0 || event.ID < q.Cursor
+}
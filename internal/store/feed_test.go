@@ -0,0 +1,44 @@
+package store
+
+import "testing"
+
+func TestFollowsMatch(t *testing.T) {
+	follows := Follows{Users: []int64{3}, Tags: []string{"go"}}
+
+	tests := []struct {
+		event Event
+		match bool
+	}{
+		{Event{Actor: 3}, true},
+		{Event{Actor: 4, Tags: []string{"rust", "go"}}, true},
+		{Event{Actor: 4, Tags: []string{"rust"}}, false},
+		{Event{Actor: 4}, false},
+	}
+	for _, test := range tests {
+		if match := follows.Match(test.event); match != test.match {
+			t.Errorf("Match(%+v) = %v, expected %v", test.event, match, test.match)
+		}
+	}
+}
+
+func TestParseFeedQuery(t *testing.T) {
+	query, err := ParseFeedQuery(map[string][]string{"limit": {"5"}, "cursor": {"40"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query.Limit != 5 || query.Cursor != 40 {
+		t.Errorf("unexpected query: %+v", query)
+	}
+	if query.AfterCursor(Event{ID: 40}) || !query.AfterCursor(Event{ID: 39}) {
+		t.Error("only events older than the cursor should be on the next page")
+	}
+
+	for _, params := range []map[string][]string{
+		{"cursor": {"0"}},
+		{"offset": {"10"}},
+	} {
+		if _, err := ParseFeedQuery(params); err == nil {
+			t.Errorf("expected %v to be rejected", params)
+		}
+	}
+}
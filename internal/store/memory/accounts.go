@@ -0,0 +1,105 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// External Account Functions
+
+func (s *service) LinkAccount(account store.ExternalAccount) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.linkAccount(account)
+}
+
+func (s *service) CreateExternalUser(user store.User, account store.ExternalAccount) (int64, error) {
+	user = newUser(user)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// nothing is written until both are sure to go through
+	if linked, ok := s.accounts[account.Provider][account.Subject]; ok {
+		if _, ok := s.users[linked.User]; ok {
+			return 0, store.ErrAccountLinked
+		}
+	}
+	id, err := s.createUser(user)
+	if err != nil {
+		return 0, err
+	}
+	account.User = id
+	return id, s.linkAccount(account)
+}
+
+// linkAccount expects the caller to hold the lock
+func (s *service) linkAccount(account store.ExternalAccount) error {
+	if _, ok := s.users[account.User]; !ok {
+		return store.ErrNoResults
+	}
+	// links left behind by deleted users don't count
+	if linked, ok := s.accounts[account.Provider][account.Subject]; ok {
+		if _, ok := s.users[linked.User]; ok {
+			return store.ErrAccountLinked
+		}
+	}
+	for _, linked := range s.accounts[account.Provider] {
+		if linked.User == account.User {
+			return store.ErrAccountLinked
+		}
+	}
+
+	if s.accounts[account.Provider] == nil {
+		s.accounts[account.Provider] = make(map[string]store.ExternalAccount)
+	}
+	account.CreatedAt = time.Now()
+	s.accounts[account.Provider][account.Subject] = account
+	return nil
+}
+
+func (s *service) GetAccountUser(provider, subject string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	account, ok := s.accounts[provider][subject]
+	if !ok {
+		return 0, store.ErrNoResults
+	}
+	if _, ok := s.users[account.User]; !ok {
+		return 0, store.ErrNoResults
+	}
+	return account.User, nil
+}
+
+func (s *service) GetAccounts(user int64) ([]store.ExternalAccount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var accounts []store.ExternalAccount
+	for _, subjects := range s.accounts {
+		for _, account := range subjects {
+			if account.User == user {
+				accounts = append(accounts, account)
+			}
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].CreatedAt.Before(accounts[j].CreatedAt) })
+	return accounts, nil
+}
+
+func (s *service) UnlinkAccount(user int64, provider string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for subject, account := range s.accounts[provider] {
+		if account.User == user {
+			delete(s.accounts[provider], subject)
+			return nil
+		}
+	}
+	return store.ErrNoResults
+}
@@ -0,0 +1,103 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// API Key Functions
+
+func (s *service) CreateAPIKey(key store.APIKey, tokenHash string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[key.User]; !ok {
+		return 0, store.ErrNoResults
+	}
+
+	s.lastAPIKeyID++
+	key.ID = s.lastAPIKeyID
+	key.CreatedAt = time.Now()
+	key.LastUsedAt = nil
+	s.apiKeys[key.ID] = key
+	s.apiKeyHashes[tokenHash] = key.ID
+	return key.ID, nil
+}
+
+func (s *service) GetAPIKey(id int64) (store.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.apiKeys[id]
+	if !ok {
+		return store.APIKey{ID: id}, store.ErrNoResults
+	}
+	return key, nil
+}
+
+func (s *service) GetAPIKeys(user int64) ([]store.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []store.APIKey
+	for _, key := range s.apiKeys {
+		if key.User == user {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID > keys[j].ID })
+	return keys, nil
+}
+
+func (s *service) UpdateAPIKey(key store.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.apiKeys[key.ID]
+	if !ok || stored.User != key.User {
+		return store.ErrNoResults
+	}
+
+	stored.Name = key.Name
+	stored.Scopes = key.Scopes
+	s.apiKeys[key.ID] = stored
+	return nil
+}
+
+func (s *service) DeleteAPIKey(user, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.apiKeys[id]
+	if !ok || key.User != user {
+		return store.ErrNoResults
+	}
+
+	delete(s.apiKeys, id)
+	for hash, keyID := range s.apiKeyHashes {
+		if keyID == id {
+			delete(s.apiKeyHashes, hash)
+		}
+	}
+	return nil
+}
+
+func (s *service) UseAPIKey(tokenHash string) (store.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	key, ok := s.apiKeys[s.apiKeyHashes[tokenHash]]
+	if !ok || !key.Active(now) {
+		return store.APIKey{}, store.ErrNoResults
+	}
+	if _, ok := s.users[key.User]; !ok {
+		return store.APIKey{}, store.ErrNoResults
+	}
+
+	key.LastUsedAt = &now
+	s.apiKeys[key.ID] = key
+	return key, nil
+}
@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Bookmark Functions
+
+// countBookmark adjusts the bookmark count of a resource, the caller must hold the lock
+func (s *service) countBookmark(resourceID, delta int64) {
+	resource := s.resources[resourceID]
+	resource.BookmarkCount += delta
+	s.resources[resourceID] = resource
+}
+
+func (s *service) AddBookmark(user, resourceID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.resourceLive(resourceID) {
+		return store.ErrNoResults
+	}
+
+	bookmarks, ok := s.bookmarks[user]
+	if !ok {
+		bookmarks = make(map[int64]time.Time)
+		s.bookmarks[user] = bookmarks
+	}
+	if _, ok := bookmarks[resourceID]; ok {
+		return nil
+	}
+
+	bookmarks[resourceID] = time.Now()
+	s.countBookmark(resourceID, 1)
+	return nil
+}
+
+func (s *service) RemoveBookmark(user, resourceID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.bookmarks[user][resourceID]; !ok {
+		return store.ErrNoResults
+	}
+
+	delete(s.bookmarks[user], resourceID)
+	s.countBookmark(resourceID, -1)
+	return nil
+}
+
+// GetBookmarks lists a user's saved resources, most recently saved first
+func (s *service) GetBookmarks(user int64, page store.Page) ([]store.Resource, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	bookmarks := s.bookmarks[user]
+	resources := make([]store.Resource, 0, len(bookmarks))
+	for id := range bookmarks {
+		if resource := s.resources[id]; !resource.Deleted {
+			resource.Bookmarked = true
+			resources = append(resources, resource)
+		}
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		a, b := bookmarks[resources[i].ID], bookmarks[resources[j].ID]
+		if !a.Equal(b) {
+			return a.After(b)
+		}
+		return resources[i].ID > resources[j].ID
+	})
+
+	start, end := page.Paginate(len(resources))
+	return resources[start:end], nil
+}
+
+func (s *service) Bookmarked(user int64, resourceIDs []int64) (map[int64]bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	bookmarked := make(map[int64]bool)
+	for _, id := range resourceIDs {
+		if _, ok := s.bookmarks[user][id]; ok {
+			bookmarked[id] = true
+		}
+	}
+	return bookmarked, nil
+}
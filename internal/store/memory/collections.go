@@ -0,0 +1,198 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Collection Functions
+
+func (s *service) CreateCollection(collection store.Collection) (int64, error) {
+	if err := store.ValidateCollection(&collection); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastCollectionID++
+	collection.ID = s.lastCollectionID
+	collection.CreatedAt = time.Now()
+	collection.Items = nil
+	s.collections[collection.ID] = collection
+
+	if collection.Visibility == store.VisibilityPublic {
+		s.record(store.EventCollectionCreated, collection.Owner, collection.ID, nil)
+	}
+
+	return collection.ID, nil
+}
+
+// liveItems drops items whose resource has been deleted, the caller must hold the lock
+func (s *service) liveItems(items []store.CollectionItem) []store.CollectionItem {
+	live := make([]store.CollectionItem, 0, len(items))
+	for _, item := range items {
+		if resource, ok := s.resources[item.Resource]; ok && !resource.Deleted {
+			live = append(live, item)
+		}
+	}
+	return live
+}
+
+func (s *service) GetCollection(id int64) (store.Collection, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	collection, ok := s.collections[id]
+	if !ok {
+		return store.Collection{ID: id}, store.ErrNoResults
+	}
+
+	collection.Items = s.liveItems(collection.Items)
+	collection.ItemCount = len(collection.Items)
+	return collection, nil
+}
+
+// GetCollections lists collections newest first without their items
+func (s *service) GetCollections(query store.CollectionQuery) ([]store.Collection, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var collections []store.Collection
+	for _, collection := range s.collections {
+		if query.Owner != 0 && collection.Owner != query.Owner {
+			continue
+		}
+		if query.PublicOnly && collection.Visibility != store.VisibilityPublic {
+			continue
+		}
+
+		collection.ItemCount = len(s.liveItems(collection.Items))
+		collection.Items = nil
+		collections = append(collections, collection)
+	}
+	sort.Slice(collections, func(i, j int) bool { return collections[i].ID > collections[j].ID })
+
+	start, end := query.Page.Paginate(len(collections))
+	return collections[start:end], nil
+}
+
+// UpdateCollection changes the title, description and visibility, the owner never changes
+func (s *service) UpdateCollection(collection store.Collection) error {
+	if err := store.ValidateCollection(&collection); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.collections[collection.ID]
+	if !ok {
+		return store.ErrNoResults
+	}
+
+	// collections made public later are announced like new ones
+	if stored.Visibility != store.VisibilityPublic && collection.Visibility == store.VisibilityPublic {
+		s.record(store.EventCollectionCreated, stored.Owner, stored.ID, nil)
+	}
+
+	stored.Title = collection.Title
+	stored.Description = collection.Description
+	stored.Visibility = collection.Visibility
+	s.collections[stored.ID] = stored
+	return nil
+}
+
+func (s *service) DeleteCollection(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.collections[id]; !ok {
+		return store.ErrNoResults
+	}
+	delete(s.collections, id)
+	return nil
+}
+
+func (s *service) AddCollectionItem(collectionID int64, item store.CollectionItem) error {
+	if err := store.ValidateCollectionItems([]store.CollectionItem{item}); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	collection, ok := s.collections[collectionID]
+	if !ok {
+		return store.ErrNoResults
+	}
+	if resource, ok := s.resources[item.Resource]; !ok || resource.Deleted {
+		return store.ErrNoResults
+	}
+
+	// copy so collections already handed out are never written to
+	items := append([]store.CollectionItem(nil), collection.Items...)
+	updated := false
+	for i := range items {
+		if items[i].Resource == item.Resource {
+			items[i].Note = item.Note
+			updated = true
+		}
+	}
+	if !updated {
+		items = append(items, item)
+	}
+
+	collection.Items = items
+	s.collections[collectionID] = collection
+	return nil
+}
+
+func (s *service) RemoveCollectionItem(collectionID, resourceID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	collection, ok := s.collections[collectionID]
+	if !ok {
+		return store.ErrNoResults
+	}
+
+	items := make([]store.CollectionItem, 0, len(collection.Items))
+	for _, item := range collection.Items {
+		if item.Resource != resourceID {
+			items = append(items, item)
+		}
+	}
+	if len(items) == len(collection.Items) {
+		return store.ErrNoResults
+	}
+
+	collection.Items = items
+	s.collections[collectionID] = collection
+	return nil
+}
+
+func (s *service) SetCollectionItems(collectionID int64, items []store.CollectionItem) error {
+	if err := store.ValidateCollectionItems(items); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	collection, ok := s.collections[collectionID]
+	if !ok {
+		return store.ErrNoResults
+	}
+	for _, item := range items {
+		if resource, ok := s.resources[item.Resource]; !ok || resource.Deleted {
+			return store.ErrNoResults
+		}
+	}
+
+	collection.Items = append([]store.CollectionItem(nil), items...)
+	s.collections[collectionID] = collection
+	return nil
+}
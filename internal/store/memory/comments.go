@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Comment Functions
+
+func (s *service) CreateComment(comment store.Comment) (int64, error) {
+	if err := store.ValidateCommentBody(comment.Body); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.resourceLive(comment.Resource) {
+		return 0, store.ErrNoResults
+	}
+
+	comment.Thread, comment.Depth = 0, 0
+	if comment.Parent != 0 {
+		parent, ok := s.comments[comment.Parent]
+		if !ok {
+			return 0, store.ErrNoResults
+		}
+		if err := comment.Reply(parent); err != nil {
+			return 0, err
+		}
+	}
+
+	s.lastCommentID++
+	comment.ID = s.lastCommentID
+	if comment.Thread == 0 {
+		comment.Thread = comment.ID
+	}
+
+	comment.CreatedAt = time.Now()
+	comment.EditedAt = nil
+	comment.Deleted = false
+	comment.Replies = nil
+	s.comments[comment.ID] = comment
+
+	return comment.ID, nil
+}
+
+func (s *service) GetComment(id int64) (store.Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	comment, ok := s.comments[id]
+	if !ok {
+		return store.Comment{ID: id}, store.ErrNoResults
+	}
+	return comment, nil
+}
+
+func (s *service) GetComments(resourceID int64, page store.Page) ([]store.Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var comments []store.Comment
+	for _, comment := range s.comments {
+		if comment.Resource == resourceID {
+			comments = append(comments, comment)
+		}
+	}
+
+	return store.PageThreads(comments, page), nil
+}
+
+func (s *service) UpdateComment(id int64, body string) error {
+	if err := store.ValidateCommentBody(body); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment, ok := s.comments[id]
+	if !ok || comment.Deleted {
+		return store.ErrNoResults
+	}
+
+	now := time.Now()
+	comment.Body = body
+	comment.EditedAt = &now
+	s.comments[id] = comment
+	return nil
+}
+
+func (s *service) DeleteComment(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment, ok := s.comments[id]
+	if !ok || comment.Deleted {
+		return store.ErrNoResults
+	}
+
+	comment.Deleted = true
+	comment.Body = ""
+	s.comments[id] = comment
+	return nil
+}
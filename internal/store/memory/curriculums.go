@@ -0,0 +1,249 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Curriculum Functions
+
+// copyCurriculum deep copies the sections so a stored curriculum is never shared
+func copyCurriculum(curriculum store.Curriculum) store.Curriculum {
+	sections := make([]store.Section, len(curriculum.Sections))
+	for i, section := range curriculum.Sections {
+		section.Steps = append([]store.Step{}, section.Steps...)
+		sections[i] = section
+	}
+	curriculum.Sections = sections
+	return curriculum
+}
+
+func (s *service) nextCurriculumNodeID() int64 {
+	s.lastCurriculumNodeID++
+	return s.lastCurriculumNodeID
+}
+
+// resourceLive reports whether a resource exists and isn't deleted, the caller must hold the lock
+func (s *service) resourceLive(id int64) bool {
+	resource, ok := s.resources[id]
+	return ok && !resource.Deleted
+}
+
+// withTotals returns a copy of the curriculum with its totals filled in, steps keep
+// pointing at deleted resources so the owner can see what needs replacing
+func withTotals(curriculum store.Curriculum) store.Curriculum {
+	curriculum = copyCurriculum(curriculum)
+	curriculum.Totals()
+	return curriculum
+}
+
+func (s *service) CreateCurriculum(curriculum store.Curriculum) (int64, error) {
+	if err := store.ValidateCurriculum(curriculum); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastCurriculumID++
+	curriculum.ID = s.lastCurriculumID
+	curriculum.CreatedAt = time.Now()
+	curriculum.Sections = nil
+	s.curriculums[curriculum.ID] = curriculum
+	s.record(store.EventCurriculumCreated, curriculum.Owner, curriculum.ID, nil)
+
+	return curriculum.ID, nil
+}
+
+func (s *service) GetCurriculum(id int64) (store.Curriculum, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	curriculum, ok := s.curriculums[id]
+	if !ok {
+		return store.Curriculum{ID: id}, store.ErrNoResults
+	}
+	return withTotals(curriculum), nil
+}
+
+// GetCurriculums lists curriculums newest first without their sections
+func (s *service) GetCurriculums(query store.CurriculumQuery) ([]store.Curriculum, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var curriculums []store.Curriculum
+	for _, curriculum := range s.curriculums {
+		if query.Owner != 0 && curriculum.Owner != query.Owner {
+			continue
+		}
+
+		curriculum = withTotals(curriculum)
+		curriculum.Sections = nil
+		curriculums = append(curriculums, curriculum)
+	}
+	sort.Slice(curriculums, func(i, j int) bool { return curriculums[i].ID > curriculums[j].ID })
+
+	start, end := query.Page.Paginate(len(curriculums))
+	return curriculums[start:end], nil
+}
+
+// updateCurriculum applies change to a copy of the stored curriculum and keeps it when
+// change succeeds
+func (s *service) updateCurriculum(id int64, change func(curriculum *store.Curriculum) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.curriculums[id]
+	if !ok {
+		return store.ErrNoResults
+	}
+
+	curriculum := copyCurriculum(stored)
+	if err := change(&curriculum); err != nil {
+		return err
+	}
+	s.curriculums[id] = curriculum
+	return nil
+}
+
+// UpdateCurriculum changes the title and description, the owner never changes
+func (s *service) UpdateCurriculum(curriculum store.Curriculum) error {
+	if err := store.ValidateCurriculum(curriculum); err != nil {
+		return err
+	}
+
+	return s.updateCurriculum(curriculum.ID, func(stored *store.Curriculum) error {
+		stored.Title = curriculum.Title
+		stored.Description = curriculum.Description
+		return nil
+	})
+}
+
+func (s *service) DeleteCurriculum(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.curriculums[id]; !ok {
+		return store.ErrNoResults
+	}
+	delete(s.curriculums, id)
+	return nil
+}
+
+// Curriculum Section Functions
+
+func (s *service) AddCurriculumSection(curriculumID int64, section store.Section) (id int64, err error) {
+	if err := store.ValidateSection(section); err != nil {
+		return id, err
+	}
+
+	err = s.updateCurriculum(curriculumID, func(curriculum *store.Curriculum) error {
+		id = s.nextCurriculumNodeID()
+		curriculum.Sections = append(curriculum.Sections, store.Section{
+			ID:          id,
+			Title:       section.Title,
+			Description: section.Description,
+			Steps:       []store.Step{},
+		})
+		return nil
+	})
+	return id, err
+}
+
+func (s *service) UpdateCurriculumSection(curriculumID int64, section store.Section) error {
+	if err := store.ValidateSection(section); err != nil {
+		return err
+	}
+
+	return s.updateCurriculum(curriculumID, func(curriculum *store.Curriculum) error {
+		stored, err := curriculum.FindSection(section.ID)
+		if err != nil {
+			return err
+		}
+		stored.Title = section.Title
+		stored.Description = section.Description
+		return nil
+	})
+}
+
+func (s *service) DeleteCurriculumSection(curriculumID, sectionID int64) error {
+	return s.updateCurriculum(curriculumID, func(curriculum *store.Curriculum) error {
+		return curriculum.RemoveSection(sectionID)
+	})
+}
+
+func (s *service) OrderCurriculumSections(curriculumID int64, order []int64) error {
+	return s.updateCurriculum(curriculumID, func(curriculum *store.Curriculum) error {
+		return curriculum.OrderSections(order)
+	})
+}
+
+// Curriculum Step Functions
+
+func (s *service) AddCurriculumStep(curriculumID, sectionID int64, step store.Step) (id int64, err error) {
+	if err := store.ValidateStep(step); err != nil {
+		return id, err
+	}
+
+	err = s.updateCurriculum(curriculumID, func(curriculum *store.Curriculum) error {
+		section, err := curriculum.FindSection(sectionID)
+		if err != nil {
+			return err
+		}
+		if !s.resourceLive(step.Resource) {
+			return store.ErrNoResults
+		}
+
+		id = s.nextCurriculumNodeID()
+		step.ID = id
+		section.Steps = append(section.Steps, step)
+		s.record(store.EventCurriculumUpdated, curriculum.Owner, curriculum.ID, nil)
+		return nil
+	})
+	return id, err
+}
+
+func (s *service) UpdateCurriculumStep(curriculumID, sectionID int64, step store.Step) error {
+	if err := store.ValidateStep(step); err != nil {
+		return err
+	}
+
+	return s.updateCurriculum(curriculumID, func(curriculum *store.Curriculum) error {
+		section, err := curriculum.FindSection(sectionID)
+		if err != nil {
+			return err
+		}
+		stored, err := section.FindStep(step.ID)
+		if err != nil {
+			return err
+		}
+		if !s.resourceLive(step.Resource) {
+			return store.ErrNoResults
+		}
+
+		*stored = step
+		return nil
+	})
+}
+
+func (s *service) DeleteCurriculumStep(curriculumID, sectionID, stepID int64) error {
+	return s.updateCurriculum(curriculumID, func(curriculum *store.Curriculum) error {
+		section, err := curriculum.FindSection(sectionID)
+		if err != nil {
+			return err
+		}
+		return section.RemoveStep(stepID)
+	})
+}
+
+func (s *service) OrderCurriculumSteps(curriculumID, sectionID int64, order []int64) error {
+	return s.updateCurriculum(curriculumID, func(curriculum *store.Curriculum) error {
+		section, err := curriculum.FindSection(sectionID)
+		if err != nil {
+			return err
+		}
+		return section.OrderSteps(order)
+	})
+}
@@ -0,0 +1,139 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Follow Functions
+
+func (s *service) FollowUser(follower, user int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[user]; !ok {
+		return false, store.ErrNoResults
+	}
+
+	if s.followedUsers[follower][user] {
+		return false, nil
+	}
+	if s.followedUsers[follower] == nil {
+		s.followedUsers[follower] = make(map[int64]bool)
+	}
+	s.followedUsers[follower][user] = true
+	return true, nil
+}
+
+func (s *service) UnfollowUser(follower, user int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.followedUsers[follower][user] {
+		return store.ErrNoResults
+	}
+	delete(s.followedUsers[follower], user)
+	return nil
+}
+
+func (s *service) FollowTag(follower int64, tag string) error {
+	tags, err := store.NormalizeTags([]string{tag})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.followedTags[follower] == nil {
+		s.followedTags[follower] = make(map[string]bool)
+	}
+	s.followedTags[follower][tags[0]] = true
+	return nil
+}
+
+func (s *service) UnfollowTag(follower int64, tag string) error {
+	tags, err := store.NormalizeTags([]string{tag})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.followedTags[follower][tags[0]] {
+		return store.ErrNoResults
+	}
+	delete(s.followedTags[follower], tags[0])
+	return nil
+}
+
+func (s *service) GetFollows(follower int64) (store.Follows, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.follows(follower), nil
+}
+
+// follows collects what follower follows in a stable order, the caller must hold the lock
+func (s *service) follows(follower int64) store.Follows {
+	follows := store.Follows{Users: []int64{}, Tags: []string{}}
+	for user := range s.followedUsers[follower] {
+		follows.Users = append(follows.Users, user)
+	}
+	for tag := range s.followedTags[follower] {
+		follows.Tags = append(follows.Tags, tag)
+	}
+	sort.Slice(follows.Users, func(i, j int) bool { return follows.Users[i] < follows.Users[j] })
+	sort.Strings(follows.Tags)
+	return follows
+}
+
+// Feed Functions
+
+// record appends an event to the activity log, the caller must hold the lock
+func (s *service) record(eventType string, actor, subject int64, tags []string) {
+	s.lastEventID++
+	s.events = append(s.events, store.Event{
+		ID:        s.lastEventID,
+		Type:      eventType,
+		Actor:     actor,
+		Subject:   subject,
+		Tags:      tags,
+		CreatedAt: time.Now(),
+	})
+}
+
+// eventLive reports whether the subject of an event can still be shown, the caller must
+// hold the lock
+func (s *service) eventLive(event store.Event) bool {
+	switch event.Type {
+	case store.EventResourceApproved:
+		return s.resourceLive(event.Subject) && s.resources[event.Subject].Approved
+	case store.EventCollectionCreated:
+		collection, ok := s.collections[event.Subject]
+		return ok && collection.Visibility == store.VisibilityPublic
+	case store.EventCurriculumCreated, store.EventCurriculumUpdated:
+		_, ok := s.curriculums[event.Subject]
+		return ok
+	}
+	return false
+}
+
+// GetFeed walks the log backwards, events are appended in ID order
+func (s *service) GetFeed(user int64, query store.FeedQuery) ([]store.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	follows := s.follows(user)
+	events := []store.Event{}
+	for i := len(s.events) - 1; i >= 0 && len(events) < query.Limit; i-- {
+		event := s.events[i]
+		if query.AfterCursor(event) && follows.Match(event) && s.eventLive(event) {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,671 @@
+package memory
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/auth"
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// service keeps every record in maps guarded by a single lock, it's meant for
+// tests and local development so nothing survives a restart
+type service struct {
+	mu sync.RWMutex
+
+	users     map[int64]store.User
+	resources map[int64]store.Resource
+	revisions map[int64][]store.Revision
+	// reviews are keyed by resource then reviewer
+	reviews map[int64]map[int64]store.Review
+	// votes are keyed by resource then voter
+	votes    map[int64]map[int64]int
+	comments map[int64]store.Comment
+	// bookmarks are keyed by user then resource and hold when the resource was saved
+	bookmarks map[int64]map[int64]time.Time
+	// follows are keyed by follower
+	followedUsers map[int64]map[int64]bool
+	followedTags  map[int64]map[string]bool
+	events        []store.Event
+	notifications map[int64]store.Notification
+	// verificationSent holds when each user was last sent a verification email
+	verificationSent map[int64]time.Time
+	// password resets are keyed by token hash
+	passwordResets  map[string]store.PasswordReset
+	sessionsRevoked map[int64]time.Time
+	outbox          map[int64]store.OutboxMail
+	sessions        map[int64]store.Session
+	refreshTokens   map[string]refreshToken
+	apiKeys         map[int64]store.APIKey
+	// apiKeyHashes maps the hash of each key's token to its ID
+	apiKeyHashes map[string]int64
+	// accounts are keyed by provider then subject
+	accounts map[string]map[string]store.ExternalAccount
+
+	collections map[int64]store.Collection
+	curriculums map[int64]store.Curriculum
+	// progress is keyed by learner then step
+	progress map[int64]map[int64]store.StepProgress
+
+	lastUserID         int64
+	lastResourceID     int64
+	lastCollectionID   int64
+	lastCurriculumID   int64
+	lastCommentID      int64
+	lastEventID        int64
+	lastNotificationID int64
+	lastMailID         int64
+	lastSessionID      int64
+	lastAPIKeyID       int64
+	// sections and steps share one sequence
+	lastCurriculumNodeID int64
+}
+
+// New returns an empty in-memory store.Service
+func New() store.Service {
+	return &service{
+		users:     make(map[int64]store.User),
+		resources: make(map[int64]store.Resource),
+		revisions: make(map[int64][]store.Revision),
+		reviews:   make(map[int64]map[int64]store.Review),
+		votes:     make(map[int64]map[int64]int),
+		comments:  make(map[int64]store.Comment),
+		bookmarks: make(map[int64]map[int64]time.Time),
+
+		followedUsers: make(map[int64]map[int64]bool),
+		followedTags:  make(map[int64]map[string]bool),
+		notifications: make(map[int64]store.Notification),
+
+		verificationSent: make(map[int64]time.Time),
+		passwordResets:   make(map[string]store.PasswordReset),
+		sessionsRevoked:  make(map[int64]time.Time),
+		outbox:           make(map[int64]store.OutboxMail),
+		sessions:         make(map[int64]store.Session),
+		refreshTokens:    make(map[string]refreshToken),
+		apiKeys:          make(map[int64]store.APIKey),
+		apiKeyHashes:     make(map[string]int64),
+		accounts:         make(map[string]map[string]store.ExternalAccount),
+
+		collections: make(map[int64]store.Collection),
+		curriculums: make(map[int64]store.Curriculum),
+		progress:    make(map[int64]map[int64]store.StepProgress),
+	}
+}
+
+// Authentication Functions
+
+func (s *service) Auth(user store.User) (store.User, error) {
+	s.mu.RLock()
+	stored, ok := s.userByUsername(user.Username)
+	s.mu.RUnlock()
+	if !ok {
+		return store.User{}, store.ErrNoResults
+	}
+
+	if !auth.VerifyPassword(stored.PasswordHash, []byte(user.Password)) {
+		return store.User{}, errors.New("Incorrect Password")
+	}
+
+	return public(stored), nil
+}
+
+// User Functions
+
+func (s *service) CreateUser(user store.User) (int64, error) {
+	user = newUser(user)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.createUser(user)
+}
+
+func (s *service) GetUser(id int64) (store.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return store.User{ID: id}, store.ErrNoResults
+	}
+	return public(user), nil
+}
+
+// PatchUser only overwrites the fields that are set on user
+func (s *service) PatchUser(user store.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.users[user.ID]
+	if !ok {
+		return store.ErrNoResults
+	}
+
+	if user.Username != "" && user.Username != stored.Username {
+		if _, ok := s.userByUsername(user.Username); ok {
+			return errors.New("Username exists, cannot update")
+		}
+		stored.Username = user.Username
+	}
+	if user.Email != "" {
+		stored.Email = user.Email
+	}
+	if user.FirstName != "" {
+		stored.FirstName = user.FirstName
+	}
+	if user.LastName != "" {
+		stored.LastName = user.LastName
+	}
+	if user.Password != "" {
+		stored.PasswordHash = auth.GeneratePasswordHash([]byte(user.Password))
+	}
+
+	s.users[stored.ID] = stored
+	return nil
+}
+
+func (s *service) DeleteUser(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[id]; !ok {
+		return store.ErrNoResults
+	}
+	delete(s.users, id)
+	return nil
+}
+
+func (s *service) GetUsers() ([]store.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.users) == 0 {
+		return nil, store.ErrNoResults
+	}
+
+	users := make([]store.User, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, public(user))
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+
+	return users, nil
+}
+
+func (s *service) CheckUsername(user store.User) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.userByUsername(user.Username); ok {
+		return store.ErrUsernameExists
+	}
+	return nil
+}
+
+func (s *service) SetRole(id int64, role string, granted bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return store.ErrNoResults
+	}
+
+	user.Roles = store.SetRole(user.Roles, role, granted)
+	s.users[id] = user
+	return nil
+}
+
+func (s *service) VerifyUser(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return store.ErrNoResults
+	}
+
+	user.Verified = true
+	s.users[id] = user
+	return nil
+}
+
+func (s *service) ClaimVerificationSend(id int64, interval time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[id]; !ok {
+		return false, store.ErrNoResults
+	}
+
+	now := time.Now()
+	if sent, ok := s.verificationSent[id]; ok && now.Sub(sent) < interval {
+		return false, nil
+	}
+	s.verificationSent[id] = now
+	return true, nil
+}
+
+// GetUserByEmail picks the lowest ID when several users share the email
+func (s *service) GetUserByEmail(email string) (store.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var found store.User
+	for _, user := range s.users {
+		if email != "" && strings.EqualFold(user.Email, email) && (found.ID == 0 || user.ID < found.ID) {
+			found = user
+		}
+	}
+	if found.ID == 0 {
+		return found, store.ErrNoResults
+	}
+	return public(found), nil
+}
+
+func (s *service) Close() error {
+	return nil
+}
+
+// newUser hashes the password of a user about to be created and clears what a new user
+// can't set themselves, it's kept out of the lock since hashing is slow on purpose
+func newUser(user store.User) store.User {
+	user.PasswordHash = auth.GeneratePasswordHash([]byte(user.Password))
+	user.Password = ""
+	user.Roles = nil
+	user.Verified = false
+	user.Notifications = nil
+	return user
+}
+
+// createUser expects the caller to hold the lock and user to come from newUser
+func (s *service) createUser(user store.User) (int64, error) {
+	if _, ok := s.userByUsername(user.Username); ok {
+		return 0, store.ErrUsernameExists
+	}
+
+	s.lastUserID++
+	user.ID = s.lastUserID
+	s.users[user.ID] = user
+
+	return user.ID, nil
+}
+
+// userByUsername expects the caller to hold the lock
+func (s *service) userByUsername(username string) (store.User, bool) {
+	for _, user := range s.users {
+		if user.Username == username {
+			return user, true
+		}
+	}
+	return store.User{}, false
+}
+
+// public strips the credentials from a stored user before it's handed out
+func public(user store.User) store.User {
+	user.Password = ""
+	user.PasswordHash = ""
+	return user
+}
+
+// Resource Functions
+
+func (s *service) CreateResource(resource store.Resource) (int64, error) {
+	tags, err := store.NormalizeTags(resource.Tags)
+	if err != nil {
+		return 0, err
+	}
+	resource.Tags = tags
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.resources {
+		if existing.URL == resource.URL {
+			return 0, errors.New("Resource URL already exists")
+		}
+	}
+
+	s.lastResourceID++
+	resource.ID = s.lastResourceID
+	resource.Approved = false
+	resource.Rejected = false
+	resource.RejectionReason = ""
+	resource.ModeratedBy, resource.ModeratedAt, resource.ApprovedAt = 0, nil, nil
+	resource.Deleted = false
+	resource.Rating, resource.RatingCount = 0, 0
+	resource.Score, resource.BookmarkCount = 0, 0
+	resource.Bookmarked = false
+	resource.CreatedAt = time.Now()
+	resource.Hot = store.HotScore(0, resource.CreatedAt)
+	s.resources[resource.ID] = resource
+
+	// the submitted content is the first revision
+	s.recordRevision(resource.ID, resource.Submitter, store.Diff(store.Resource{}, resource))
+
+	return resource.ID, nil
+}
+
+func (s *service) GetResource(id int64) (store.Resource, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	resource, ok := s.resources[id]
+	if !ok || resource.Deleted {
+		return store.Resource{ID: id}, store.ErrNoResults
+	}
+	return resource, nil
+}
+
+func (s *service) GetResources(query store.ResourceQuery) ([]store.Resource, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	resources := make([]store.Resource, 0, len(s.resources))
+	for _, resource := range s.resources {
+		resources = append(resources, resource)
+	}
+
+	return query.Apply(resources), nil
+}
+
+// UpdateResource edits the name, description and url of a resource as editor, the original
+// submitter always stays the same and every change is kept as a revision
+func (s *service) UpdateResource(resource store.Resource, editor int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.updateContent(resource.ID, resource, editor)
+}
+
+// updateContent expects the caller to hold the write lock
+func (s *service) updateContent(id int64, content store.Resource, editor int64) error {
+	stored, ok := s.resources[id]
+	if !ok || stored.Deleted {
+		return store.ErrNoResults
+	}
+
+	changes := store.Diff(stored, content)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	if _, ok := changes["url"]; ok {
+		for otherID, existing := range s.resources {
+			if otherID != id && existing.URL == content.URL {
+				return errors.New("Resource URL already exists")
+			}
+		}
+	}
+
+	s.resources[id] = store.WithContent(stored, content)
+	s.recordRevision(id, editor, changes)
+	return nil
+}
+
+// recordRevision expects the caller to hold the write lock
+func (s *service) recordRevision(resourceID, editor int64, changes map[string]store.Change) {
+	s.revisions[resourceID] = append(s.revisions[resourceID], store.Revision{
+		Revision:  int64(len(s.revisions[resourceID]) + 1),
+		Resource:  resourceID,
+		Editor:    editor,
+		CreatedAt: time.Now(),
+		Changes:   changes,
+	})
+}
+
+func (s *service) GetRevisions(resourceID int64) ([]store.Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	resource, ok := s.resources[resourceID]
+	if !ok || resource.Deleted {
+		return nil, store.ErrNoResults
+	}
+
+	return append([]store.Revision(nil), s.revisions[resourceID]...), nil
+}
+
+// RevertResource restores the content a resource had at revision, the revert is recorded as
+// a new revision so it can be undone as well
+func (s *service) RevertResource(resourceID, revision, editor int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	content, err := store.Replay(s.revisions[resourceID], revision)
+	if err != nil {
+		return err
+	}
+
+	return s.updateContent(resourceID, content, editor)
+}
+
+// DeleteResource only flags the resource as deleted, it is never removed
+func (s *service) DeleteResource(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resource, ok := s.resources[id]
+	if !ok || resource.Deleted {
+		return store.ErrNoResults
+	}
+
+	resource.Deleted = true
+	s.resources[id] = resource
+	return nil
+}
+
+// Moderation Functions
+
+// GetPendingResources lists live resources that haven't been approved or rejected, oldest first
+func (s *service) GetPendingResources(page store.Page) ([]store.Resource, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var pending []store.Resource
+	for _, resource := range s.resources {
+		if !resource.Deleted && !resource.Approved && !resource.Rejected {
+			pending = append(pending, resource)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].ID < pending[j].ID })
+
+	start, end := page.Paginate(len(pending))
+	return pending[start:end], nil
+}
+
+func (s *service) ApproveResource(id, moderator int64) (bool, error) {
+	return s.moderate(id, moderator, true, "")
+}
+
+func (s *service) RejectResource(id, moderator int64, reason string) error {
+	_, err := s.moderate(id, moderator, false, reason)
+	return err
+}
+
+// moderate reports whether it changed the resource, approving an approved resource doesn't
+func (s *service) moderate(id, moderator int64, approved bool, reason string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resource, ok := s.resources[id]
+	if !ok || resource.Deleted {
+		return false, store.ErrNoResults
+	}
+	if approved && resource.Approved {
+		return false, nil
+	}
+
+	// only the first approval makes the feed, edits send a resource back to be approved again
+	now := time.Now()
+	if approved && resource.ApprovedAt == nil {
+		s.record(store.EventResourceApproved, resource.Submitter, id, resource.Tags)
+		resource.ApprovedAt = &now
+	}
+
+	resource.Approved = approved
+	resource.Rejected = !approved
+	resource.RejectionReason = reason
+	resource.ModeratedBy, resource.ModeratedAt = moderator, &now
+	s.resources[id] = resource
+	return true, nil
+}
+
+// Search Functions
+
+func (s *service) Search(query store.SearchQuery) ([]store.SearchResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	resources := make([]store.Resource, 0, len(s.resources))
+	for _, resource := range s.resources {
+		resources = append(resources, resource)
+	}
+
+	return store.NaiveSearch(resources, query), nil
+}
+
+// Tag Functions
+
+func (s *service) AddTags(resourceID int64, tags []string) error {
+	tags, err := store.NormalizeTags(tags)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resource, ok := s.resources[resourceID]
+	if !ok || resource.Deleted {
+		return store.ErrNoResults
+	}
+
+	// copy so slices already handed out by GetResource are never written to
+	resource.Tags, _ = store.NormalizeTags(append(append([]string{}, resource.Tags...), tags...))
+	s.resources[resourceID] = resource
+	return nil
+}
+
+func (s *service) RemoveTags(resourceID int64, tags []string) error {
+	tags, err := store.NormalizeTags(tags)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resource, ok := s.resources[resourceID]
+	if !ok || resource.Deleted {
+		return store.ErrNoResults
+	}
+
+	resource.Tags = without(resource.Tags, tags)
+	s.resources[resourceID] = resource
+	return nil
+}
+
+// GetTags only knows about tags that are on an approved resource, so every count is at least one
+func (s *service) GetTags() ([]store.Tag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]int64)
+	for _, resource := range s.resources {
+		if resource.Deleted || !resource.Approved {
+			continue
+		}
+		for _, tag := range resource.Tags {
+			counts[tag]++
+		}
+	}
+
+	tags := make([]store.Tag, 0, len(counts))
+	for name, count := range counts {
+		tags = append(tags, store.Tag{Name: name, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Name < tags[j].Name
+	})
+
+	return tags, nil
+}
+
+// GetTaggedResources only lists approved resources
+func (s *service) GetTaggedResources(tag string) ([]store.Resource, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var resources []store.Resource
+	for _, resource := range s.resources {
+		if !resource.Deleted && resource.Approved && contains(resource.Tags, tag) {
+			resources = append(resources, resource)
+		}
+	}
+	sort.Slice(resources, func(i, j int) bool { return resources[i].ID < resources[j].ID })
+
+	return resources, nil
+}
+
+func (s *service) MergeTags(from, into string) error {
+	tags, err := store.NormalizeTags([]string{from})
+	if err != nil {
+		return err
+	}
+	from = tags[0]
+	if tags, err = store.NormalizeTags([]string{into}); err != nil {
+		return err
+	}
+	into = tags[0]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	merged := false
+	for id, resource := range s.resources {
+		if resource.Deleted || !contains(resource.Tags, from) {
+			continue
+		}
+		merged = true
+		resource.Tags, _ = store.NormalizeTags(append(without(resource.Tags, []string{from}), into))
+		s.resources[id] = resource
+	}
+	if !merged {
+		return store.ErrNoResults
+	}
+
+	for _, followed := range s.followedTags {
+		if followed[from] {
+			delete(followed, from)
+			followed[into] = true
+		}
+	}
+	return nil
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// without returns ss minus everything in remove, keeping the order of ss
+func without(ss []string, remove []string) []string {
+	out := make([]string, 0, len(ss))
+	for _, s := range ss {
+		if !contains(remove, s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
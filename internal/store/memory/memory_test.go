@@ -0,0 +1,229 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/natethinks/instruu-api/internal/store"
+	"github.com/natethinks/instruu-api/internal/store/storetest"
+)
+
+func TestCreateAndAuthUser(t *testing.T) {
+	sto := New()
+
+	id, err := sto.CreateUser(store.User{Username: "nate", Password: "testing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := sto.GetUser(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Username != "nate" || user.PasswordHash != "" {
+		t.Errorf("unexpected user returned: %+v", user)
+	}
+
+	if _, err := sto.Auth(store.User{Username: "nate", Password: "testing"}); err != nil {
+		t.Errorf("auth with correct password: %v", err)
+	}
+	if _, err := sto.Auth(store.User{Username: "nate", Password: "wrong"}); err == nil {
+		t.Error("auth with wrong password succeeded")
+	}
+
+	if err := sto.CheckUsername(store.User{Username: "nate"}); err == nil {
+		t.Error("existing username passed the check")
+	}
+}
+
+func TestDeleteResource(t *testing.T) {
+	sto := New()
+
+	id, err := sto.CreateResource(store.Resource{Name: "Go Tour", URL: "https://tour.golang.org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sto.CreateResource(store.Resource{Name: "Duplicate", URL: "https://tour.golang.org"}); err == nil {
+		t.Error("duplicate URL was accepted")
+	}
+
+	if err := sto.DeleteResource(id); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sto.GetResource(id); err != store.ErrNoResults {
+		t.Errorf("expected ErrNoResults for deleted resource, got %v", err)
+	}
+
+	resources, err := sto.GetResources(store.ResourceQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources) != 0 {
+		t.Errorf("deleted resource was listed: %+v", resources)
+	}
+}
+
+func TestTags(t *testing.T) {
+	sto := New()
+
+	first, _ := sto.CreateResource(store.Resource{URL: "https://tour.golang.org", Tags: []string{"Go", " beginner "}})
+	second, _ := sto.CreateResource(store.Resource{URL: "https://gobyexample.com", Tags: []string{"go"}})
+	sto.ApproveResource(first, 1)
+	sto.ApproveResource(second, 1)
+
+	if err := sto.RemoveTags(first, []string{"beginner"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sto.AddTags(second, []string{"examples"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tags, err := sto.GetTags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 2 || tags[0] != (store.Tag{Name: "go", Count: 2}) {
+		t.Errorf("unexpected tags: %+v", tags)
+	}
+
+	if err := sto.AddTags(42, []string{"go"}); err != store.ErrNoResults {
+		t.Errorf("expected ErrNoResults tagging a missing resource, got %v", err)
+	}
+}
+
+func TestStoreTags(t *testing.T) {
+	storetest.Tags(t, New())
+}
+
+func TestStoreDeleteUser(t *testing.T) {
+	storetest.DeleteUser(t, New())
+}
+
+func TestStoreUsernames(t *testing.T) {
+	storetest.Usernames(t, New())
+}
+
+func TestStoreExternalUser(t *testing.T) {
+	storetest.ExternalUser(t, New())
+}
+
+func TestStoreModeration(t *testing.T) {
+	storetest.Moderation(t, New())
+}
+
+func TestRevertResource(t *testing.T) {
+	sto := New()
+
+	id, _ := sto.CreateResource(store.Resource{Name: "Go Tour", Description: "Learn Go", URL: "https://tour.golang.org", Submitter: 1})
+
+	if err := sto.UpdateResource(store.Resource{ID: id, Name: "A Tour of Go", Description: "Learn Go", URL: "https://tour.golang.org"}, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	revisions, err := sto.GetRevisions(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 || revisions[1].Editor != 2 || revisions[1].Changes["name"] != (store.Change{From: "Go Tour", To: "A Tour of Go"}) {
+		t.Fatalf("unexpected revisions: %+v", revisions)
+	}
+
+	if err := sto.RevertResource(id, 1, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	resource, _ := sto.GetResource(id)
+	if resource.Name != "Go Tour" || resource.Submitter != 1 {
+		t.Errorf("unexpected resource after revert: %+v", resource)
+	}
+
+	if revisions, _ := sto.GetRevisions(id); len(revisions) != 3 {
+		t.Errorf("expected the revert to be recorded, got %+v", revisions)
+	}
+}
+
+func TestCollectionItems(t *testing.T) {
+	sto := New()
+
+	first, _ := sto.CreateResource(store.Resource{Name: "Go Tour", URL: "https://tour.golang.org"})
+	second, _ := sto.CreateResource(store.Resource{Name: "Effective Go", URL: "https://golang.org/doc/effective_go"})
+
+	id, err := sto.CreateCollection(store.Collection{Owner: 1, Title: "Learning Go", Visibility: store.VisibilityPublic})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sto.AddCollectionItem(id, store.CollectionItem{Resource: first}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sto.AddCollectionItem(id, store.CollectionItem{Resource: second, Note: "read after the tour"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sto.AddCollectionItem(id, store.CollectionItem{Resource: 99}); err != store.ErrNoResults {
+		t.Errorf("expected a missing resource to return ErrNoResults, got %v", err)
+	}
+
+	if err := sto.SetCollectionItems(id, []store.CollectionItem{{Resource: second}, {Resource: first}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sto.DeleteResource(second); err != nil {
+		t.Fatal(err)
+	}
+
+	collection, err := sto.GetCollection(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if collection.ItemCount != 1 || len(collection.Items) != 1 || collection.Items[0].Resource != first {
+		t.Errorf("unexpected items after reorder and delete: %+v", collection)
+	}
+}
+
+func TestReviewRating(t *testing.T) {
+	sto := New()
+
+	id, _ := sto.CreateResource(store.Resource{Name: "Go Tour", URL: "https://tour.golang.org"})
+	for reviewer, rating := range []int{5, 2, 4} {
+		if err := sto.SetReview(store.Review{Resource: id, Reviewer: int64(reviewer + 1), Rating: rating}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a second review from the same user replaces the first
+	if err := sto.SetReview(store.Review{Resource: id, Reviewer: 2, Rating: 3, Text: "better after a second look"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sto.DeleteReview(id, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	resource, err := sto.GetResource(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resource.Rating != 4 || resource.RatingCount != 2 {
+		t.Errorf("unexpected rating: %v from %d reviews", resource.Rating, resource.RatingCount)
+	}
+}
+
+func TestVote(t *testing.T) {
+	sto := New()
+
+	id, _ := sto.CreateResource(store.Resource{Name: "Go Tour", URL: "https://tour.golang.org"})
+	for _, vote := range []struct {
+		voter int64
+		value int
+	}{{1, 1}, {2, 1}, {3, -1}, {2, -1}, {1, 0}} {
+		if err := sto.Vote(id, vote.voter, vote.value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resource, err := sto.GetResource(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resource.Score != -2 || resource.Hot != store.HotScore(-2, resource.CreatedAt) {
+		t.Errorf("unexpected score %d with hot %v", resource.Score, resource.Hot)
+	}
+}
@@ -0,0 +1,106 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Notification Functions
+
+func (s *service) CreateNotification(notification store.Notification) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[notification.User]; !ok {
+		return 0, store.ErrNoResults
+	}
+
+	s.lastNotificationID++
+	notification.ID = s.lastNotificationID
+	notification.Read = false
+	notification.CreatedAt = time.Now()
+	s.notifications[notification.ID] = notification
+
+	return notification.ID, nil
+}
+
+// GetNotifications lists a user's notifications, newest first
+func (s *service) GetNotifications(user int64, query store.NotificationQuery) ([]store.Notification, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var notifications []store.Notification
+	for _, notification := range s.notifications {
+		if notification.User != user || (query.UnreadOnly && notification.Read) {
+			continue
+		}
+		notifications = append(notifications, notification)
+	}
+	sort.Slice(notifications, func(i, j int) bool { return notifications[i].ID > notifications[j].ID })
+
+	start, end := query.Page.Paginate(len(notifications))
+	return notifications[start:end], nil
+}
+
+func (s *service) UnreadNotifications(user int64) (unread int64, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, notification := range s.notifications {
+		if notification.User == user && !notification.Read {
+			unread++
+		}
+	}
+	return unread, nil
+}
+
+func (s *service) MarkNotificationRead(user, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	notification, ok := s.notifications[id]
+	if !ok || notification.User != user {
+		return store.ErrNoResults
+	}
+
+	notification.Read = true
+	s.notifications[id] = notification
+	return nil
+}
+
+func (s *service) MarkAllNotificationsRead(user int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, notification := range s.notifications {
+		if notification.User == user && !notification.Read {
+			notification.Read = true
+			s.notifications[id] = notification
+		}
+	}
+	return nil
+}
+
+func (s *service) SetNotificationPreferences(id int64, preferences store.NotificationPreferences) error {
+	if err := store.ValidateNotificationPreferences(preferences); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return store.ErrNoResults
+	}
+
+	// copy so the caller's map is never shared with the store
+	user.Notifications = make(store.NotificationPreferences, len(preferences))
+	for notificationType, enabled := range preferences {
+		user.Notifications[notificationType] = enabled
+	}
+	s.users[id] = user
+	return nil
+}
@@ -0,0 +1,74 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Outbox Functions
+
+func (s *service) QueueMail(mail store.OutboxMail) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastMailID++
+	mail.ID = s.lastMailID
+	mail.Attempts = 0
+	mail.LastError = ""
+	mail.CreatedAt = time.Now()
+	mail.NextAttemptAt = mail.CreatedAt
+	s.outbox[mail.ID] = mail
+	return mail.ID, nil
+}
+
+func (s *service) ClaimMail(limit int, lease time.Duration) ([]store.OutboxMail, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var due []store.OutboxMail
+	for _, mail := range s.outbox {
+		if !mail.NextAttemptAt.IsZero() && !mail.NextAttemptAt.After(now) {
+			due = append(due, mail)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].ID < due[j].ID })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	for i := range due {
+		due[i].Attempts++
+		due[i].NextAttemptAt = now.Add(lease)
+		s.outbox[due[i].ID] = due[i]
+	}
+	return due, nil
+}
+
+func (s *service) DeleteMail(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.outbox[id]; !ok {
+		return store.ErrNoResults
+	}
+	delete(s.outbox, id)
+	return nil
+}
+
+func (s *service) RetryMail(id int64, lastError string, retryAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mail, ok := s.outbox[id]
+	if !ok {
+		return store.ErrNoResults
+	}
+
+	mail.LastError = lastError
+	mail.NextAttemptAt = retryAt
+	s.outbox[id] = mail
+	return nil
+}
@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Progress Functions
+
+func (s *service) SetStepProgress(learner, curriculumID, stepID int64, status string) error {
+	if err := store.ValidateProgressStatus(status); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	curriculum, ok := s.curriculums[curriculumID]
+	if !ok {
+		return store.ErrNoResults
+	}
+	if _, err := curriculum.FindStep(stepID); err != nil {
+		return err
+	}
+
+	steps, ok := s.progress[learner]
+	if !ok {
+		steps = make(map[int64]store.StepProgress)
+		s.progress[learner] = steps
+	}
+
+	var existing *store.StepProgress
+	if p, ok := steps[stepID]; ok {
+		existing = &p
+	}
+	progress := store.MarkProgress(existing, status, time.Now())
+	progress.Learner, progress.Curriculum, progress.Step = learner, curriculumID, stepID
+	steps[stepID] = progress
+	return nil
+}
+
+func (s *service) GetStepProgress(learner, curriculumID int64) ([]store.StepProgress, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var progress []store.StepProgress
+	for _, p := range s.progress[learner] {
+		if curriculumID == 0 || p.Curriculum == curriculumID {
+			progress = append(progress, p)
+		}
+	}
+	sort.Slice(progress, func(i, j int) bool { return progress[i].Step < progress[j].Step })
+
+	return progress, nil
+}
@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Password Reset Functions
+
+func (s *service) CreatePasswordReset(reset store.PasswordReset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[reset.User]; !ok {
+		return store.ErrNoResults
+	}
+
+	now := time.Now()
+	for hash, stored := range s.passwordResets {
+		if now.After(stored.ExpiresAt) {
+			delete(s.passwordResets, hash)
+		}
+	}
+
+	reset.CreatedAt = now
+	s.passwordResets[reset.TokenHash] = reset
+	return nil
+}
+
+func (s *service) ResetPassword(tokenHash, passwordHash string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	reset, ok := s.passwordResets[tokenHash]
+	if !ok || now.After(reset.ExpiresAt) {
+		return 0, store.ErrNoResults
+	}
+	user, ok := s.users[reset.User]
+	if !ok {
+		return 0, store.ErrNoResults
+	}
+
+	for hash, stored := range s.passwordResets {
+		if stored.User == reset.User {
+			delete(s.passwordResets, hash)
+		}
+	}
+
+	user.PasswordHash = passwordHash
+	s.users[user.ID] = user
+	s.sessionsRevoked[user.ID] = now
+	for id, session := range s.sessions {
+		if session.User == user.ID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+			s.sessions[id] = session
+		}
+	}
+	return user.ID, nil
+}
+
+func (s *service) SessionsRevokedAt(user int64) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.users[user]; !ok {
+		return time.Time{}, store.ErrNoResults
+	}
+	return s.sessionsRevoked[user], nil
+}
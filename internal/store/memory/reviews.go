@@ -0,0 +1,95 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Review Functions
+
+// updateRating recomputes the average rating of a resource, the caller must hold the lock
+func (s *service) updateRating(resourceID int64) {
+	var ratings []int
+	for _, review := range s.reviews[resourceID] {
+		ratings = append(ratings, review.Rating)
+	}
+
+	resource := s.resources[resourceID]
+	resource.Rating, resource.RatingCount = store.AverageRating(ratings)
+	s.resources[resourceID] = resource
+}
+
+func (s *service) SetReview(review store.Review) error {
+	if err := store.ValidateReview(review); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.resourceLive(review.Resource) {
+		return store.ErrNoResults
+	}
+
+	reviews, ok := s.reviews[review.Resource]
+	if !ok {
+		reviews = make(map[int64]store.Review)
+		s.reviews[review.Resource] = reviews
+	}
+
+	review.UpdatedAt = time.Now()
+	review.CreatedAt = review.UpdatedAt
+	if existing, ok := reviews[review.Reviewer]; ok {
+		review.CreatedAt = existing.CreatedAt
+	}
+	reviews[review.Reviewer] = review
+
+	s.updateRating(review.Resource)
+	return nil
+}
+
+func (s *service) GetReview(resourceID, reviewer int64) (store.Review, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	review, ok := s.reviews[resourceID][reviewer]
+	if !ok {
+		return store.Review{Resource: resourceID, Reviewer: reviewer}, store.ErrNoResults
+	}
+	return review, nil
+}
+
+// GetReviews lists the reviews of a resource, most recently updated first
+func (s *service) GetReviews(resourceID int64, page store.Page) ([]store.Review, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reviews := make([]store.Review, 0, len(s.reviews[resourceID]))
+	for _, review := range s.reviews[resourceID] {
+		reviews = append(reviews, review)
+	}
+	sort.Slice(reviews, func(i, j int) bool {
+		if !reviews[i].UpdatedAt.Equal(reviews[j].UpdatedAt) {
+			return reviews[i].UpdatedAt.After(reviews[j].UpdatedAt)
+		}
+		return reviews[i].Reviewer < reviews[j].Reviewer
+	})
+
+	start, end := page.Paginate(len(reviews))
+	return reviews[start:end], nil
+}
+
+func (s *service) DeleteReview(resourceID, reviewer int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.reviews[resourceID][reviewer]; !ok {
+		return store.ErrNoResults
+	}
+	delete(s.reviews[resourceID], reviewer)
+
+	s.updateRating(resourceID)
+	return nil
+}
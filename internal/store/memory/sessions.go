@@ -0,0 +1,134 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// refreshToken is what's kept of a refresh token, tokens that were rotated out are kept to
+// catch them being used again
+type refreshToken struct {
+	session int64
+	rotated bool
+}
+
+// Session Functions
+
+func (s *service) CreateSession(session store.Session, tokenHash string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[session.User]; !ok {
+		return 0, store.ErrNoResults
+	}
+
+	s.lastSessionID++
+	session.ID = s.lastSessionID
+	session.CreatedAt = time.Now()
+	session.LastUsedAt = session.CreatedAt
+	session.RevokedAt = nil
+	session.Current = false
+	s.sessions[session.ID] = session
+	s.refreshTokens[tokenHash] = refreshToken{session: session.ID}
+	return session.ID, nil
+}
+
+func (s *service) GetSession(id int64) (store.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return store.Session{ID: id}, store.ErrNoResults
+	}
+	return session, nil
+}
+
+func (s *service) GetSessions(user int64) ([]store.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	var sessions []store.Session
+	for _, session := range s.sessions {
+		if session.User == user && session.Active(now) {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].LastUsedAt.Equal(sessions[j].LastUsedAt) {
+			return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+		}
+		return sessions[i].ID > sessions[j].ID
+	})
+	return sessions, nil
+}
+
+func (s *service) RotateSession(tokenHash, newHash string, expiresAt time.Time) (store.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	token, ok := s.refreshTokens[tokenHash]
+	if !ok {
+		return store.Session{}, store.ErrNoResults
+	}
+	session, ok := s.sessions[token.session]
+	if !ok || !session.Active(now) {
+		return store.Session{}, store.ErrNoResults
+	}
+
+	if token.rotated {
+		session.RevokedAt = &now
+		s.sessions[session.ID] = session
+		return store.Session{}, store.ErrReusedToken
+	}
+
+	token.rotated = true
+	s.refreshTokens[tokenHash] = token
+	s.refreshTokens[newHash] = refreshToken{session: session.ID}
+
+	session.LastUsedAt = now
+	session.ExpiresAt = expiresAt
+	s.sessions[session.ID] = session
+	return session, nil
+}
+
+func (s *service) RevokeSession(user, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok || session.User != user {
+		return store.ErrNoResults
+	}
+	s.revokeSession(session)
+	return nil
+}
+
+func (s *service) RevokeSessionByToken(tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.refreshTokens[tokenHash]
+	if !ok {
+		return store.ErrNoResults
+	}
+	if session, ok := s.sessions[token.session]; ok {
+		s.revokeSession(session)
+	}
+	return nil
+}
+
+// revokeSession expects the caller to hold the lock, revoking a session again keeps when
+// it was first revoked
+func (s *service) revokeSession(session store.Session) {
+	if session.RevokedAt != nil {
+		return
+	}
+	now := time.Now()
+	session.RevokedAt = &now
+	s.sessions[session.ID] = session
+}
@@ -0,0 +1,39 @@
+package memory
+
+import (
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Vote Functions
+
+func (s *service) Vote(resourceID, voter int64, value int) error {
+	if err := store.ValidateVote(value); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.resourceLive(resourceID) {
+		return store.ErrNoResults
+	}
+
+	votes, ok := s.votes[resourceID]
+	if !ok {
+		votes = make(map[int64]int)
+		s.votes[resourceID] = votes
+	}
+
+	previous := votes[voter]
+	if value == 0 {
+		delete(votes, voter)
+	} else {
+		votes[voter] = value
+	}
+
+	resource := s.resources[resourceID]
+	resource.Score += int64(value - previous)
+	resource.Hot = store.HotScore(resource.Score, resource.CreatedAt)
+	s.resources[resourceID] = resource
+	return nil
+}
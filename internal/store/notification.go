@@ -0,0 +1,122 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Notification types
+const (
+	// NotificationApproved and NotificationRejected tell a submitter how moderation went
+	NotificationApproved = "resource.approved"
+	NotificationRejected = "resource.rejected"
+	// NotificationComment is a top level comment on a resource the user submitted
+	NotificationComment = "comment"
+	// NotificationReply is a reply to one of the user's comments
+	NotificationReply = "comment.reply"
+	// NotificationReview is a new review of a resource the user submitted
+	NotificationReview = "review"
+	// NotificationFollow is someone following the user
+	NotificationFollow = "follow"
+)
+
+// notificationTypes are the types users can turn on and off
+var notificationTypes = []string{
+	NotificationApproved, NotificationRejected, NotificationComment,
+	NotificationReply, NotificationReview, NotificationFollow,
+}
+
+// Notification tells a user something happened that involves them, Resource and Comment
+// are set when the type is about one
+type Notification struct {
+	ID        int64     `json:"id"`
+	User      int64     `json:"-"`
+	Type      string    `json:"type"`
+	Actor     int64     `json:"actor"`
+	Resource  int64     `json:"resource,omitempty"`
+	Comment   int64     `json:"comment,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	Read      bool      `json:"read"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Notifications is a page of notifications along with how many are unread in total
+type Notifications struct {
+	Unread        int64          `json:"unread"`
+	Notifications []Notification `json:"notifications"`
+}
+
+// NotificationQuery lists a user's notifications, newest first
+type NotificationQuery struct {
+	UnreadOnly bool
+	Page
+}
+
+// ParseNotificationQuery validates the unread, limit and offset url query parameters,
+// anything else is rejected
+func ParseNotificationQuery(params map[string][]string) (NotificationQuery, error) {
+	query := NotificationQuery{Page: Page{Limit: DefaultLimit}}
+
+	for param, values := range params {
+		if len(values) > 1 {
+			return query, &QueryError{param, "can only be given once"}
+		}
+		value := ""
+		if len(values) > 0 {
+			value = values[0]
+		}
+
+		var err error
+		switch param {
+		case "unread":
+			query.UnreadOnly, err = strconv.ParseBool(value)
+		case "limit":
+			query.Limit, err = parseLimit(value)
+		case "offset":
+			query.Offset, err = parseOffset(value)
+		default:
+			err = fmt.Errorf("unknown parameter")
+		}
+		if err != nil {
+			return query, &QueryError{param, err.Error()}
+		}
+	}
+
+	return query, nil
+}
+
+// NotificationPreferences turns notification types on or off, types that aren't listed
+// are on
+type NotificationPreferences map[string]bool
+
+// Enabled reports whether the user wants notifications of a type
+func (p NotificationPreferences) Enabled(notificationType string) bool {
+	enabled, ok := p[notificationType]
+	return !ok || enabled
+}
+
+// All lists every notification type with whether it's on
+func (p NotificationPreferences) All() NotificationPreferences {
+	all := make(NotificationPreferences, len(notificationTypes))
+	for _, notificationType := range notificationTypes {
+		all[notificationType] = p.Enabled(notificationType)
+	}
+	return all
+}
+
+// ValidateNotificationPreferences checks every type in the preferences is known
+func ValidateNotificationPreferences(p NotificationPreferences) error {
+	for notificationType := range p {
+		known := false
+		for _, t := range notificationTypes {
+			known = known || t == notificationType
+		}
+		if !known {
+			return fmt.Errorf("unknown notification type %q, must be one of %s",
+				notificationType, strings.Join(notificationTypes, ", "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package store
+
+import "testing"
+
+func TestNotificationPreferences(t *testing.T) {
+	preferences := NotificationPreferences{NotificationFollow: false, NotificationReview: true}
+
+	if preferences.Enabled(NotificationFollow) || !preferences.Enabled(NotificationReview) {
+		t.Errorf("listed types should follow the preferences: %v", preferences)
+	}
+	if !preferences.Enabled(NotificationComment) {
+		t.Error("types that aren't listed should be on")
+	}
+
+	all := preferences.All()
+	if len(all) != len(notificationTypes) || all[NotificationFollow] || !all[NotificationReply] {
+		t.Errorf("unexpected preferences: %v", all)
+	}
+
+	if err := ValidateNotificationPreferences(NotificationPreferences{"digest": true}); err == nil {
+		t.Error("expected unknown types to be rejected")
+	}
+}
+
+func TestParseNotificationQuery(t *testing.T) {
+	query, err := ParseNotificationQuery(map[string][]string{"unread": {"true"}, "offset": {"20"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !query.UnreadOnly || query.Offset != 20 || query.Limit != DefaultLimit {
+		t.Errorf("unexpected query: %+v", query)
+	}
+
+	if _, err := ParseNotificationQuery(map[string][]string{"unread": {"maybe"}}); err == nil {
+		t.Error("expected a malformed unread flag to be rejected")
+	}
+}
@@ -0,0 +1,20 @@
+package store
+
+import "time"
+
+// OutboxMail is mail waiting in the outbox to be sent, it's kept until a send succeeds so
+// nothing is lost when the process dies
+type OutboxMail struct {
+	ID      int64  `json:"id"`
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	HTML    string `json:"html,omitempty"`
+	// Attempts counts every time the mail was claimed for sending
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"lastError,omitempty"`
+	// NextAttemptAt is when the mail is due to be sent, it's the zero time once the outbox
+	// gave up on it
+	NextAttemptAt time.Time `json:"nextAttemptAt"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
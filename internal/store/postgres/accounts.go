@@ -0,0 +1,85 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/natethinks/instruu-api/internal/auth"
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// uniqueViolation is the postgres error code for a broken unique constraint
+const uniqueViolation = "23505"
+
+// External Account Functions
+
+func (s *service) LinkAccount(account store.ExternalAccount) error {
+	// selecting the owner turns a missing user into no rows instead of a key violation
+	res, err := s.db.Exec(`
+		INSERT INTO external_accounts (provider, subject, owner, email)
+		SELECT $1, $2, id, $4 FROM users WHERE id = $3`,
+		account.Provider, account.Subject, account.User, account.Email)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == uniqueViolation {
+		return store.ErrAccountLinked
+	}
+	return affectedOne(res, err)
+}
+
+func (s *service) CreateExternalUser(user store.User, account store.ExternalAccount) (id int64, err error) {
+	user.PasswordHash = auth.GeneratePasswordHash([]byte(user.Password))
+	err = s.withTx(func(tx *sql.Tx) error {
+		err := tx.QueryRow(
+			"INSERT INTO users (username, email, firstname, lastname, password) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+			user.Username, user.Email, user.FirstName, user.LastName, user.PasswordHash).Scan(&id)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == uniqueViolation {
+			return store.ErrUsernameExists
+		} else if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			"INSERT INTO external_accounts (provider, subject, owner, email) VALUES ($1, $2, $3, $4)",
+			account.Provider, account.Subject, id, account.Email)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == uniqueViolation {
+			return store.ErrAccountLinked
+		}
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (s *service) GetAccountUser(provider, subject string) (user int64, err error) {
+	err = s.db.QueryRow("SELECT owner FROM external_accounts WHERE provider = $1 AND subject = $2",
+		provider, subject).Scan(&user)
+	if err == sql.ErrNoRows {
+		return 0, store.ErrNoResults
+	}
+	return user, err
+}
+
+func (s *service) GetAccounts(user int64) ([]store.ExternalAccount, error) {
+	rows, err := s.db.Query(`
+		SELECT provider, subject, owner, email, createdAt FROM external_accounts
+		WHERE owner = $1 ORDER BY createdAt`, user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []store.ExternalAccount
+	for rows.Next() {
+		var account store.ExternalAccount
+		if err := rows.Scan(&account.Provider, &account.Subject, &account.User, &account.Email, &account.CreatedAt); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, rows.Err()
+}
+
+func (s *service) UnlinkAccount(user int64, provider string) error {
+	return affectedOne(s.db.Exec("DELETE FROM external_accounts WHERE owner = $1 AND provider = $2", user, provider))
+}
@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// API Key Functions
+
+const apiKeyColumns = "id, owner, name, prefix, scopes, expiresAt, lastUsedAt, createdAt"
+
+// scanAPIKey scans a row of apiKeyColumns
+func scanAPIKey(row interface {
+	Scan(dest ...interface{}) error
+}) (key store.APIKey, err error) {
+	err = row.Scan(&key.ID, &key.User, &key.Name, &key.Prefix, pq.Array(&key.Scopes),
+		&key.ExpiresAt, &key.LastUsedAt, &key.CreatedAt)
+	return key, err
+}
+
+func (s *service) CreateAPIKey(key store.APIKey, tokenHash string) (id int64, err error) {
+	// selecting the owner turns a missing user into no rows instead of a key violation
+	err = s.db.QueryRow(`
+		INSERT INTO api_keys (owner, name, prefix, scopes, expiresAt, tokenHash)
+		SELECT id, $2, $3, $4, $5, $6 FROM users WHERE id = $1 RETURNING id`,
+		key.User, key.Name, key.Prefix, pq.Array(key.Scopes), key.ExpiresAt, tokenHash).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, store.ErrNoResults
+	}
+	return id, err
+}
+
+func (s *service) GetAPIKey(id int64) (store.APIKey, error) {
+	key, err := scanAPIKey(s.db.QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return store.APIKey{ID: id}, store.ErrNoResults
+	}
+	return key, err
+}
+
+func (s *service) GetAPIKeys(user int64) ([]store.APIKey, error) {
+	rows, err := s.db.Query("SELECT "+apiKeyColumns+" FROM api_keys WHERE owner = $1 ORDER BY id DESC", user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []store.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *service) UpdateAPIKey(key store.APIKey) error {
+	return affectedOne(s.db.Exec("UPDATE api_keys SET name = $1, scopes = $2 WHERE id = $3 AND owner = $4",
+		key.Name, pq.Array(key.Scopes), key.ID, key.User))
+}
+
+func (s *service) DeleteAPIKey(user, id int64) error {
+	return affectedOne(s.db.Exec("DELETE FROM api_keys WHERE id = $1 AND owner = $2", id, user))
+}
+
+func (s *service) UseAPIKey(tokenHash string) (store.APIKey, error) {
+	key, err := scanAPIKey(s.db.QueryRow(`
+		UPDATE api_keys SET lastUsedAt = now()
+		WHERE tokenHash = $1 AND (expiresAt IS NULL OR expiresAt > now())
+		RETURNING `+apiKeyColumns, tokenHash))
+	if err == sql.ErrNoRows {
+		return store.APIKey{}, store.ErrNoResults
+	}
+	return key, err
+}
@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Bookmark Functions
+
+func (s *service) AddBookmark(user, resourceID int64) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		if err := resourceExists(tx, resourceID); err != nil {
+			return err
+		}
+
+		res, err := tx.Exec("INSERT INTO bookmarks (owner, resource) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+			user, resourceID)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			// already saved
+			return err
+		}
+
+		_, err = tx.Exec("UPDATE resources SET bookmarkCount = bookmarkCount + 1 WHERE id = $1", resourceID)
+		return err
+	})
+}
+
+func (s *service) RemoveBookmark(user, resourceID int64) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		err := affectedOne(tx.Exec("DELETE FROM bookmarks WHERE owner = $1 AND resource = $2", user, resourceID))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("UPDATE resources SET bookmarkCount = bookmarkCount - 1 WHERE id = $1", resourceID)
+		return err
+	})
+}
+
+// GetBookmarks lists a user's saved resources, most recently saved first
+func (s *service) GetBookmarks(user int64, page store.Page) ([]store.Resource, error) {
+	rows, err := s.db.Query(`
+		SELECT `+resourceColumns+`
+		FROM bookmarks b JOIN resources r ON r.id = b.resource
+		WHERE b.owner = $1 AND r.deleted = false
+		ORDER BY b.createdAt DESC, r.id DESC
+		LIMIT $2 OFFSET $3`, user, page.Limit, page.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resources, err := scanResources(rows)
+	for i := range resources {
+		resources[i].Bookmarked = true
+	}
+	return resources, err
+}
+
+func (s *service) Bookmarked(user int64, resourceIDs []int64) (map[int64]bool, error) {
+	bookmarked := make(map[int64]bool)
+	if len(resourceIDs) == 0 {
+		return bookmarked, nil
+	}
+
+	rows, err := s.db.Query("SELECT resource FROM bookmarks WHERE owner = $1 AND resource = ANY($2)",
+		user, pq.Array(resourceIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		bookmarked[id] = true
+	}
+	return bookmarked, rows.Err()
+}
@@ -0,0 +1,197 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Collection Functions
+
+// liveItemCount counts the items of the collection aliased c whose resource isn't deleted
+const liveItemCount = `(
+	SELECT COUNT(*) FROM collection_items ci JOIN resources r ON r.id = ci.resource
+	WHERE ci.collection = c.id AND r.deleted = false)`
+
+func (s *service) CreateCollection(collection store.Collection) (id int64, err error) {
+	if err := store.ValidateCollection(&collection); err != nil {
+		return id, err
+	}
+
+	err = s.withTx(func(tx *sql.Tx) error {
+		err := tx.QueryRow(
+			"INSERT INTO collections (owner, title, description, visibility) VALUES ($1, $2, $3, $4) RETURNING id",
+			collection.Owner, collection.Title, collection.Description, collection.Visibility).Scan(&id)
+		if err != nil || collection.Visibility != store.VisibilityPublic {
+			return err
+		}
+		return record(tx, store.EventCollectionCreated, collection.Owner, id, nil)
+	})
+	return id, err
+}
+
+func (s *service) GetCollection(id int64) (collection store.Collection, err error) {
+	collection = store.Collection{ID: id}
+	err = s.db.QueryRow(`
+		SELECT c.owner, c.title, c.description, c.visibility, c.createdAt
+		FROM collections c WHERE c.id = $1`, id).Scan(
+		&collection.Owner, &collection.Title, &collection.Description, &collection.Visibility, &collection.CreatedAt)
+	if err == sql.ErrNoRows {
+		return collection, store.ErrNoResults
+	} else if err != nil {
+		return collection, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT ci.resource, ci.note
+		FROM collection_items ci JOIN resources r ON r.id = ci.resource
+		WHERE ci.collection = $1 AND r.deleted = false
+		ORDER BY ci.position`, id)
+	if err != nil {
+		return collection, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item store.CollectionItem
+		if err = rows.Scan(&item.Resource, &item.Note); err != nil {
+			return collection, err
+		}
+		collection.Items = append(collection.Items, item)
+	}
+	collection.ItemCount = len(collection.Items)
+	return collection, rows.Err()
+}
+
+// GetCollections lists collections newest first without their items
+func (s *service) GetCollections(query store.CollectionQuery) (collections []store.Collection, err error) {
+	b := &queryBuilder{}
+	if query.Owner != 0 {
+		b.and("c.owner = " + b.arg(query.Owner))
+	}
+	if query.PublicOnly {
+		b.and("c.visibility = " + b.arg(store.VisibilityPublic))
+	}
+
+	stmt := `
+		SELECT c.id, c.owner, c.title, c.description, c.visibility, c.createdAt, ` + liveItemCount + `
+		FROM collections c
+		WHERE ` + b.whereClause() + `
+		ORDER BY c.id DESC
+		LIMIT ` + b.arg(query.Limit) + ` OFFSET ` + b.arg(query.Offset)
+
+	rows, err := s.db.Query(stmt, b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c store.Collection
+		if err = rows.Scan(&c.ID, &c.Owner, &c.Title, &c.Description, &c.Visibility, &c.CreatedAt, &c.ItemCount); err != nil {
+			return collections, err
+		}
+		collections = append(collections, c)
+	}
+	return collections, rows.Err()
+}
+
+// UpdateCollection changes the title, description and visibility, the owner never changes
+func (s *service) UpdateCollection(collection store.Collection) error {
+	if err := store.ValidateCollection(&collection); err != nil {
+		return err
+	}
+
+	return s.withTx(func(tx *sql.Tx) error {
+		var owner int64
+		var visibility string
+		err := tx.QueryRow("SELECT owner, visibility FROM collections WHERE id = $1 FOR UPDATE",
+			collection.ID).Scan(&owner, &visibility)
+		if err == sql.ErrNoRows {
+			return store.ErrNoResults
+		} else if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("UPDATE collections SET title = $1, description = $2, visibility = $3 WHERE id = $4",
+			collection.Title, collection.Description, collection.Visibility, collection.ID)
+		if err != nil {
+			return err
+		}
+
+		// collections made public later are announced like new ones
+		if visibility != store.VisibilityPublic && collection.Visibility == store.VisibilityPublic {
+			return record(tx, store.EventCollectionCreated, owner, collection.ID, nil)
+		}
+		return nil
+	})
+}
+
+func (s *service) DeleteCollection(id int64) error {
+	return affectedOne(s.db.Exec("DELETE FROM collections WHERE id = $1", id))
+}
+
+// lockCollection makes sure the collection exists and serialises changes to its items
+func lockCollection(tx *sql.Tx, id int64) error {
+	err := tx.QueryRow("SELECT id FROM collections WHERE id = $1 FOR UPDATE", id).Scan(&id)
+	if err == sql.ErrNoRows {
+		return store.ErrNoResults
+	}
+	return err
+}
+
+func (s *service) AddCollectionItem(collectionID int64, item store.CollectionItem) error {
+	if err := store.ValidateCollectionItems([]store.CollectionItem{item}); err != nil {
+		return err
+	}
+
+	return s.withTx(func(tx *sql.Tx) error {
+		if err := lockCollection(tx, collectionID); err != nil {
+			return err
+		}
+		if err := resourceExists(tx, item.Resource); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(`
+			INSERT INTO collection_items (collection, resource, position, note)
+			SELECT $1, $2, COALESCE(MAX(position), -1) + 1, $3 FROM collection_items WHERE collection = $1
+			ON CONFLICT (collection, resource) DO UPDATE SET note = EXCLUDED.note`,
+			collectionID, item.Resource, item.Note)
+		return err
+	})
+}
+
+func (s *service) RemoveCollectionItem(collectionID, resourceID int64) error {
+	return affectedOne(s.db.Exec("DELETE FROM collection_items WHERE collection = $1 AND resource = $2",
+		collectionID, resourceID))
+}
+
+func (s *service) SetCollectionItems(collectionID int64, items []store.CollectionItem) error {
+	if err := store.ValidateCollectionItems(items); err != nil {
+		return err
+	}
+
+	return s.withTx(func(tx *sql.Tx) error {
+		if err := lockCollection(tx, collectionID); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("DELETE FROM collection_items WHERE collection = $1", collectionID); err != nil {
+			return err
+		}
+
+		for position, item := range items {
+			if err := resourceExists(tx, item.Resource); err != nil {
+				return err
+			}
+
+			_, err := tx.Exec("INSERT INTO collection_items (collection, resource, position, note) VALUES ($1, $2, $3, $4)",
+				collectionID, item.Resource, position, item.Note)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
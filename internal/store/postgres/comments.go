@@ -0,0 +1,121 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Comment Functions
+
+// commentColumns is the select list scanComment reads, the comments table is aliased c
+const commentColumns = `c.id, c.resource, COALESCE(c.parent, 0), COALESCE(c.thread, c.id), c.depth, ` +
+	`COALESCE(c.author, 0), c.body, c.deleted, c.createdAt, c.editedAt`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanComment(row scanner) (c store.Comment, err error) {
+	err = row.Scan(&c.ID, &c.Resource, &c.Parent, &c.Thread, &c.Depth,
+		&c.Author, &c.Body, &c.Deleted, &c.CreatedAt, &c.EditedAt)
+	return c, err
+}
+
+func scanComments(rows *sql.Rows) (comments []store.Comment, err error) {
+	defer rows.Close()
+
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return comments, err
+		}
+		comments = append(comments, comment)
+	}
+	return comments, rows.Err()
+}
+
+func (s *service) CreateComment(comment store.Comment) (id int64, err error) {
+	if err := store.ValidateCommentBody(comment.Body); err != nil {
+		return id, err
+	}
+	comment.Thread, comment.Depth = 0, 0
+
+	err = s.withTx(func(tx *sql.Tx) error {
+		if err := resourceExists(tx, comment.Resource); err != nil {
+			return err
+		}
+
+		if comment.Parent != 0 {
+			parent, err := scanComment(tx.QueryRow("SELECT "+commentColumns+" FROM comments c WHERE c.id = $1", comment.Parent))
+			if err == sql.ErrNoRows {
+				return store.ErrNoResults
+			} else if err != nil {
+				return err
+			}
+
+			if err := comment.Reply(parent); err != nil {
+				return err
+			}
+		}
+
+		return tx.QueryRow(`
+			INSERT INTO comments (resource, parent, thread, depth, author, body)
+			VALUES ($1, NULLIF($2, 0), NULLIF($3, 0), $4, NULLIF($5, 0), $6) RETURNING id`,
+			comment.Resource, comment.Parent, comment.Thread, comment.Depth, comment.Author, comment.Body).Scan(&id)
+	})
+	return id, err
+}
+
+func (s *service) GetComment(id int64) (store.Comment, error) {
+	comment, err := scanComment(s.db.QueryRow("SELECT "+commentColumns+" FROM comments c WHERE c.id = $1", id))
+	if err == sql.ErrNoRows {
+		return store.Comment{ID: id}, store.ErrNoResults
+	}
+	return comment, err
+}
+
+func (s *service) GetComments(resourceID int64, page store.Page) ([]store.Comment, error) {
+	rows, err := s.db.Query(`
+		SELECT `+commentColumns+` FROM comments c
+		WHERE c.resource = $1 AND c.parent IS NULL
+		ORDER BY c.id DESC
+		LIMIT $2 OFFSET $3`, resourceID, page.Limit, page.Offset)
+	if err != nil {
+		return nil, err
+	}
+	roots, err := scanComments(rows)
+	if err != nil || len(roots) == 0 {
+		return roots, err
+	}
+
+	threads := make([]int64, len(roots))
+	for i, root := range roots {
+		threads[i] = root.ID
+	}
+
+	rows, err = s.db.Query("SELECT "+commentColumns+" FROM comments c WHERE c.thread = ANY($1)", pq.Array(threads))
+	if err != nil {
+		return nil, err
+	}
+	replies, err := scanComments(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.Threads(roots, replies), nil
+}
+
+func (s *service) UpdateComment(id int64, body string) error {
+	if err := store.ValidateCommentBody(body); err != nil {
+		return err
+	}
+
+	return affectedOne(s.db.Exec("UPDATE comments SET body = $1, editedAt = now() WHERE id = $2 AND deleted = false",
+		body, id))
+}
+
+func (s *service) DeleteComment(id int64) error {
+	return affectedOne(s.db.Exec("UPDATE comments SET deleted = true, body = '' WHERE id = $1 AND deleted = false", id))
+}
@@ -0,0 +1,318 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Curriculum Functions
+
+// steps keep pointing at deleted resources so the owner can see what needs replacing
+
+func (s *service) CreateCurriculum(curriculum store.Curriculum) (id int64, err error) {
+	if err := store.ValidateCurriculum(curriculum); err != nil {
+		return id, err
+	}
+
+	err = s.withTx(func(tx *sql.Tx) error {
+		err := tx.QueryRow("INSERT INTO curriculums (owner, title, description) VALUES ($1, $2, $3) RETURNING id",
+			curriculum.Owner, curriculum.Title, curriculum.Description).Scan(&id)
+		if err != nil {
+			return err
+		}
+		return record(tx, store.EventCurriculumCreated, curriculum.Owner, id, nil)
+	})
+	return id, err
+}
+
+func (s *service) GetCurriculum(id int64) (curriculum store.Curriculum, err error) {
+	curriculum = store.Curriculum{ID: id}
+	err = s.db.QueryRow("SELECT owner, title, description, createdAt FROM curriculums WHERE id = $1", id).Scan(
+		&curriculum.Owner, &curriculum.Title, &curriculum.Description, &curriculum.CreatedAt)
+	if err == sql.ErrNoRows {
+		return curriculum, store.ErrNoResults
+	} else if err != nil {
+		return curriculum, err
+	}
+
+	if curriculum.Sections, err = s.curriculumSections(id); err != nil {
+		return curriculum, err
+	}
+	curriculum.Totals()
+	return curriculum, nil
+}
+
+// curriculumSections loads the sections of a curriculum along with their steps
+func (s *service) curriculumSections(curriculumID int64) ([]store.Section, error) {
+	rows, err := s.db.Query(`
+		SELECT id, title, description FROM curriculum_sections
+		WHERE curriculum = $1 ORDER BY position`, curriculumID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sections []store.Section
+	index := make(map[int64]int)
+	for rows.Next() {
+		section := store.Section{Steps: []store.Step{}}
+		if err := rows.Scan(&section.ID, &section.Title, &section.Description); err != nil {
+			return nil, err
+		}
+		index[section.ID] = len(sections)
+		sections = append(sections, section)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	steps, err := s.db.Query(`
+		SELECT st.section, st.id, st.resource, st.note, st.estimatedMinutes, st.optional
+		FROM curriculum_steps st JOIN curriculum_sections se ON se.id = st.section
+		WHERE se.curriculum = $1 ORDER BY st.position`, curriculumID)
+	if err != nil {
+		return nil, err
+	}
+	defer steps.Close()
+
+	for steps.Next() {
+		var (
+			sectionID int64
+			step      store.Step
+		)
+		if err := steps.Scan(&sectionID, &step.ID, &step.Resource, &step.Note, &step.EstimatedMinutes, &step.Optional); err != nil {
+			return nil, err
+		}
+		section := &sections[index[sectionID]]
+		section.Steps = append(section.Steps, step)
+	}
+	return sections, steps.Err()
+}
+
+// GetCurriculums lists curriculums newest first without their sections
+func (s *service) GetCurriculums(query store.CurriculumQuery) (curriculums []store.Curriculum, err error) {
+	b := &queryBuilder{}
+	if query.Owner != 0 {
+		b.and("c.owner = " + b.arg(query.Owner))
+	}
+
+	stmt := `
+		SELECT c.id, c.owner, c.title, c.description, c.createdAt, totals.steps, totals.minutes
+		FROM curriculums c, LATERAL (
+			SELECT COUNT(st.id) AS steps,
+				COALESCE(SUM(st.estimatedMinutes) FILTER (WHERE NOT st.optional), 0) AS minutes
+			FROM curriculum_sections se JOIN curriculum_steps st ON st.section = se.id
+			WHERE se.curriculum = c.id
+		) totals
+		WHERE ` + b.whereClause() + `
+		ORDER BY c.id DESC
+		LIMIT ` + b.arg(query.Limit) + ` OFFSET ` + b.arg(query.Offset)
+
+	rows, err := s.db.Query(stmt, b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c store.Curriculum
+		if err = rows.Scan(&c.ID, &c.Owner, &c.Title, &c.Description, &c.CreatedAt, &c.StepCount, &c.EstimatedMinutes); err != nil {
+			return curriculums, err
+		}
+		curriculums = append(curriculums, c)
+	}
+	return curriculums, rows.Err()
+}
+
+// UpdateCurriculum changes the title and description, the owner never changes
+func (s *service) UpdateCurriculum(curriculum store.Curriculum) error {
+	if err := store.ValidateCurriculum(curriculum); err != nil {
+		return err
+	}
+
+	return affectedOne(s.db.Exec("UPDATE curriculums SET title = $1, description = $2 WHERE id = $3",
+		curriculum.Title, curriculum.Description, curriculum.ID))
+}
+
+func (s *service) DeleteCurriculum(id int64) error {
+	return affectedOne(s.db.Exec("DELETE FROM curriculums WHERE id = $1", id))
+}
+
+// lockCurriculum makes sure the curriculum exists and serialises changes to its tree
+func lockCurriculum(tx *sql.Tx, id int64) error {
+	err := tx.QueryRow("SELECT id FROM curriculums WHERE id = $1 FOR UPDATE", id).Scan(&id)
+	if err == sql.ErrNoRows {
+		return store.ErrNoResults
+	}
+	return err
+}
+
+// reorder sets positions to follow order after checking it lists every one of ids
+func reorder(tx *sql.Tx, table string, ids, order []int64) error {
+	if err := store.ValidateOrder(ids, order); err != nil {
+		return err
+	}
+
+	for position, id := range order {
+		if _, err := tx.Exec("UPDATE "+table+" SET position = $1 WHERE id = $2", position, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryIDs runs a query returning a single column of IDs
+func queryIDs(tx *sql.Tx, query string, args ...interface{}) ([]int64, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Curriculum Section Functions
+
+func (s *service) AddCurriculumSection(curriculumID int64, section store.Section) (id int64, err error) {
+	if err := store.ValidateSection(section); err != nil {
+		return id, err
+	}
+
+	err = s.withTx(func(tx *sql.Tx) error {
+		if err := lockCurriculum(tx, curriculumID); err != nil {
+			return err
+		}
+
+		return tx.QueryRow(`
+			INSERT INTO curriculum_sections (curriculum, position, title, description)
+			SELECT $1, COALESCE(MAX(position), -1) + 1, $2, $3 FROM curriculum_sections WHERE curriculum = $1
+			RETURNING id`,
+			curriculumID, section.Title, section.Description).Scan(&id)
+	})
+	return id, err
+}
+
+func (s *service) UpdateCurriculumSection(curriculumID int64, section store.Section) error {
+	if err := store.ValidateSection(section); err != nil {
+		return err
+	}
+
+	return affectedOne(s.db.Exec(
+		"UPDATE curriculum_sections SET title = $1, description = $2 WHERE id = $3 AND curriculum = $4",
+		section.Title, section.Description, section.ID, curriculumID))
+}
+
+func (s *service) DeleteCurriculumSection(curriculumID, sectionID int64) error {
+	return affectedOne(s.db.Exec("DELETE FROM curriculum_sections WHERE id = $1 AND curriculum = $2",
+		sectionID, curriculumID))
+}
+
+func (s *service) OrderCurriculumSections(curriculumID int64, order []int64) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		if err := lockCurriculum(tx, curriculumID); err != nil {
+			return err
+		}
+
+		ids, err := queryIDs(tx, "SELECT id FROM curriculum_sections WHERE curriculum = $1", curriculumID)
+		if err != nil {
+			return err
+		}
+		return reorder(tx, "curriculum_sections", ids, order)
+	})
+}
+
+// Curriculum Step Functions
+
+// sectionExists makes sure the section belongs to the curriculum
+func sectionExists(tx *sql.Tx, curriculumID, sectionID int64) error {
+	err := tx.QueryRow("SELECT id FROM curriculum_sections WHERE id = $1 AND curriculum = $2",
+		sectionID, curriculumID).Scan(&sectionID)
+	if err == sql.ErrNoRows {
+		return store.ErrNoResults
+	}
+	return err
+}
+
+func (s *service) AddCurriculumStep(curriculumID, sectionID int64, step store.Step) (id int64, err error) {
+	if err := store.ValidateStep(step); err != nil {
+		return id, err
+	}
+
+	err = s.withTx(func(tx *sql.Tx) error {
+		if err := lockCurriculum(tx, curriculumID); err != nil {
+			return err
+		}
+		if err := sectionExists(tx, curriculumID, sectionID); err != nil {
+			return err
+		}
+		if err := resourceExists(tx, step.Resource); err != nil {
+			return err
+		}
+
+		err := tx.QueryRow(`
+			INSERT INTO curriculum_steps (section, position, resource, note, estimatedMinutes, optional)
+			SELECT $1, COALESCE(MAX(position), -1) + 1, $2, $3, $4, $5 FROM curriculum_steps WHERE section = $1
+			RETURNING id`,
+			sectionID, step.Resource, step.Note, step.EstimatedMinutes, step.Optional).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("INSERT INTO events (type, actor, subject) SELECT $1, owner, id FROM curriculums WHERE id = $2",
+			store.EventCurriculumUpdated, curriculumID)
+		return err
+	})
+	return id, err
+}
+
+func (s *service) UpdateCurriculumStep(curriculumID, sectionID int64, step store.Step) error {
+	if err := store.ValidateStep(step); err != nil {
+		return err
+	}
+
+	return s.withTx(func(tx *sql.Tx) error {
+		if err := resourceExists(tx, step.Resource); err != nil {
+			return err
+		}
+
+		return affectedOne(tx.Exec(`
+			UPDATE curriculum_steps st SET resource = $1, note = $2, estimatedMinutes = $3, optional = $4
+			FROM curriculum_sections se
+			WHERE st.id = $5 AND st.section = $6 AND se.id = st.section AND se.curriculum = $7`,
+			step.Resource, step.Note, step.EstimatedMinutes, step.Optional, step.ID, sectionID, curriculumID))
+	})
+}
+
+func (s *service) DeleteCurriculumStep(curriculumID, sectionID, stepID int64) error {
+	return affectedOne(s.db.Exec(`
+		DELETE FROM curriculum_steps st USING curriculum_sections se
+		WHERE st.id = $1 AND st.section = $2 AND se.id = st.section AND se.curriculum = $3`,
+		stepID, sectionID, curriculumID))
+}
+
+func (s *service) OrderCurriculumSteps(curriculumID, sectionID int64, order []int64) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		if err := lockCurriculum(tx, curriculumID); err != nil {
+			return err
+		}
+		if err := sectionExists(tx, curriculumID, sectionID); err != nil {
+			return err
+		}
+
+		ids, err := queryIDs(tx, "SELECT id FROM curriculum_steps WHERE section = $1", sectionID)
+		if err != nil {
+			return err
+		}
+		return reorder(tx, "curriculum_steps", ids, order)
+	})
+}
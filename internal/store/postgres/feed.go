@@ -0,0 +1,121 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Follow Functions
+
+func (s *service) FollowUser(follower, user int64) (followed bool, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		err := tx.QueryRow("SELECT id FROM users WHERE id = $1", user).Scan(&user)
+		if err == sql.ErrNoRows {
+			return store.ErrNoResults
+		} else if err != nil {
+			return err
+		}
+
+		res, err := tx.Exec("INSERT INTO user_follows (follower, followee) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+			follower, user)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		followed = n == 1
+		return err
+	})
+	return followed && err == nil, err
+}
+
+func (s *service) UnfollowUser(follower, user int64) error {
+	return affectedOne(s.db.Exec("DELETE FROM user_follows WHERE follower = $1 AND followee = $2", follower, user))
+}
+
+func (s *service) FollowTag(follower int64, tag string) error {
+	tags, err := store.NormalizeTags([]string{tag})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec("INSERT INTO tag_follows (follower, tag) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		follower, tags[0])
+	return err
+}
+
+func (s *service) UnfollowTag(follower int64, tag string) error {
+	tags, err := store.NormalizeTags([]string{tag})
+	if err != nil {
+		return err
+	}
+
+	return affectedOne(s.db.Exec("DELETE FROM tag_follows WHERE follower = $1 AND tag = $2", follower, tags[0]))
+}
+
+func (s *service) GetFollows(follower int64) (follows store.Follows, err error) {
+	err = s.db.QueryRow(`
+		SELECT
+			ARRAY(SELECT followee FROM user_follows WHERE follower = $1 ORDER BY followee),
+			ARRAY(SELECT tag FROM tag_follows WHERE follower = $1 ORDER BY tag)`,
+		follower).Scan(pq.Array(&follows.Users), pq.Array(&follows.Tags))
+	return follows, err
+}
+
+// Feed Functions
+
+// record adds an event to the activity log
+func record(tx *sql.Tx, eventType string, actor, subject int64, tags []string) error {
+	if tags == nil {
+		tags = []string{}
+	}
+	_, err := tx.Exec("INSERT INTO events (type, actor, subject, tags) VALUES ($1, NULLIF($2, 0), $3, $4)",
+		eventType, actor, subject, pq.Array(tags))
+	return err
+}
+
+// eventLiveClause leaves out events about anything since deleted or hidden, the events
+// table is aliased e
+const eventLiveClause = `CASE e.type
+	WHEN '` + store.EventResourceApproved + `' THEN EXISTS (
+		SELECT 1 FROM resources r WHERE r.id = e.subject AND r.approved = true AND r.deleted = false)
+	WHEN '` + store.EventCollectionCreated + `' THEN EXISTS (
+		SELECT 1 FROM collections c WHERE c.id = e.subject AND c.visibility = '` + store.VisibilityPublic + `')
+	ELSE EXISTS (SELECT 1 FROM curriculums c WHERE c.id = e.subject)
+	END`
+
+// GetFeed fans out on read, the follows of the user are matched against the log when the
+// feed is requested
+func (s *service) GetFeed(user int64, query store.FeedQuery) ([]store.Event, error) {
+	b := &queryBuilder{}
+	follower := b.arg(user)
+	b.and(`(e.actor IN (SELECT followee FROM user_follows WHERE follower = ` + follower + `)
+		OR e.tags && ARRAY(SELECT tag FROM tag_follows WHERE follower = ` + follower + `))`)
+	if query.Cursor != 0 {
+		b.and("e.id < " + b.arg(query.Cursor))
+	}
+	b.and(eventLiveClause)
+
+	rows, err := s.db.Query(`
+		SELECT e.id, e.type, COALESCE(e.actor, 0), e.subject, e.tags, e.createdAt
+		FROM events e
+		WHERE `+b.whereClause()+`
+		ORDER BY e.id DESC
+		LIMIT `+b.arg(query.Limit), b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []store.Event{}
+	for rows.Next() {
+		var event store.Event
+		err := rows.Scan(&event.ID, &event.Type, &event.Actor, &event.Subject, pq.Array(&event.Tags), &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
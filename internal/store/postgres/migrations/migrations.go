@@ -0,0 +1,217 @@
+// Package migrations applies ordered, checksummed schema changes to a postgres
+// database and records them in the schema_migrations table
+package migrations
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// lockID is the advisory lock held while migrating so concurrent instances queue up
+// instead of racing each other, the number itself is arbitrary
+const lockID int64 = 7265110931
+
+const migrationsTableCreationQuery = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version		integer PRIMARY KEY,
+	name		varchar(256) NOT NULL,
+	checksum	varchar(64) NOT NULL,
+	appliedAt	timestamptz NOT NULL DEFAULT now()
+)`
+
+// Migration is a single schema change, Down must undo everything Up does
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Checksum fingerprints the SQL of the migration so edits to an applied migration are caught
+func (m Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Up + "\x00" + m.Down))
+	return hex.EncodeToString(sum[:])
+}
+
+// Status reports whether a migration has been applied to the database
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+	// Modified is set when the applied checksum doesn't match the migration anymore
+	Modified bool
+}
+
+// Migrator applies a set of migrations to a database
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New validates that migrations are in strictly increasing version order and returns a Migrator
+func New(db *sql.DB, migrations []Migration) (*Migrator, error) {
+	last := 0
+	for _, m := range migrations {
+		if m.Version <= last {
+			return nil, fmt.Errorf("migration %d %q is out of order", m.Version, m.Name)
+		}
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d %q is missing up or down sql", m.Version, m.Name)
+		}
+		last = m.Version
+	}
+
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+type applied struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+// Up applies every pending migration in order, all of them or none of them
+func (m *Migrator) Up() (done []Migration, err error) {
+	err = m.locked(func(tx *sql.Tx, history map[int]applied) error {
+		if err := m.verify(history); err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := history[migration.Version]; ok {
+				continue
+			}
+
+			if _, err := tx.Exec(migration.Up); err != nil {
+				return errors.Wrapf(err, "applying migration %d %s", migration.Version, migration.Name)
+			}
+
+			_, err := tx.Exec("INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)",
+				migration.Version, migration.Name, migration.Checksum())
+			if err != nil {
+				return errors.Wrapf(err, "recording migration %d", migration.Version)
+			}
+
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down rolls back the most recently applied migration, it returns false when there was nothing to roll back
+func (m *Migrator) Down() (migration Migration, ok bool, err error) {
+	err = m.locked(func(tx *sql.Tx, history map[int]applied) error {
+		if err := m.verify(history); err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			if _, found := history[m.migrations[i].Version]; found {
+				migration, ok = m.migrations[i], true
+				break
+			}
+		}
+		if !ok {
+			return nil
+		}
+
+		if _, err := tx.Exec(migration.Down); err != nil {
+			return errors.Wrapf(err, "rolling back migration %d %s", migration.Version, migration.Name)
+		}
+
+		_, err := tx.Exec("DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+		return errors.Wrapf(err, "removing migration %d", migration.Version)
+	})
+	return migration, ok, err
+}
+
+// Status lists every known migration and whether it has been applied
+func (m *Migrator) Status() (statuses []Status, err error) {
+	err = m.locked(func(tx *sql.Tx, history map[int]applied) error {
+		for _, migration := range m.migrations {
+			status := Status{Migration: migration}
+			if a, ok := history[migration.Version]; ok {
+				status.Applied = true
+				status.AppliedAt = a.appliedAt
+				status.Modified = a.checksum != migration.Checksum()
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// verify makes sure every applied migration is still known and unchanged
+func (m *Migrator) verify(history map[int]applied) error {
+	known := make(map[int]Migration, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+
+	for version, a := range history {
+		migration, ok := known[version]
+		if !ok {
+			return fmt.Errorf("database has migration %d applied which this build doesn't know about", version)
+		}
+		if a.checksum != migration.Checksum() {
+			return fmt.Errorf("migration %d %s was modified after it was applied", version, migration.Name)
+		}
+	}
+	return nil
+}
+
+// locked runs fn in a transaction holding the migration advisory lock, the lock is
+// released when the transaction commits or rolls back
+func (m *Migrator) locked(fn func(tx *sql.Tx, history map[int]applied) error) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "starting migration transaction")
+	}
+
+	if err := m.run(tx, fn); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return errors.Wrap(tx.Commit(), "committing migrations")
+}
+
+func (m *Migrator) run(tx *sql.Tx, fn func(tx *sql.Tx, history map[int]applied) error) error {
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", lockID); err != nil {
+		return errors.Wrap(err, "acquiring migration lock")
+	}
+
+	if _, err := tx.Exec(migrationsTableCreationQuery); err != nil {
+		return errors.Wrap(err, "creating schema_migrations table")
+	}
+
+	rows, err := tx.Query("SELECT version, checksum, appliedAt FROM schema_migrations")
+	if err != nil {
+		return errors.Wrap(err, "reading schema_migrations")
+	}
+	defer rows.Close()
+
+	history := make(map[int]applied)
+	for rows.Next() {
+		var (
+			version int
+			a       applied
+		)
+		if err := rows.Scan(&version, &a.checksum, &a.appliedAt); err != nil {
+			return err
+		}
+		history[version] = a
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	return fn(tx, history)
+}
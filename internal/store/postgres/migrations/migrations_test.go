@@ -0,0 +1,34 @@
+package migrations
+
+import "testing"
+
+func TestNewRejectsOutOfOrder(t *testing.T) {
+	_, err := New(nil, []Migration{
+		{Version: 2, Name: "second", Up: "SELECT 1", Down: "SELECT 1"},
+		{Version: 1, Name: "first", Up: "SELECT 1", Down: "SELECT 1"},
+	})
+	if err == nil {
+		t.Error("out of order migrations were accepted")
+	}
+
+	_, err = New(nil, []Migration{
+		{Version: 1, Name: "first", Up: "SELECT 1"},
+	})
+	if err == nil {
+		t.Error("migration without down sql was accepted")
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	m := Migration{Version: 1, Name: "first", Up: "CREATE TABLE a ()", Down: "DROP TABLE a"}
+
+	if m.Checksum() != m.Checksum() {
+		t.Error("checksum isn't stable")
+	}
+
+	changed := m
+	changed.Down = "DROP TABLE IF EXISTS a"
+	if m.Checksum() == changed.Checksum() {
+		t.Error("checksum didn't change with the sql")
+	}
+}
@@ -0,0 +1,69 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// GetPendingResources lists live resources that haven't been approved or rejected, oldest first
+func (s *service) GetPendingResources(page store.Page) ([]store.Resource, error) {
+	rows, err := s.db.Query(`
+		SELECT `+resourceColumns+`
+		FROM resources r
+		WHERE r.approved = false AND r.rejected = false AND r.deleted = false
+		ORDER BY r.id
+		LIMIT $1 OFFSET $2`, page.Limit, page.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanResources(rows)
+}
+
+func (s *service) ApproveResource(id, moderator int64) (bool, error) {
+	return s.moderate(id, moderator, true, "")
+}
+
+func (s *service) RejectResource(id, moderator int64, reason string) error {
+	_, err := s.moderate(id, moderator, false, reason)
+	return err
+}
+
+// moderate reports whether it changed the resource, approving an approved resource doesn't
+func (s *service) moderate(id, moderator int64, approved bool, reason string) (changed bool, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		// only the first approval makes the feed, edits send a resource back to be approved again
+		var isApproved, approvedBefore bool
+		err := tx.QueryRow(`
+			SELECT approved, approvedAt IS NOT NULL FROM resources
+			WHERE id = $1 AND deleted = false FOR UPDATE`, id).Scan(&isApproved, &approvedBefore)
+		if err == sql.ErrNoRows {
+			return store.ErrNoResults
+		} else if err != nil {
+			return err
+		}
+		if approved && isApproved {
+			return nil
+		}
+		changed = true
+
+		_, err = tx.Exec(`
+			UPDATE resources
+			SET approved = $1, rejected = NOT $1, rejectionReason = NULLIF($2, ''),
+				moderatedBy = NULLIF($3, 0), moderatedAt = now(),
+				approvedAt = CASE WHEN $1 THEN COALESCE(approvedAt, now()) ELSE approvedAt END
+			WHERE id = $4`, approved, reason, moderator, id)
+		if err != nil || !approved || approvedBefore {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO events (type, actor, subject, tags)
+			SELECT $1, r.submitter, r.id, `+tagsColumn+` FROM resources r WHERE r.id = $2`,
+			store.EventResourceApproved, id)
+		return err
+	})
+	return changed && err == nil, err
+}
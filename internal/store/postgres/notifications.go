@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"encoding/json"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Notification Functions
+
+func (s *service) CreateNotification(notification store.Notification) (id int64, err error) {
+	err = s.db.QueryRow(`
+		INSERT INTO notifications (recipient, type, actor, resource, comment, message)
+		VALUES ($1, $2, NULLIF($3, 0), NULLIF($4, 0), NULLIF($5, 0), $6) RETURNING id`,
+		notification.User, notification.Type, notification.Actor, notification.Resource,
+		notification.Comment, notification.Message).Scan(&id)
+	return id, err
+}
+
+// GetNotifications lists a user's notifications, newest first
+func (s *service) GetNotifications(user int64, query store.NotificationQuery) ([]store.Notification, error) {
+	rows, err := s.db.Query(`
+		SELECT id, type, COALESCE(actor, 0), COALESCE(resource, 0), COALESCE(comment, 0), message, read, createdAt
+		FROM notifications
+		WHERE recipient = $1 AND (read = false OR NOT $2)
+		ORDER BY id DESC
+		LIMIT $3 OFFSET $4`, user, query.UnreadOnly, query.Limit, query.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []store.Notification
+	for rows.Next() {
+		notification := store.Notification{User: user}
+		err := rows.Scan(&notification.ID, &notification.Type, &notification.Actor, &notification.Resource,
+			&notification.Comment, &notification.Message, &notification.Read, &notification.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+	return notifications, rows.Err()
+}
+
+func (s *service) UnreadNotifications(user int64) (unread int64, err error) {
+	err = s.db.QueryRow("SELECT count(*) FROM notifications WHERE recipient = $1 AND read = false", user).Scan(&unread)
+	return unread, err
+}
+
+func (s *service) MarkNotificationRead(user, id int64) error {
+	return affectedOne(s.db.Exec("UPDATE notifications SET read = true WHERE id = $1 AND recipient = $2", id, user))
+}
+
+func (s *service) MarkAllNotificationsRead(user int64) error {
+	_, err := s.db.Exec("UPDATE notifications SET read = true WHERE recipient = $1 AND read = false", user)
+	return err
+}
+
+func (s *service) SetNotificationPreferences(user int64, preferences store.NotificationPreferences) error {
+	if err := store.ValidateNotificationPreferences(preferences); err != nil {
+		return err
+	}
+	if preferences == nil {
+		preferences = store.NotificationPreferences{}
+	}
+
+	data, err := json.Marshal(preferences)
+	if err != nil {
+		return err
+	}
+	return affectedOne(s.db.Exec("UPDATE users SET notificationPreferences = $1 WHERE id = $2", data, user))
+}
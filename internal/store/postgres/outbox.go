@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"sort"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Outbox Functions
+
+func (s *service) QueueMail(mail store.OutboxMail) (id int64, err error) {
+	err = s.db.QueryRow(
+		"INSERT INTO outbox (recipient, subject, text, html) VALUES ($1, $2, $3, $4) RETURNING id",
+		mail.To, mail.Subject, mail.Text, mail.HTML).Scan(&id)
+	return id, err
+}
+
+// ClaimMail skips rows another worker has locked so concurrent workers never claim the same mail
+func (s *service) ClaimMail(limit int, lease time.Duration) ([]store.OutboxMail, error) {
+	rows, err := s.db.Query(`
+		UPDATE outbox SET attempts = attempts + 1, nextAttemptAt = now() + $2 * interval '1 microsecond'
+		WHERE id IN (
+			SELECT id FROM outbox WHERE nextAttemptAt <= now()
+			ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, recipient, subject, text, html, attempts, lastError, nextAttemptAt, createdAt`,
+		limit, int64(lease/time.Microsecond))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []store.OutboxMail
+	for rows.Next() {
+		var mail store.OutboxMail
+		err := rows.Scan(&mail.ID, &mail.To, &mail.Subject, &mail.Text, &mail.HTML, &mail.Attempts,
+			&mail.LastError, &mail.NextAttemptAt, &mail.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		due = append(due, mail)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING doesn't follow the order of the subquery
+	sort.Slice(due, func(i, j int) bool { return due[i].ID < due[j].ID })
+	return due, nil
+}
+
+func (s *service) DeleteMail(id int64) error {
+	return affectedOne(s.db.Exec("DELETE FROM outbox WHERE id = $1", id))
+}
+
+func (s *service) RetryMail(id int64, lastError string, retryAt time.Time) error {
+	var next *time.Time
+	if !retryAt.IsZero() {
+		next = &retryAt
+	}
+	return affectedOne(s.db.Exec("UPDATE outbox SET lastError = $1, nextAttemptAt = $2 WHERE id = $3",
+		lastError, next, id))
+}
@@ -2,13 +2,15 @@ package postgres
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/natethinks/instruu-api/internal/auth"
 	"github.com/natethinks/instruu-api/internal/store"
 
 	// for the postgres sql driver
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
 )
 
@@ -31,57 +33,32 @@ func (o Options) connectionInfo() string {
 		o.Host, o.Port, o.User, o.Pass, o.DBName, o.SSLMode)
 }
 
-const usersTableCreationQuery = `
-CREATE TABLE IF NOT EXISTS users (
-	id          SERIAL PRIMARY KEY,
-	username	varchar(256),
-	email		varchar(256),
-	firstName	varchar(256),
-	lastName	varchar(256),
-	password	varchar(256),
-	isVerified 	BOOLEAN NOT NULL DEFAULT FALSE
-)`
-
-const resourcesTableCreationQuery = `
-CREATE TABLE IF NOT EXISTS resources (
-	id          SERIAL PRIMARY KEY,
-	name		varchar(256),
-	description text,
-	url			varchar(256) UNIQUE,
-	approved	BOOLEAN NOT NULL DEFAULT FALSE,
-	submitter	integer references users(id),
-	deleted		BOOLEAN NOT NULL DEFAULT FALSE
-)`
-
-const tagsTableCreationQuery = `
-CREATE TABLE IF NOT EXISTS tags (
-	id			SERIAL PRIMARY KEY,
-	name		varchar(256) UNIQUE
-)`
-
-const tagTableCreationQuery = `
-CREATE TABLE IF NOT EXISTS tag (
-	id			SERIAL PRIMARY KEY,
-	resource	integer references resources(id) ON DELETE CASCADE,
-	tag			integer references tags(id) ON DELETE CASCADE,
-	CONSTRAINT  unq_res_tag UNIQUE(resource, tag)
-)`
-
-// New connects to a postgres server with specified options and returns a store.Service
-func New(options Options) (store.Service, error) {
+// Open connects to a postgres server with specified options without touching the schema
+func Open(options Options) (*sql.DB, error) {
 	db, err := sql.Open("postgres", options.connectionInfo())
 	if err != nil {
 		return nil, errors.Wrap(err, "connecting to postgres database")
 	}
+	return db, nil
+}
 
-	_, err = db.Exec(usersTableCreationQuery)
+// New connects to a postgres server with specified options, applies any pending
+// migrations and returns a store.Service
+func New(options Options) (store.Service, error) {
+	db, err := Open(options)
 	if err != nil {
-		return nil, errors.Wrap(err, "creating todos table")
+		return nil, err
 	}
 
-	_, err = db.Exec(resourcesTableCreationQuery)
+	migrator, err := Migrator(db)
 	if err != nil {
-		return nil, errors.Wrap(err, "creating resources table")
+		db.Close()
+		return nil, err
+	}
+
+	if _, err := migrator.Up(); err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "migrating database")
 	}
 
 	return &service{db: db}, nil
@@ -89,66 +66,81 @@ func New(options Options) (store.Service, error) {
 
 // Authentication Functions
 
-func (s *service) Auth(user store.User) (jwt string, err error) {
-	fmt.Println("auth running")
-	fmt.Println(user)
-	// auth needs to query and grab the users password hash, then send it to the verify
-	// password function in auth then it needs to generate a JWT and return it to the user
-	err = s.db.QueryRow("SELECT password FROM users WHERE username = $1", user.Username).Scan(&user.PasswordHash)
-	if err != nil {
-		fmt.Println("probably can't find that user")
-		return jwt, err
+func (s *service) Auth(user store.User) (store.User, error) {
+	var id int64
+	err := s.db.QueryRow("SELECT id, password FROM users WHERE username = $1", user.Username).Scan(&id, &user.PasswordHash)
+	if err == sql.ErrNoRows {
+		return store.User{}, store.ErrNoResults
+	} else if err != nil {
+		return store.User{}, err
 	}
 
-	authSuccess := auth.VerifyPassword(user.PasswordHash, []byte(user.Password))
-	if !authSuccess {
-		fmt.Println("password doesn't match")
-		err = errors.New("Incorrect Password")
-		return jwt, err
+	if !auth.VerifyPassword(user.PasswordHash, []byte(user.Password)) {
+		return store.User{}, errors.New("Incorrect Password")
 	}
 
-	fmt.Println(user)
-	return jwt, nil
+	return s.GetUser(id)
 }
 
 // User store functions
 func (s *service) CreateUser(user store.User) (id int64, err error) {
 	// generate password hash before storing
 	user.PasswordHash = auth.GeneratePasswordHash([]byte(user.Password))
-	fmt.Println(user)
 	err = s.db.QueryRow(
 		"INSERT INTO users (username, email, firstname, lastname, password) VALUES ($1, $2, $3, $4, $5) RETURNING id",
 		user.Username, user.Email, user.FirstName, user.LastName, user.PasswordHash).Scan(&id)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == uniqueViolation {
+		return 0, store.ErrUsernameExists
+	}
 	return id, err
 }
 
 func (s *service) GetUser(id int64) (user store.User, err error) {
-	fmt.Println("s.GetUser() called")
 	user = store.User{ID: id}
-	err = s.db.QueryRow("SELECT username, email FROM users WHERE id = $1", id).Scan(
-		&user.Username, &user.Email)
+	var preferences []byte
+	err = s.db.QueryRow(
+		"SELECT username, email, firstName, lastName, isVerified, roles, notificationPreferences FROM users WHERE id = $1", id).Scan(
+		&user.Username, &user.Email, &user.FirstName, &user.LastName, &user.Verified, pq.Array(&user.Roles), &preferences)
 	if err == sql.ErrNoRows {
-		err = store.ErrNoResults
+		return user, store.ErrNoResults
+	} else if err != nil {
+		return user, err
 	}
+
+	err = json.Unmarshal(preferences, &user.Notifications)
 	return user, err
 }
 
-func (s *service) PatchUser(user store.User) (err error) {
-	fmt.Println("s.PatchUser() called")
-	fmt.Println(user)
-	return nil
+// PatchUser only changes the fields that are set, like the other stores
+func (s *service) PatchUser(user store.User) error {
+	var passwordHash string
+	if user.Password != "" {
+		passwordHash = auth.GeneratePasswordHash([]byte(user.Password))
+	}
+
+	res, err := s.db.Exec(`
+		UPDATE users SET
+			username = COALESCE(NULLIF($2, ''), username),
+			email = COALESCE(NULLIF($3, ''), email),
+			firstName = COALESCE(NULLIF($4, ''), firstName),
+			lastName = COALESCE(NULLIF($5, ''), lastName),
+			password = COALESCE(NULLIF($6, ''), password)
+		WHERE id = $1`,
+		user.ID, user.Username, user.Email, user.FirstName, user.LastName, passwordHash)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == uniqueViolation {
+		return errors.New("Username exists, cannot update")
+	}
+	return affectedOne(res, err)
 }
 
-func (s *service) DeleteUser(id int64) (err error) {
-	fmt.Println("s.DeleteUser() called")
-	fmt.Println(id)
-	return nil
+// DeleteUser leaves the foreign keys to clean up, what the user owns goes with them and
+// what they wrote for everyone else stays without them
+func (s *service) DeleteUser(id int64) error {
+	return affectedOne(s.db.Exec("DELETE FROM users WHERE id = $1", id))
 }
 
 func (s *service) GetUsers() (users []store.User, err error) {
-	fmt.Println("s.GetUsers() called")
-
-	rows, err := s.db.Query("SELECT id, username, email, firstname, lastname, isVerified FROM users")
+	rows, err := s.db.Query("SELECT id, username, email, firstname, lastname, isVerified, roles FROM users")
 	if err != nil && err != sql.ErrNoRows {
 		return nil, err
 	} else if err == sql.ErrNoRows {
@@ -158,7 +150,7 @@ func (s *service) GetUsers() (users []store.User, err error) {
 
 	for rows.Next() {
 		var user store.User
-		if err = rows.Scan(&user.ID, &user.Username, &user.Email, &user.FirstName, &user.LastName, &user.Verified); err != nil {
+		if err = rows.Scan(&user.ID, &user.Username, &user.Email, &user.FirstName, &user.LastName, &user.Verified, pq.Array(&user.Roles)); err != nil {
 			return users, err
 		}
 		users = append(users, user)
@@ -167,82 +159,168 @@ func (s *service) GetUsers() (users []store.User, err error) {
 }
 
 func (s *service) CheckUsername(user store.User) error {
-	fmt.Println(user.Username)
 	var id int
 	err := s.db.QueryRow("SELECT id FROM users WHERE username = $1", user.Username).Scan(&id)
 	if err == sql.ErrNoRows {
 		return nil
 	}
 
-	err = errors.New("Username exists, cannot create")
-	return err
+	return store.ErrUsernameExists
+}
+
+// SetRole updates the array in one statement so concurrent grants don't lose each other
+func (s *service) SetRole(id int64, role string, granted bool) error {
+	if granted {
+		return affectedOne(s.db.Exec(`
+			UPDATE users SET roles = array(SELECT DISTINCT unnest(array_append(roles, $1::varchar)) ORDER BY 1)
+			WHERE id = $2`, role, id))
+	}
+	return affectedOne(s.db.Exec("UPDATE users SET roles = array_remove(roles, $1::varchar) WHERE id = $2", role, id))
+}
+
+func (s *service) VerifyUser(id int64) error {
+	return affectedOne(s.db.Exec("UPDATE users SET isVerified = true WHERE id = $1", id))
+}
+
+// ClaimVerificationSend checks and records the send in one statement so concurrent
+// requests can't both claim it
+func (s *service) ClaimVerificationSend(id int64, interval time.Duration) (bool, error) {
+	res, err := s.db.Exec(`
+		UPDATE users SET verificationSentAt = now()
+		WHERE id = $1 AND (verificationSentAt IS NULL OR verificationSentAt <= now() - $2 * interval '1 microsecond')`,
+		id, int64(interval/time.Microsecond))
+	if err = affectedOne(res, err); err != store.ErrNoResults {
+		return err == nil, err
+	}
+
+	// nothing was updated, either the user doesn't exist or the claim is too soon
+	err = s.db.QueryRow("SELECT id FROM users WHERE id = $1", id).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, store.ErrNoResults
+	}
+	return false, err
+}
+
+func (s *service) GetUserByEmail(email string) (store.User, error) {
+	var id int64
+	err := s.db.QueryRow(
+		"SELECT id FROM users WHERE email <> '' AND lower(email) = lower($1) ORDER BY id LIMIT 1", email).Scan(&id)
+	if err == sql.ErrNoRows {
+		return store.User{}, store.ErrNoResults
+	} else if err != nil {
+		return store.User{}, err
+	}
+	return s.GetUser(id)
 }
 
 func (s *service) Close() error {
 	return s.db.Close()
 }
 
+// affectedOne turns an update or delete that matched no rows into store.ErrNoResults
+func affectedOne(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return store.ErrNoResults
+	}
+	return nil
+}
+
+// withTx runs fn in a transaction, committing when it returns nil and rolling back otherwise
+func (s *service) withTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
 // Resource Functions
 
 func (s *service) CreateResource(resource store.Resource) (id int64, err error) {
-	fmt.Println(resource)
-	err = s.db.QueryRow(
-		"INSERT INTO resources (name, description, url, submitter) VALUES ($1, $2, $3, $4) RETURNING id",
-		resource.ID, resource.Description, resource.URL, resource.Submitter).Scan(&id)
-	return id, err
+	tags, err := store.NormalizeTags(resource.Tags)
+	if err != nil {
+		return id, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return id, err
+	}
+
+	createdAt := time.Now()
+	err = tx.QueryRow(`
+		INSERT INTO resources (name, description, url, submitter, createdAt, hot)
+		VALUES ($1, $2, $3, NULLIF($4, 0), $5, $6) RETURNING id`,
+		resource.Name, resource.Description, resource.URL, resource.Submitter,
+		createdAt, store.HotScore(0, createdAt)).Scan(&id)
+	if err != nil {
+		tx.Rollback()
+		return id, err
+	}
+
+	// the submitted content is the first revision
+	if err = recordRevision(tx, id, resource.Submitter, store.Diff(store.Resource{}, resource)); err != nil {
+		tx.Rollback()
+		return id, err
+	}
+
+	if err = addTags(tx, id, tags); err != nil {
+		tx.Rollback()
+		return id, err
+	}
+
+	return id, tx.Commit()
 }
 
 func (s *service) GetResource(id int64) (resource store.Resource, err error) {
-
 	resource = store.Resource{ID: id}
-	err = s.db.QueryRow("SELECT id, name, description, url, submitter, approved, submitter FROM resources WHERE id = $1 AND deleted = false", id).Scan(&resource.Name, &resource.Description, &resource.URL, &resource.Submitter)
+	err = s.db.QueryRow(`
+		SELECT `+resourceColumns+`
+		FROM resources r
+		WHERE r.id = $1 AND r.deleted = false`, id).Scan(resourceFields(&resource)...)
 	if err == sql.ErrNoRows {
 		err = store.ErrNoResults
 	}
 	return resource, err
 }
 
-// GetResources might better encompass groups as well with a query param
-func (s *service) GetResources(query map[string][]string) (resources []store.Resource, err error) {
-
-	// need to do some query builder stuff and check the query params
-	fmt.Println(query)
+func (s *service) GetResources(query store.ResourceQuery) ([]store.Resource, error) {
+	stmt, args := resourceQuery(query)
 
-	rows, err := s.db.Query("SELECT id, name, description, url FROM resources")
-	if err != nil && err != sql.ErrNoRows {
+	rows, err := s.db.Query(stmt, args...)
+	if err != nil {
 		return nil, err
-	} else if err == sql.ErrNoRows {
-		err = store.ErrNoResults
 	}
 	defer rows.Close()
 
+	return scanResources(rows)
+}
+
+// scanResources reads rows selected with resourceColumns
+func scanResources(rows *sql.Rows) (resources []store.Resource, err error) {
 	for rows.Next() {
 		var resource store.Resource
-		if err = rows.Scan(&resource.ID, &resource.Name, &resource.Description, &resource.URL); err != nil {
+		if err = rows.Scan(resourceFields(&resource)...); err != nil {
 			return resources, err
 		}
 		resources = append(resources, resource)
 	}
-	return
-}
-
-func (s *service) UpdateResource(resource store.Resource) (err error) {
-	// name, description, and URL are all game to be updated. maybe not url?
-	// but since i'm getting an entire resource passed to me I might as well run the
-	// whole update
-	fmt.Println(resource)
-	res, err := s.db.Exec("UPDATE resources SET (name, description, url, approved, deleted) VALUES ($1, $2, $3, $4, $5)", resource.Name, resource.Description, resource.URL, resource.Approved, resource.Deleted)
-	fmt.Println(res)
-	if err != nil {
-		return err
-	}
-
-	// Need to add something to track history of changes here, the original submitter should stay the same, but on this patch request the submitter value will be the editor, and that will be stored in it's own table.
-
-	return err
+	return resources, rows.Err()
 }
 
-func (s *service) DeleteResource(ID int64) (err error) {
-
-	return err
+// DeleteResource only flags the resource as deleted, it is never removed
+func (s *service) DeleteResource(id int64) error {
+	return affectedOne(s.db.Exec("UPDATE resources SET deleted = true WHERE id = $1 AND deleted = false", id))
 }
@@ -1 +1,97 @@
 package postgres
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/natethinks/instruu-api/internal/store"
+	"github.com/natethinks/instruu-api/internal/store/storetest"
+)
+
+// newTestStore connects to the database named by POSTGRES_TEST_DB_NAME with the usual
+// POSTGRES_* settings, skipping the test when it isn't set
+func newTestStore(t *testing.T) store.Service {
+	name := os.Getenv("POSTGRES_TEST_DB_NAME")
+	if name == "" {
+		t.Skip("POSTGRES_TEST_DB_NAME isn't set")
+	}
+	port, _ := strconv.Atoi(os.Getenv("POSTGRES_PORT"))
+
+	sto, err := New(Options{
+		User:    os.Getenv("POSTGRES_USER"),
+		Pass:    os.Getenv("POSTGRES_PASS"),
+		Host:    os.Getenv("POSTGRES_HOST"),
+		Port:    port,
+		DBName:  name,
+		SSLMode: os.Getenv("POSTGRES_SSL_MODE"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sto
+}
+
+func TestSchemaIsValid(t *testing.T) {
+	if _, err := Migrator(nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestResourceQueryUsesPlaceholders(t *testing.T) {
+	approved := true
+	stmt, args := resourceQuery(store.ResourceQuery{
+		Tags:     []string{"go"},
+		Approved: &approved,
+		Prefix:   "50%_off'; DROP TABLE resources; --",
+		Sort:     store.SortName,
+		Limit:    20,
+		Cursor:   3,
+	})
+
+	if strings.Contains(stmt, "DROP TABLE") {
+		t.Errorf("prefix was formatted into the sql: %s", stmt)
+	}
+	if len(args) != 5 || !strings.Contains(stmt, "$5") || strings.Contains(stmt, "$6") {
+		t.Errorf("placeholders don't line up with %d args: %s", len(args), stmt)
+	}
+	if args[2] != `50\%\_off'; DROP TABLE resources; --%` {
+		t.Errorf("prefix wasn't escaped: %v", args[2])
+	}
+}
+
+func TestStoreTags(t *testing.T) {
+	sto := newTestStore(t)
+	defer sto.Close()
+
+	storetest.Tags(t, sto)
+}
+
+func TestStoreDeleteUser(t *testing.T) {
+	sto := newTestStore(t)
+	defer sto.Close()
+
+	storetest.DeleteUser(t, sto)
+}
+
+func TestStoreUsernames(t *testing.T) {
+	sto := newTestStore(t)
+	defer sto.Close()
+
+	storetest.Usernames(t, sto)
+}
+
+func TestStoreExternalUser(t *testing.T) {
+	sto := newTestStore(t)
+	defer sto.Close()
+
+	storetest.ExternalUser(t, sto)
+}
+
+func TestStoreModeration(t *testing.T) {
+	sto := newTestStore(t)
+	defer sto.Close()
+
+	storetest.Moderation(t, sto)
+}
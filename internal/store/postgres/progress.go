@@ -0,0 +1,57 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Progress Functions
+
+func (s *service) SetStepProgress(learner, curriculumID, stepID int64, status string) error {
+	if err := store.ValidateProgressStatus(status); err != nil {
+		return err
+	}
+
+	return s.withTx(func(tx *sql.Tx) error {
+		err := tx.QueryRow(`
+			SELECT st.id FROM curriculum_steps st JOIN curriculum_sections se ON se.id = st.section
+			WHERE st.id = $1 AND se.curriculum = $2`, stepID, curriculumID).Scan(&stepID)
+		if err == sql.ErrNoRows {
+			return store.ErrNoResults
+		} else if err != nil {
+			return err
+		}
+
+		// same rules as store.MarkProgress, the first start and completion are kept
+		_, err = tx.Exec(`
+			INSERT INTO curriculum_progress (learner, curriculum, step, status, completedAt)
+			VALUES ($1, $2, $3, $4::text, CASE WHEN $4::text = 'completed' THEN now() END)
+			ON CONFLICT (learner, step) DO UPDATE SET
+				status = EXCLUDED.status,
+				completedAt = CASE WHEN EXCLUDED.status = 'completed'
+					THEN COALESCE(curriculum_progress.completedAt, now()) END`,
+			learner, curriculumID, stepID, status)
+		return err
+	})
+}
+
+func (s *service) GetStepProgress(learner, curriculumID int64) (progress []store.StepProgress, err error) {
+	rows, err := s.db.Query(`
+		SELECT curriculum, step, status, startedAt, completedAt FROM curriculum_progress
+		WHERE learner = $1 AND ($2 = 0 OR curriculum = $2)
+		ORDER BY step`, learner, curriculumID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p := store.StepProgress{Learner: learner}
+		if err = rows.Scan(&p.Curriculum, &p.Step, &p.Status, &p.StartedAt, &p.CompletedAt); err != nil {
+			return progress, err
+		}
+		progress = append(progress, p)
+	}
+	return progress, rows.Err()
+}
@@ -0,0 +1,115 @@
+package postgres
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// resourceColumns is the select list resourceFields scans into, the resources table is aliased r
+const resourceColumns = `r.id, r.name, r.description, r.url, r.approved, COALESCE(r.submitter, 0), ` +
+	`r.rejected, COALESCE(r.rejectionReason, ''), COALESCE(r.moderatedBy, 0), r.moderatedAt, r.approvedAt, ` +
+	`r.rating, r.ratingCount, ` +
+	`r.score, r.hot, r.createdAt, r.bookmarkCount, ` + tagsColumn
+
+// resourceFields returns the scan destinations for resourceColumns
+func resourceFields(resource *store.Resource) []interface{} {
+	return []interface{}{
+		&resource.ID, &resource.Name, &resource.Description, &resource.URL, &resource.Approved, &resource.Submitter,
+		&resource.Rejected, &resource.RejectionReason, &resource.ModeratedBy, &resource.ModeratedAt, &resource.ApprovedAt,
+		&resource.Rating, &resource.RatingCount,
+		&resource.Score, &resource.Hot, &resource.CreatedAt, &resource.BookmarkCount, pq.Array(&resource.Tags),
+	}
+}
+
+// popularityColumn mirrors store.Popularity
+const popularityColumn = `(r.score + r.bookmarkCount)`
+
+// queryBuilder collects where clauses, every user supplied value goes through arg so
+// nothing from a request is ever formatted into the sql
+type queryBuilder struct {
+	where []string
+	args  []interface{}
+}
+
+// arg adds a value and returns its placeholder
+func (b *queryBuilder) arg(value interface{}) string {
+	b.args = append(b.args, value)
+	return "$" + strconv.Itoa(len(b.args))
+}
+
+func (b *queryBuilder) and(clause string) {
+	b.where = append(b.where, clause)
+}
+
+func (b *queryBuilder) whereClause() string {
+	if len(b.where) == 0 {
+		return "true"
+	}
+	return strings.Join(b.where, " AND ")
+}
+
+// escapeLike escapes the LIKE wildcards so a prefix is matched literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// resourceQuery builds the sql and arguments answering a store.ResourceQuery
+func resourceQuery(query store.ResourceQuery) (string, []interface{}) {
+	b := &queryBuilder{}
+	b.and("r.deleted = false")
+
+	if len(query.Tags) > 0 {
+		b.and(b.arg(pq.Array(query.Tags)) + "::varchar[] <@ " + tagsColumn)
+	}
+	if query.Submitter != 0 {
+		b.and("r.submitter = " + b.arg(query.Submitter))
+	}
+	if query.Approved != nil {
+		b.and("r.approved = " + b.arg(*query.Approved))
+	}
+	if query.Prefix != "" {
+		b.and("r.name ILIKE " + b.arg(escapeLike(query.Prefix)+"%"))
+	}
+
+	var orderBy string
+	switch query.Sort {
+	case store.SortName:
+		orderBy = "r.name, r.id"
+		if query.Cursor != 0 {
+			b.and("(r.name, r.id) > (SELECT r.name, r.id FROM resources r WHERE r.id = " + b.arg(query.Cursor) + ")")
+		}
+	case store.SortPopularity:
+		orderBy = popularityColumn + " DESC, r.id DESC"
+		if query.Cursor != 0 {
+			b.and("(" + popularityColumn + ", r.id) < (SELECT " + popularityColumn + ", r.id FROM resources r WHERE r.id = " + b.arg(query.Cursor) + ")")
+		}
+	case store.SortHot:
+		orderBy = "r.hot DESC, r.id DESC"
+		if query.Cursor != 0 {
+			b.and("(r.hot, r.id) < (SELECT r.hot, r.id FROM resources r WHERE r.id = " + b.arg(query.Cursor) + ")")
+		}
+	case store.SortRating:
+		orderBy = "r.rating DESC, r.ratingCount DESC, r.id DESC"
+		if query.Cursor != 0 {
+			b.and("(r.rating, r.ratingCount, r.id) < (SELECT r.rating, r.ratingCount, r.id FROM resources r WHERE r.id = " + b.arg(query.Cursor) + ")")
+		}
+	default:
+		orderBy = "r.id DESC"
+		if query.Cursor != 0 {
+			b.and("r.id < " + b.arg(query.Cursor))
+		}
+	}
+
+	stmt := "SELECT " + resourceColumns + " FROM resources r WHERE " + b.whereClause() + " ORDER BY " + orderBy
+	if query.Limit > 0 {
+		stmt += " LIMIT " + b.arg(query.Limit)
+	}
+	if query.Offset > 0 {
+		stmt += " OFFSET " + b.arg(query.Offset)
+	}
+
+	return stmt, b.args
+}
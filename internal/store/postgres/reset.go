@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Password Reset Functions
+
+func (s *service) CreatePasswordReset(reset store.PasswordReset) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM password_resets WHERE expiresAt < now()"); err != nil {
+			return err
+		}
+
+		// selecting the owner turns a missing user into no rows instead of a key violation
+		return affectedOne(tx.Exec(
+			"INSERT INTO password_resets (tokenHash, owner, expiresAt) SELECT $1, id, $2 FROM users WHERE id = $3",
+			reset.TokenHash, reset.ExpiresAt, reset.User))
+	})
+}
+
+// ResetPassword deletes the reset before anything else so two requests with the same
+// token can't both use it
+func (s *service) ResetPassword(tokenHash, passwordHash string) (id int64, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		err := tx.QueryRow(
+			"DELETE FROM password_resets WHERE tokenHash = $1 AND expiresAt >= now() RETURNING owner",
+			tokenHash).Scan(&id)
+		if err == sql.ErrNoRows {
+			return store.ErrNoResults
+		} else if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("DELETE FROM password_resets WHERE owner = $1", id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE sessions SET revokedAt = now() WHERE owner = $1 AND revokedAt IS NULL", id); err != nil {
+			return err
+		}
+
+		return affectedOne(tx.Exec(
+			"UPDATE users SET password = $1, sessionsRevokedAt = now() WHERE id = $2", passwordHash, id))
+	})
+	return id, err
+}
+
+func (s *service) SessionsRevokedAt(user int64) (time.Time, error) {
+	var revoked *time.Time
+	err := s.db.QueryRow("SELECT sessionsRevokedAt FROM users WHERE id = $1", user).Scan(&revoked)
+	if err == sql.ErrNoRows {
+		return time.Time{}, store.ErrNoResults
+	} else if err != nil || revoked == nil {
+		return time.Time{}, err
+	}
+	return *revoked, nil
+}
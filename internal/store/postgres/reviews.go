@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Review Functions
+
+// updateRating recomputes the average rating materialised on a resource
+func updateRating(tx *sql.Tx, resourceID int64) error {
+	_, err := tx.Exec(`
+		UPDATE resources SET (rating, ratingCount) = (
+			SELECT COALESCE(AVG(rating), 0), COUNT(*) FROM reviews WHERE resource = $1
+		) WHERE id = $1`, resourceID)
+	return err
+}
+
+func (s *service) SetReview(review store.Review) error {
+	if err := store.ValidateReview(review); err != nil {
+		return err
+	}
+
+	return s.withTx(func(tx *sql.Tx) error {
+		if err := resourceExists(tx, review.Resource); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(`
+			INSERT INTO reviews (resource, reviewer, rating, text) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (resource, reviewer) DO UPDATE SET
+				rating = EXCLUDED.rating, text = EXCLUDED.text, updatedAt = now()`,
+			review.Resource, review.Reviewer, review.Rating, review.Text)
+		if err != nil {
+			return err
+		}
+		return updateRating(tx, review.Resource)
+	})
+}
+
+func (s *service) GetReview(resourceID, reviewer int64) (review store.Review, err error) {
+	review = store.Review{Resource: resourceID, Reviewer: reviewer}
+	err = s.db.QueryRow(`
+		SELECT rating, text, createdAt, updatedAt FROM reviews
+		WHERE resource = $1 AND reviewer = $2`, resourceID, reviewer).Scan(
+		&review.Rating, &review.Text, &review.CreatedAt, &review.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return review, store.ErrNoResults
+	}
+	return review, err
+}
+
+// GetReviews lists the reviews of a resource, most recently updated first
+func (s *service) GetReviews(resourceID int64, page store.Page) (reviews []store.Review, err error) {
+	rows, err := s.db.Query(`
+		SELECT reviewer, rating, text, createdAt, updatedAt FROM reviews
+		WHERE resource = $1
+		ORDER BY updatedAt DESC, reviewer
+		LIMIT $2 OFFSET $3`, resourceID, page.Limit, page.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		review := store.Review{Resource: resourceID}
+		if err = rows.Scan(&review.Reviewer, &review.Rating, &review.Text, &review.CreatedAt, &review.UpdatedAt); err != nil {
+			return reviews, err
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews, rows.Err()
+}
+
+func (s *service) DeleteReview(resourceID, reviewer int64) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		err := affectedOne(tx.Exec("DELETE FROM reviews WHERE resource = $1 AND reviewer = $2", resourceID, reviewer))
+		if err != nil {
+			return err
+		}
+		return updateRating(tx, resourceID)
+	})
+}
@@ -0,0 +1,130 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// lockContent selects the editable content of a live resource and locks its row until the
+// transaction ends, which also serialises revision numbers for the resource
+func lockContent(tx *sql.Tx, id int64) (resource store.Resource, err error) {
+	resource = store.Resource{ID: id}
+	err = tx.QueryRow(
+		"SELECT name, description, url FROM resources WHERE id = $1 AND deleted = false FOR UPDATE", id).Scan(
+		&resource.Name, &resource.Description, &resource.URL)
+	if err == sql.ErrNoRows {
+		err = store.ErrNoResults
+	}
+	return resource, err
+}
+
+// recordRevision stores changes as the next revision of the resource, the resource row has
+// to be locked or freshly inserted by tx
+func recordRevision(tx *sql.Tx, resourceID, editor int64, changes map[string]store.Change) error {
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO resource_revisions (resource, revision, editor, changes)
+		SELECT $1, COALESCE(MAX(revision), 0) + 1, NULLIF($2, 0), $3
+		FROM resource_revisions WHERE resource = $1`, resourceID, editor, data)
+	return err
+}
+
+// updateContent writes the editable fields of content and records what changed, the
+// resource goes back to the moderation queue like store.WithContent
+func updateContent(tx *sql.Tx, current, content store.Resource, editor int64) error {
+	changes := store.Diff(current, content)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	_, err := tx.Exec(`
+		UPDATE resources SET name = $1, description = $2, url = $3,
+			approved = false, rejected = false, rejectionReason = NULL
+		WHERE id = $4`,
+		content.Name, content.Description, content.URL, current.ID)
+	if err != nil {
+		return err
+	}
+
+	return recordRevision(tx, current.ID, editor, changes)
+}
+
+// UpdateResource edits the name, description and url of a resource as editor, the original
+// submitter always stays the same and every change is kept in resource_revisions
+func (s *service) UpdateResource(resource store.Resource, editor int64) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		current, err := lockContent(tx, resource.ID)
+		if err != nil {
+			return err
+		}
+
+		return updateContent(tx, current, resource, editor)
+	})
+}
+
+func (s *service) GetRevisions(resourceID int64) ([]store.Revision, error) {
+	var revisions []store.Revision
+	err := s.withTx(func(tx *sql.Tx) (err error) {
+		if err := resourceExists(tx, resourceID); err != nil {
+			return err
+		}
+
+		revisions, err = loadRevisions(tx, resourceID)
+		return err
+	})
+	return revisions, err
+}
+
+func loadRevisions(tx *sql.Tx, resourceID int64) (revisions []store.Revision, err error) {
+	rows, err := tx.Query(`
+		SELECT revision, resource, COALESCE(editor, 0), createdAt, changes
+		FROM resource_revisions WHERE resource = $1 ORDER BY revision`, resourceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			revision store.Revision
+			changes  []byte
+		)
+		if err = rows.Scan(&revision.Revision, &revision.Resource, &revision.Editor, &revision.CreatedAt, &changes); err != nil {
+			return revisions, err
+		}
+		if err = json.Unmarshal(changes, &revision.Changes); err != nil {
+			return revisions, err
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+// RevertResource restores the content a resource had at revision, the revert is recorded as
+// a new revision so it can be undone as well
+func (s *service) RevertResource(resourceID, revision, editor int64) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		current, err := lockContent(tx, resourceID)
+		if err != nil {
+			return err
+		}
+
+		history, err := loadRevisions(tx, resourceID)
+		if err != nil {
+			return err
+		}
+
+		content, err := store.Replay(history, revision)
+		if err != nil {
+			return err
+		}
+
+		return updateContent(tx, current, content, editor)
+	})
+}
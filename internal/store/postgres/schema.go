@@ -0,0 +1,481 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/natethinks/instruu-api/internal/store/postgres/migrations"
+)
+
+// schema is every migration of the postgres store in order, once a migration has
+// shipped it must never be edited, add a new one instead
+var schema = []migrations.Migration{
+	{
+		Version: 1,
+		Name:    "create users",
+		Up: `
+CREATE TABLE IF NOT EXISTS users (
+	id          SERIAL PRIMARY KEY,
+	username	varchar(256),
+	email		varchar(256),
+	firstName	varchar(256),
+	lastName	varchar(256),
+	password	varchar(256),
+	isVerified 	BOOLEAN NOT NULL DEFAULT FALSE
+)`,
+		Down: `DROP TABLE IF EXISTS users`,
+	},
+	{
+		Version: 2,
+		Name:    "create resources",
+		Up: `
+CREATE TABLE IF NOT EXISTS resources (
+	id          SERIAL PRIMARY KEY,
+	name		varchar(256),
+	description text,
+	url			varchar(256) UNIQUE,
+	approved	BOOLEAN NOT NULL DEFAULT FALSE,
+	submitter	integer references users(id),
+	deleted		BOOLEAN NOT NULL DEFAULT FALSE
+)`,
+		Down: `DROP TABLE IF EXISTS resources`,
+	},
+	{
+		Version: 3,
+		Name:    "create tags",
+		Up: `
+CREATE TABLE IF NOT EXISTS tags (
+	id			SERIAL PRIMARY KEY,
+	name		varchar(256) UNIQUE
+)`,
+		Down: `DROP TABLE IF EXISTS tags`,
+	},
+	{
+		Version: 4,
+		Name:    "create tag",
+		Up: `
+CREATE TABLE IF NOT EXISTS tag (
+	id			SERIAL PRIMARY KEY,
+	resource	integer references resources(id) ON DELETE CASCADE,
+	tag			integer references tags(id) ON DELETE CASCADE,
+	CONSTRAINT  unq_res_tag UNIQUE(resource, tag)
+)`,
+		Down: `DROP TABLE IF EXISTS tag`,
+	},
+	{
+		Version: 5,
+		Name:    "add resources search vector",
+		// the name is weighted above the description when ranking search results
+		Up: `
+ALTER TABLE resources ADD COLUMN search tsvector GENERATED ALWAYS AS (
+	setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+	setweight(to_tsvector('english', coalesce(description, '')), 'B')
+) STORED;
+CREATE INDEX resources_search_idx ON resources USING GIN (search)`,
+		Down: `
+DROP INDEX IF EXISTS resources_search_idx;
+ALTER TABLE resources DROP COLUMN IF EXISTS search`,
+	},
+	{
+		Version: 6,
+		Name:    "create resource revisions",
+		// existing resources get their current content as revision 1 so history can be replayed
+		Up: `
+CREATE TABLE resource_revisions (
+	id			SERIAL PRIMARY KEY,
+	resource	integer NOT NULL references resources(id) ON DELETE CASCADE,
+	revision	integer NOT NULL,
+	editor		integer references users(id) ON DELETE SET NULL,
+	createdAt	timestamptz NOT NULL DEFAULT now(),
+	changes		jsonb NOT NULL,
+	CONSTRAINT  unq_res_revision UNIQUE(resource, revision)
+);
+INSERT INTO resource_revisions (resource, revision, editor, changes)
+SELECT id, 1, submitter, jsonb_strip_nulls(jsonb_build_object(
+	'name', CASE WHEN coalesce(name, '') <> '' THEN jsonb_build_object('from', '', 'to', name) END,
+	'description', CASE WHEN coalesce(description, '') <> '' THEN jsonb_build_object('from', '', 'to', description) END,
+	'url', CASE WHEN coalesce(url, '') <> '' THEN jsonb_build_object('from', '', 'to', url) END))
+FROM resources`,
+		Down: `DROP TABLE IF EXISTS resource_revisions`,
+	},
+	{
+		Version: 7,
+		Name:    "add moderation",
+		Up: `
+ALTER TABLE users ADD COLUMN isModerator BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE resources
+	ADD COLUMN rejected			BOOLEAN NOT NULL DEFAULT FALSE,
+	ADD COLUMN rejectionReason	text,
+	ADD COLUMN moderatedBy		integer references users(id) ON DELETE SET NULL,
+	ADD COLUMN moderatedAt		timestamptz;
+CREATE INDEX resources_pending_idx ON resources (id) WHERE approved = false AND rejected = false AND deleted = false`,
+		Down: `
+DROP INDEX IF EXISTS resources_pending_idx;
+ALTER TABLE resources
+	DROP COLUMN IF EXISTS rejected,
+	DROP COLUMN IF EXISTS rejectionReason,
+	DROP COLUMN IF EXISTS moderatedBy,
+	DROP COLUMN IF EXISTS moderatedAt;
+ALTER TABLE users DROP COLUMN IF EXISTS isModerator`,
+	},
+	{
+		Version: 8,
+		Name:    "create collections",
+		Up: `
+CREATE TABLE collections (
+	id			SERIAL PRIMARY KEY,
+	owner		integer NOT NULL references users(id) ON DELETE CASCADE,
+	title		varchar(256) NOT NULL,
+	description	text NOT NULL DEFAULT '',
+	visibility	varchar(16) NOT NULL DEFAULT 'private' CHECK (visibility IN ('public', 'unlisted', 'private')),
+	createdAt	timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX collections_owner_idx ON collections (owner);
+CREATE TABLE collection_items (
+	collection	integer NOT NULL references collections(id) ON DELETE CASCADE,
+	resource	integer NOT NULL references resources(id) ON DELETE CASCADE,
+	position	integer NOT NULL,
+	note		text NOT NULL DEFAULT '',
+	PRIMARY KEY (collection, resource)
+)`,
+		Down: `
+DROP TABLE IF EXISTS collection_items;
+DROP TABLE IF EXISTS collections`,
+	},
+	{
+		Version: 9,
+		Name:    "create curriculums",
+		Up: `
+CREATE TABLE curriculums (
+	id			SERIAL PRIMARY KEY,
+	owner		integer NOT NULL references users(id) ON DELETE CASCADE,
+	title		varchar(256) NOT NULL,
+	description	text NOT NULL DEFAULT '',
+	createdAt	timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX curriculums_owner_idx ON curriculums (owner);
+CREATE TABLE curriculum_sections (
+	id			SERIAL PRIMARY KEY,
+	curriculum	integer NOT NULL references curriculums(id) ON DELETE CASCADE,
+	position	integer NOT NULL,
+	title		varchar(256) NOT NULL,
+	description	text NOT NULL DEFAULT ''
+);
+CREATE INDEX curriculum_sections_curriculum_idx ON curriculum_sections (curriculum, position);
+CREATE TABLE curriculum_steps (
+	id					SERIAL PRIMARY KEY,
+	section				integer NOT NULL references curriculum_sections(id) ON DELETE CASCADE,
+	position			integer NOT NULL,
+	resource			integer NOT NULL references resources(id) ON DELETE CASCADE,
+	note				text NOT NULL DEFAULT '',
+	estimatedMinutes	integer NOT NULL DEFAULT 0 CHECK (estimatedMinutes >= 0),
+	optional			boolean NOT NULL DEFAULT false
+);
+CREATE INDEX curriculum_steps_section_idx ON curriculum_steps (section, position)`,
+		Down: `
+DROP TABLE IF EXISTS curriculum_steps;
+DROP TABLE IF EXISTS curriculum_sections;
+DROP TABLE IF EXISTS curriculums`,
+	},
+	{
+		Version: 10,
+		Name:    "create curriculum progress",
+		Up: `
+CREATE TABLE curriculum_progress (
+	learner		integer NOT NULL references users(id) ON DELETE CASCADE,
+	curriculum	integer NOT NULL references curriculums(id) ON DELETE CASCADE,
+	step		integer NOT NULL references curriculum_steps(id) ON DELETE CASCADE,
+	status		varchar(16) NOT NULL CHECK (status IN ('started', 'completed')),
+	startedAt	timestamptz NOT NULL DEFAULT now(),
+	completedAt	timestamptz,
+	PRIMARY KEY (learner, step)
+);
+CREATE INDEX curriculum_progress_curriculum_idx ON curriculum_progress (learner, curriculum)`,
+		Down: `
+DROP TABLE IF EXISTS curriculum_progress`,
+	},
+	{
+		Version: 11,
+		Name:    "create reviews",
+		Up: `
+CREATE TABLE reviews (
+	resource	integer NOT NULL references resources(id) ON DELETE CASCADE,
+	reviewer	integer NOT NULL references users(id) ON DELETE CASCADE,
+	rating		smallint NOT NULL CHECK (rating BETWEEN 1 AND 5),
+	text		text NOT NULL DEFAULT '',
+	createdAt	timestamptz NOT NULL DEFAULT now(),
+	updatedAt	timestamptz NOT NULL DEFAULT now(),
+	PRIMARY KEY (resource, reviewer)
+);
+CREATE INDEX reviews_resource_updated_idx ON reviews (resource, updatedAt DESC);
+ALTER TABLE resources
+	ADD COLUMN rating		double precision NOT NULL DEFAULT 0,
+	ADD COLUMN ratingCount	integer NOT NULL DEFAULT 0;
+CREATE INDEX resources_rating_idx ON resources (rating DESC, ratingCount DESC, id DESC) WHERE deleted = false`,
+		Down: `
+DROP INDEX IF EXISTS resources_rating_idx;
+ALTER TABLE resources DROP COLUMN IF EXISTS rating, DROP COLUMN IF EXISTS ratingCount;
+DROP TABLE IF EXISTS reviews`,
+	},
+	{
+		Version: 12,
+		Name:    "create votes",
+		// existing resources date from their first revision, hot mirrors store.HotScore for a score of 0
+		Up: `
+ALTER TABLE resources
+	ADD COLUMN createdAt	timestamptz NOT NULL DEFAULT now(),
+	ADD COLUMN score		integer NOT NULL DEFAULT 0,
+	ADD COLUMN hot			double precision NOT NULL DEFAULT 0;
+UPDATE resources r SET createdAt = rv.createdAt
+FROM resource_revisions rv WHERE rv.resource = r.id AND rv.revision = 1;
+UPDATE resources SET hot = (floor(extract(epoch FROM createdAt)) - 1514764800) / 45000;
+CREATE INDEX resources_hot_idx ON resources (hot DESC, id DESC) WHERE deleted = false;
+CREATE INDEX resources_score_idx ON resources (score DESC, id DESC) WHERE deleted = false;
+CREATE TABLE votes (
+	resource	integer NOT NULL references resources(id) ON DELETE CASCADE,
+	voter		integer NOT NULL references users(id) ON DELETE CASCADE,
+	value		smallint NOT NULL CHECK (value IN (-1, 1)),
+	createdAt	timestamptz NOT NULL DEFAULT now(),
+	PRIMARY KEY (resource, voter)
+)`,
+		Down: `
+DROP TABLE IF EXISTS votes;
+DROP INDEX IF EXISTS resources_score_idx;
+DROP INDEX IF EXISTS resources_hot_idx;
+ALTER TABLE resources DROP COLUMN IF EXISTS hot, DROP COLUMN IF EXISTS score, DROP COLUMN IF EXISTS createdAt`,
+	},
+	{
+		Version: 13,
+		Name:    "create comments",
+		// thread is left null on top level comments, they're their own thread
+		Up: `
+CREATE TABLE comments (
+	id			SERIAL PRIMARY KEY,
+	resource	integer NOT NULL references resources(id) ON DELETE CASCADE,
+	parent		integer references comments(id) ON DELETE CASCADE,
+	thread		integer references comments(id) ON DELETE CASCADE,
+	depth		integer NOT NULL DEFAULT 0,
+	author		integer references users(id) ON DELETE SET NULL,
+	body		text NOT NULL,
+	deleted		boolean NOT NULL DEFAULT false,
+	createdAt	timestamptz NOT NULL DEFAULT now(),
+	editedAt	timestamptz
+);
+CREATE INDEX comments_resource_top_idx ON comments (resource, id DESC) WHERE parent IS NULL;
+CREATE INDEX comments_thread_idx ON comments (thread)`,
+		Down: `
+DROP TABLE IF EXISTS comments`,
+	},
+	{
+		Version: 14,
+		Name:    "create bookmarks",
+		Up: `
+CREATE TABLE bookmarks (
+	owner		integer NOT NULL references users(id) ON DELETE CASCADE,
+	resource	integer NOT NULL references resources(id) ON DELETE CASCADE,
+	createdAt	timestamptz NOT NULL DEFAULT now(),
+	PRIMARY KEY (owner, resource)
+);
+CREATE INDEX bookmarks_owner_created_idx ON bookmarks (owner, createdAt DESC);
+ALTER TABLE resources ADD COLUMN bookmarkCount integer NOT NULL DEFAULT 0`,
+		Down: `
+ALTER TABLE resources DROP COLUMN IF EXISTS bookmarkCount;
+DROP TABLE IF EXISTS bookmarks`,
+	},
+	{
+		Version: 15,
+		Name:    "create follows and events",
+		// tags are followed by name so a tag can be followed before anything carries it,
+		// events keep the tags a resource had when it was approved
+		Up: `
+CREATE TABLE user_follows (
+	follower	integer NOT NULL references users(id) ON DELETE CASCADE,
+	followee	integer NOT NULL references users(id) ON DELETE CASCADE,
+	createdAt	timestamptz NOT NULL DEFAULT now(),
+	PRIMARY KEY (follower, followee)
+);
+CREATE TABLE tag_follows (
+	follower	integer NOT NULL references users(id) ON DELETE CASCADE,
+	tag			varchar(256) NOT NULL,
+	createdAt	timestamptz NOT NULL DEFAULT now(),
+	PRIMARY KEY (follower, tag)
+);
+CREATE TABLE events (
+	id			SERIAL PRIMARY KEY,
+	type		varchar(32) NOT NULL,
+	actor		integer references users(id) ON DELETE CASCADE,
+	subject		integer NOT NULL,
+	tags		varchar(256)[] NOT NULL DEFAULT '{}',
+	createdAt	timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX events_actor_idx ON events (actor, id DESC);
+CREATE INDEX events_tags_idx ON events USING GIN (tags)`,
+		Down: `
+DROP TABLE IF EXISTS events;
+DROP TABLE IF EXISTS tag_follows;
+DROP TABLE IF EXISTS user_follows`,
+	},
+	{
+		Version: 16,
+		Name:    "create notifications",
+		Up: `
+CREATE TABLE notifications (
+	id			SERIAL PRIMARY KEY,
+	recipient	integer NOT NULL references users(id) ON DELETE CASCADE,
+	type		varchar(32) NOT NULL,
+	actor		integer references users(id) ON DELETE SET NULL,
+	resource	integer references resources(id) ON DELETE CASCADE,
+	comment		integer references comments(id) ON DELETE CASCADE,
+	message		text NOT NULL DEFAULT '',
+	read		boolean NOT NULL DEFAULT false,
+	createdAt	timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX notifications_recipient_idx ON notifications (recipient, id DESC);
+CREATE INDEX notifications_unread_idx ON notifications (recipient) WHERE read = false;
+ALTER TABLE users ADD COLUMN notificationPreferences jsonb NOT NULL DEFAULT '{}'`,
+		Down: `
+ALTER TABLE users DROP COLUMN IF EXISTS notificationPreferences;
+DROP TABLE IF EXISTS notifications`,
+	},
+	{
+		Version: 17,
+		Name:    "add verification sent",
+		Up:      `ALTER TABLE users ADD COLUMN verificationSentAt timestamptz`,
+		Down:    `ALTER TABLE users DROP COLUMN IF EXISTS verificationSentAt`,
+	},
+	{
+		Version: 18,
+		Name:    "create password resets",
+		Up: `
+CREATE TABLE password_resets (
+	tokenHash	char(64) PRIMARY KEY,
+	owner		integer NOT NULL references users(id) ON DELETE CASCADE,
+	expiresAt	timestamptz NOT NULL,
+	createdAt	timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX password_resets_owner_idx ON password_resets (owner);
+ALTER TABLE users ADD COLUMN sessionsRevokedAt timestamptz`,
+		Down: `
+ALTER TABLE users DROP COLUMN IF EXISTS sessionsRevokedAt;
+DROP TABLE IF EXISTS password_resets`,
+	},
+	{
+		Version: 19,
+		Name:    "create outbox",
+		Up: `
+CREATE TABLE outbox (
+	id				SERIAL PRIMARY KEY,
+	recipient		text NOT NULL,
+	subject			text NOT NULL,
+	text			text NOT NULL,
+	html			text NOT NULL DEFAULT '',
+	attempts		integer NOT NULL DEFAULT 0,
+	lastError		text NOT NULL DEFAULT '',
+	nextAttemptAt	timestamptz DEFAULT now(),
+	createdAt		timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX outbox_due_idx ON outbox (nextAttemptAt) WHERE nextAttemptAt IS NOT NULL`,
+		Down: `DROP TABLE IF EXISTS outbox`,
+	},
+	{
+		Version: 20,
+		Name:    "create sessions",
+		Up: `
+CREATE TABLE sessions (
+	id			SERIAL PRIMARY KEY,
+	owner		integer NOT NULL references users(id) ON DELETE CASCADE,
+	userAgent	text NOT NULL DEFAULT '',
+	ip			text NOT NULL DEFAULT '',
+	createdAt	timestamptz NOT NULL DEFAULT now(),
+	lastUsedAt	timestamptz NOT NULL DEFAULT now(),
+	expiresAt	timestamptz NOT NULL,
+	revokedAt	timestamptz
+);
+CREATE INDEX sessions_owner_idx ON sessions (owner);
+CREATE TABLE refresh_tokens (
+	tokenHash	char(64) PRIMARY KEY,
+	session		integer NOT NULL references sessions(id) ON DELETE CASCADE,
+	rotated		boolean NOT NULL DEFAULT false
+)`,
+		Down: `
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS sessions`,
+	},
+	{
+		Version: 21,
+		Name:    "replace moderator flag with roles",
+		Up: `
+ALTER TABLE users ADD COLUMN roles varchar(32)[] NOT NULL DEFAULT '{}';
+UPDATE users SET roles = '{moderator}' WHERE isModerator;
+ALTER TABLE users DROP COLUMN isModerator`,
+		Down: `
+ALTER TABLE users ADD COLUMN isModerator BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE users SET isModerator = 'moderator' = ANY(roles);
+ALTER TABLE users DROP COLUMN IF EXISTS roles`,
+	},
+	{
+		Version: 22,
+		Name:    "create api keys",
+		Up: `
+CREATE TABLE api_keys (
+	id			SERIAL PRIMARY KEY,
+	owner		integer NOT NULL references users(id) ON DELETE CASCADE,
+	name		varchar(100) NOT NULL,
+	prefix		varchar(32) NOT NULL,
+	scopes		varchar(32)[] NOT NULL,
+	tokenHash	char(64) NOT NULL UNIQUE,
+	expiresAt	timestamptz,
+	lastUsedAt	timestamptz,
+	createdAt	timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX api_keys_owner_idx ON api_keys (owner)`,
+		Down: `DROP TABLE IF EXISTS api_keys`,
+	},
+	{
+		Version: 23,
+		Name:    "create external accounts",
+		Up: `
+CREATE TABLE external_accounts (
+	provider	varchar(64) NOT NULL,
+	subject		text NOT NULL,
+	owner		integer NOT NULL references users(id) ON DELETE CASCADE,
+	email		text NOT NULL DEFAULT '',
+	createdAt	timestamptz NOT NULL DEFAULT now(),
+	PRIMARY KEY (provider, subject),
+	UNIQUE (owner, provider)
+)`,
+		Down: `DROP TABLE IF EXISTS external_accounts`,
+	},
+	{
+		Version: 24,
+		Name:    "keep resources of deleted users",
+		Up: `
+ALTER TABLE resources DROP CONSTRAINT IF EXISTS resources_submitter_fkey;
+ALTER TABLE resources ADD CONSTRAINT resources_submitter_fkey
+	FOREIGN KEY (submitter) REFERENCES users(id) ON DELETE SET NULL`,
+		Down: `
+ALTER TABLE resources DROP CONSTRAINT IF EXISTS resources_submitter_fkey;
+ALTER TABLE resources ADD CONSTRAINT resources_submitter_fkey
+	FOREIGN KEY (submitter) REFERENCES users(id)`,
+	},
+	{
+		Version: 25,
+		Name:    "make usernames unique",
+		Up:      `ALTER TABLE users ADD CONSTRAINT users_username_key UNIQUE (username)`,
+		Down:    `ALTER TABLE users DROP CONSTRAINT IF EXISTS users_username_key`,
+	},
+	{
+		Version: 26,
+		Name:    "add resources approved at",
+		Up: `
+ALTER TABLE resources ADD COLUMN approvedAt timestamptz;
+UPDATE resources r SET approvedAt = COALESCE(
+	(SELECT min(e.createdAt) FROM events e WHERE e.type = 'resource.approved' AND e.subject = r.id),
+	CASE WHEN r.approved THEN COALESCE(r.moderatedAt, r.createdAt) END)`,
+		Down: `ALTER TABLE resources DROP COLUMN IF EXISTS approvedAt`,
+	},
+}
+
+// Migrator returns a migrations.Migrator loaded with the schema of the postgres store
+func Migrator(db *sql.DB) (*migrations.Migrator, error) {
+	return migrations.New(db, schema)
+}
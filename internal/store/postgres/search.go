@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// escapedDescription HTML escapes the description the way html.EscapeString does, so the
+// only markup in a headline is the highlighting
+const escapedDescription = `replace(replace(replace(replace(replace(
+				coalesce(r.description, ''), '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&#34;'), '''', '&#39;')`
+
+// Search ranks approved resources with the weighted search vector and highlights the description
+func (s *service) Search(query store.SearchQuery) (results []store.SearchResult, err error) {
+	rows, err := s.db.Query(`
+		SELECT `+resourceColumns+`, ts_rank(r.search, query),
+			ts_headline('english', `+escapedDescription+`, query,
+				'MaxWords=20, MinWords=5, StartSel=<b>, StopSel=</b>')
+		FROM resources r, websearch_to_tsquery('english', $1) query
+		WHERE r.deleted = false AND r.approved = true AND r.search @@ query
+		ORDER BY ts_rank(r.search, query) DESC, r.id DESC
+		LIMIT $2 OFFSET $3`, query.Text, query.Limit, query.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var result store.SearchResult
+		if err = rows.Scan(append(resourceFields(&result.Resource), &result.Rank, &result.Snippet)...); err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,132 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Session Functions
+
+const sessionColumns = "id, owner, userAgent, ip, createdAt, lastUsedAt, expiresAt, revokedAt"
+
+// scanSession scans a row of sessionColumns
+func scanSession(row interface {
+	Scan(dest ...interface{}) error
+}) (session store.Session, err error) {
+	err = row.Scan(&session.ID, &session.User, &session.UserAgent, &session.IP, &session.CreatedAt,
+		&session.LastUsedAt, &session.ExpiresAt, &session.RevokedAt)
+	return session, err
+}
+
+func (s *service) CreateSession(session store.Session, tokenHash string) (id int64, err error) {
+	err = s.withTx(func(tx *sql.Tx) error {
+		// selecting the owner turns a missing user into no rows instead of a key violation
+		err := tx.QueryRow(`
+			INSERT INTO sessions (owner, userAgent, ip, expiresAt)
+			SELECT id, $2, $3, $4 FROM users WHERE id = $1 RETURNING id`,
+			session.User, session.UserAgent, session.IP, session.ExpiresAt).Scan(&id)
+		if err == sql.ErrNoRows {
+			return store.ErrNoResults
+		} else if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("INSERT INTO refresh_tokens (tokenHash, session) VALUES ($1, $2)", tokenHash, id)
+		return err
+	})
+	return id, err
+}
+
+func (s *service) GetSession(id int64) (store.Session, error) {
+	session, err := scanSession(s.db.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return store.Session{ID: id}, store.ErrNoResults
+	}
+	return session, err
+}
+
+func (s *service) GetSessions(user int64) ([]store.Session, error) {
+	rows, err := s.db.Query(`
+		SELECT `+sessionColumns+` FROM sessions
+		WHERE owner = $1 AND revokedAt IS NULL AND expiresAt > now()
+		ORDER BY lastUsedAt DESC, id DESC`, user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []store.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// RotateSession locks the token so two refreshes with the same token can't both rotate it
+func (s *service) RotateSession(tokenHash, newHash string, expiresAt time.Time) (session store.Session, err error) {
+	reused := false
+
+	err = s.withTx(func(tx *sql.Tx) error {
+		var rotated bool
+		var id int64
+		err := tx.QueryRow("SELECT session, rotated FROM refresh_tokens WHERE tokenHash = $1 FOR UPDATE",
+			tokenHash).Scan(&id, &rotated)
+		if err == sql.ErrNoRows {
+			return store.ErrNoResults
+		} else if err != nil {
+			return err
+		}
+
+		session, err = scanSession(tx.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE id = $1 FOR UPDATE", id))
+		if err == sql.ErrNoRows {
+			return store.ErrNoResults
+		} else if err != nil {
+			return err
+		}
+		if !session.Active(time.Now()) {
+			return store.ErrNoResults
+		}
+
+		if rotated {
+			// the revocation has to be committed, so the error is only returned after
+			reused = true
+			_, err := tx.Exec("UPDATE sessions SET revokedAt = now() WHERE id = $1", id)
+			return err
+		}
+
+		if _, err := tx.Exec("UPDATE refresh_tokens SET rotated = true WHERE tokenHash = $1", tokenHash); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT INTO refresh_tokens (tokenHash, session) VALUES ($1, $2)", newHash, id); err != nil {
+			return err
+		}
+
+		return tx.QueryRow(
+			"UPDATE sessions SET lastUsedAt = now(), expiresAt = $1 WHERE id = $2 RETURNING lastUsedAt, expiresAt",
+			expiresAt, id).Scan(&session.LastUsedAt, &session.ExpiresAt)
+	})
+	if err == nil && reused {
+		err = store.ErrReusedToken
+	}
+	if err != nil {
+		return store.Session{}, err
+	}
+	return session, nil
+}
+
+func (s *service) RevokeSession(user, id int64) error {
+	return affectedOne(s.db.Exec(
+		"UPDATE sessions SET revokedAt = COALESCE(revokedAt, now()) WHERE id = $1 AND owner = $2", id, user))
+}
+
+func (s *service) RevokeSessionByToken(tokenHash string) error {
+	return affectedOne(s.db.Exec(`
+		UPDATE sessions SET revokedAt = COALESCE(revokedAt, now())
+		WHERE id = (SELECT session FROM refresh_tokens WHERE tokenHash = $1)`, tokenHash))
+}
@@ -0,0 +1,190 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// tagsColumn selects the sorted tag names of the resource aliased r as a text array
+const tagsColumn = `ARRAY(
+			SELECT tags.name FROM tag JOIN tags ON tags.id = tag.tag
+			WHERE tag.resource = r.id ORDER BY tags.name)`
+
+// addTags creates any tags that don't exist yet and attaches them to the resource
+func addTags(tx *sql.Tx, resourceID int64, tags []string) error {
+	for _, name := range tags {
+		_, err := tx.Exec("INSERT INTO tags (name) VALUES ($1) ON CONFLICT (name) DO NOTHING", name)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			"INSERT INTO tag (resource, tag) SELECT $1, id FROM tags WHERE name = $2 ON CONFLICT DO NOTHING",
+			resourceID, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resourceExists returns store.ErrNoResults for missing and deleted resources
+func resourceExists(tx *sql.Tx, id int64) error {
+	err := tx.QueryRow("SELECT id FROM resources WHERE id = $1 AND deleted = false", id).Scan(&id)
+	if err == sql.ErrNoRows {
+		return store.ErrNoResults
+	}
+	return err
+}
+
+func (s *service) AddTags(resourceID int64, tags []string) error {
+	tags, err := store.NormalizeTags(tags)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := resourceExists(tx, resourceID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := addTags(tx, resourceID, tags); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *service) RemoveTags(resourceID int64, tags []string) error {
+	tags, err := store.NormalizeTags(tags)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := resourceExists(tx, resourceID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(
+		"DELETE FROM tag USING tags WHERE tag.tag = tags.id AND tag.resource = $1 AND tags.name = ANY($2)",
+		resourceID, pq.Array(tags))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetTags lists the tags on approved resources with the number of them using it, most used
+// first. Tags rows outlive their last use so they're joined to live resources to match the
+// other stores
+func (s *service) GetTags() (tags []store.Tag, err error) {
+	rows, err := s.db.Query(`
+		SELECT tags.name, COUNT(resources.id)
+		FROM tags
+		JOIN tag ON tag.tag = tags.id
+		JOIN resources ON resources.id = tag.resource AND resources.deleted = false AND resources.approved = true
+		GROUP BY tags.name
+		ORDER BY COUNT(resources.id) DESC, tags.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tag store.Tag
+		if err = rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return tags, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// GetTaggedResources only lists approved resources
+func (s *service) GetTaggedResources(tag string) ([]store.Resource, error) {
+	rows, err := s.db.Query(`
+		SELECT `+resourceColumns+`
+		FROM resources r
+		JOIN tag ON tag.resource = r.id
+		JOIN tags ON tags.id = tag.tag
+		WHERE tags.name = $1 AND r.deleted = false AND r.approved = true
+		ORDER BY r.id`, tag)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanResources(rows)
+}
+
+// MergeTags drops the from tag entirely, deleted resources that carried it are retagged too
+func (s *service) MergeTags(from, into string) error {
+	tags, err := store.NormalizeTags([]string{from})
+	if err != nil {
+		return err
+	}
+	from = tags[0]
+	if tags, err = store.NormalizeTags([]string{into}); err != nil {
+		return err
+	}
+	into = tags[0]
+
+	return s.withTx(func(tx *sql.Tx) error {
+		var live int
+		err := tx.QueryRow(`
+			SELECT COUNT(*) FROM tag
+			JOIN tags ON tags.id = tag.tag
+			JOIN resources ON resources.id = tag.resource
+			WHERE tags.name = $1 AND resources.deleted = false`, from).Scan(&live)
+		if err != nil {
+			return err
+		}
+		if live == 0 {
+			return store.ErrNoResults
+		}
+
+		_, err = tx.Exec("INSERT INTO tags (name) VALUES ($1) ON CONFLICT (name) DO NOTHING", into)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO tag (resource, tag)
+			SELECT tag.resource, (SELECT id FROM tags WHERE name = $2)
+			FROM tag JOIN tags ON tags.id = tag.tag
+			WHERE tags.name = $1
+			ON CONFLICT DO NOTHING`, from, into)
+		if err != nil {
+			return err
+		}
+		// the tag rows of from go with it
+		if _, err = tx.Exec("DELETE FROM tags WHERE name = $1", from); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO tag_follows (follower, tag, createdAt)
+			SELECT follower, $2, createdAt FROM tag_follows WHERE tag = $1
+			ON CONFLICT DO NOTHING`, from, into)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM tag_follows WHERE tag = $1", from)
+		return err
+	})
+}
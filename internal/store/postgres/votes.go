@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// Vote Functions
+
+// Vote applies the difference from the voter's previous vote to the score stored on the
+// resource, the resource row is locked so concurrent votes can't lose an update
+func (s *service) Vote(resourceID, voter int64, value int) error {
+	if err := store.ValidateVote(value); err != nil {
+		return err
+	}
+
+	return s.withTx(func(tx *sql.Tx) error {
+		var (
+			score     int64
+			createdAt time.Time
+		)
+		err := tx.QueryRow("SELECT score, createdAt FROM resources WHERE id = $1 AND deleted = false FOR UPDATE",
+			resourceID).Scan(&score, &createdAt)
+		if err == sql.ErrNoRows {
+			return store.ErrNoResults
+		} else if err != nil {
+			return err
+		}
+
+		previous := 0
+		err = tx.QueryRow("SELECT value FROM votes WHERE resource = $1 AND voter = $2", resourceID, voter).Scan(&previous)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		if value == 0 {
+			_, err = tx.Exec("DELETE FROM votes WHERE resource = $1 AND voter = $2", resourceID, voter)
+		} else {
+			_, err = tx.Exec(`
+				INSERT INTO votes (resource, voter, value) VALUES ($1, $2, $3)
+				ON CONFLICT (resource, voter) DO UPDATE SET value = EXCLUDED.value, createdAt = now()`,
+				resourceID, voter, value)
+		}
+		if err != nil {
+			return err
+		}
+
+		score += int64(value - previous)
+		_, err = tx.Exec("UPDATE resources SET score = $1, hot = $2 WHERE id = $3",
+			score, store.HotScore(score, createdAt), resourceID)
+		return err
+	})
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// Step progress statuses, a step without progress hasn't been started
+const (
+	ProgressStarted   = "started"
+	ProgressCompleted = "completed"
+)
+
+// StepProgress is where a learner is on one curriculum step
+type StepProgress struct {
+	Learner     int64      `json:"-"`
+	Curriculum  int64      `json:"curriculum"`
+	Step        int64      `json:"step"`
+	Status      string     `json:"status"`
+	StartedAt   time.Time  `json:"startedAt"`
+	CompletedAt *time.Time `json:"completedAt"`
+}
+
+// Progress summarises a learner's progress through a curriculum, optional steps don't
+// count towards completion
+type Progress struct {
+	Curriculum   int64             `json:"curriculum"`
+	Title        string            `json:"title"`
+	Completed    int               `json:"completed"`
+	Total        int               `json:"total"`
+	Percent      int               `json:"percent"`
+	LastActivity time.Time         `json:"lastActivity"`
+	Sections     []SectionProgress `json:"sections"`
+	Steps        []StepProgress    `json:"steps"`
+}
+
+// SectionProgress is the completion of one section
+type SectionProgress struct {
+	Section   int64  `json:"section"`
+	Title     string `json:"title"`
+	Completed int    `json:"completed"`
+	Total     int    `json:"total"`
+	Percent   int    `json:"percent"`
+}
+
+// ValidateProgressStatus checks a status sent by a learner
+func ValidateProgressStatus(status string) error {
+	if status != ProgressStarted && status != ProgressCompleted {
+		return fmt.Errorf("status must be %s or %s", ProgressStarted, ProgressCompleted)
+	}
+	return nil
+}
+
+// MarkProgress moves a step to status, keeping when it was first started and first completed,
+// existing is nil when the step hasn't been started
+func MarkProgress(existing *StepProgress, status string, now time.Time) StepProgress {
+	progress := StepProgress{Status: status, StartedAt: now}
+	if existing != nil {
+		progress = *existing
+		progress.Status = status
+	}
+
+	if status == ProgressCompleted {
+		if progress.CompletedAt == nil {
+			progress.CompletedAt = &now
+		}
+	} else {
+		progress.CompletedAt = nil
+	}
+	return progress
+}
+
+// FindStep returns the step with the given ID from any section
+func (c *Curriculum) FindStep(id int64) (*Step, error) {
+	for i := range c.Sections {
+		if step, err := c.Sections[i].FindStep(id); err == nil {
+			return step, nil
+		}
+	}
+	return nil, ErrNoResults
+}
+
+// ComputeProgress works out the completion of a curriculum from a learner's step progress,
+// progress on steps no longer in the curriculum is ignored
+func ComputeProgress(curriculum Curriculum, steps []StepProgress) Progress {
+	byStep := make(map[int64]StepProgress, len(steps))
+	for _, step := range steps {
+		byStep[step.Step] = step
+	}
+
+	progress := Progress{
+		Curriculum: curriculum.ID,
+		Title:      curriculum.Title,
+		Sections:   make([]SectionProgress, 0, len(curriculum.Sections)),
+		Steps:      []StepProgress{},
+	}
+	for _, section := range curriculum.Sections {
+		sp := SectionProgress{Section: section.ID, Title: section.Title}
+		for _, step := range section.Steps {
+			p, ok := byStep[step.ID]
+			if ok {
+				progress.Steps = append(progress.Steps, p)
+				if latest := p.latest(); latest.After(progress.LastActivity) {
+					progress.LastActivity = latest
+				}
+			}
+
+			if step.Optional {
+				continue
+			}
+			sp.Total++
+			if ok && p.Status == ProgressCompleted {
+				sp.Completed++
+			}
+		}
+
+		sp.Percent = percent(sp.Completed, sp.Total)
+		progress.Completed += sp.Completed
+		progress.Total += sp.Total
+		progress.Sections = append(progress.Sections, sp)
+	}
+	progress.Percent = percent(progress.Completed, progress.Total)
+
+	return progress
+}
+
+func (p StepProgress) latest() time.Time {
+	if p.CompletedAt != nil {
+		return *p.CompletedAt
+	}
+	return p.StartedAt
+}
+
+// percent rounds down, anything without required steps counts as done
+func percent(completed, total int) int {
+	if total == 0 {
+		return 100
+	}
+	return completed * 100 / total
+}
@@ -0,0 +1,41 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeProgress(t *testing.T) {
+	curriculum := Curriculum{ID: 1, Sections: []Section{
+		{ID: 1, Steps: []Step{{ID: 10}, {ID: 11}, {ID: 12, Optional: true}}},
+		{ID: 2, Steps: []Step{{ID: 20, Optional: true}}},
+	}}
+
+	now := time.Now()
+	steps := []StepProgress{
+		MarkProgress(nil, ProgressCompleted, now),
+		MarkProgress(nil, ProgressStarted, now),
+		MarkProgress(nil, ProgressCompleted, now),
+	}
+	steps[0].Step, steps[1].Step, steps[2].Step = 10, 11, 99
+
+	progress := ComputeProgress(curriculum, steps)
+	if progress.Completed != 1 || progress.Total != 2 || progress.Percent != 50 || len(progress.Steps) != 2 {
+		t.Errorf("unexpected progress: %+v", progress)
+	}
+	if progress.Sections[1].Percent != 100 {
+		t.Errorf("a section of optional steps should count as done, got %+v", progress.Sections[1])
+	}
+}
+
+func TestMarkProgress(t *testing.T) {
+	started := time.Now().Add(-time.Hour)
+	completed := MarkProgress(&StepProgress{Status: ProgressStarted, StartedAt: started}, ProgressCompleted, time.Now())
+	if !completed.StartedAt.Equal(started) || completed.CompletedAt == nil {
+		t.Errorf("unexpected completed progress: %+v", completed)
+	}
+
+	if reopened := MarkProgress(&completed, ProgressStarted, time.Now()); reopened.CompletedAt != nil {
+		t.Errorf("restarting a step should clear its completion: %+v", reopened)
+	}
+}
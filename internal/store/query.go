@@ -0,0 +1,271 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sort orders for ResourceQuery
+const (
+	SortNewest     = "newest"
+	SortName       = "name"
+	SortPopularity = "popularity"
+	SortRating     = "rating"
+	SortHot        = "hot"
+)
+
+// sorts are the orders a request can ask for
+var sorts = []string{SortNewest, SortName, SortPopularity, SortRating, SortHot}
+
+// Page size limits for ResourceQuery
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// QueryError is returned for query parameters that are unknown or malformed
+type QueryError struct {
+	Param   string
+	Message string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("invalid query parameter %q: %s", e.Param, e.Message)
+}
+
+// Page is a validated limit and offset for lists without a cursor
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// ParsePage validates the limit and offset url query parameters, anything else is rejected
+func ParsePage(params map[string][]string) (Page, error) {
+	page := Page{Limit: DefaultLimit}
+
+	for param, values := range params {
+		if len(values) > 1 {
+			return page, &QueryError{param, "can only be given once"}
+		}
+		value := ""
+		if len(values) > 0 {
+			value = values[0]
+		}
+
+		var err error
+		switch param {
+		case "limit":
+			page.Limit, err = parseLimit(value)
+		case "offset":
+			page.Offset, err = parseOffset(value)
+		default:
+			err = fmt.Errorf("unknown parameter")
+		}
+		if err != nil {
+			return page, &QueryError{param, err.Error()}
+		}
+	}
+
+	return page, nil
+}
+
+func parseLimit(value string) (int, error) {
+	limit, err := strconv.Atoi(value)
+	if err == nil && (limit < 1 || limit > MaxLimit) {
+		err = fmt.Errorf("must be between 1 and %d", MaxLimit)
+	}
+	return limit, err
+}
+
+func parseOffset(value string) (int, error) {
+	offset, err := strconv.Atoi(value)
+	if err == nil && offset < 0 {
+		err = fmt.Errorf("cannot be negative")
+	}
+	return offset, err
+}
+
+// Paginate returns the part of a sorted list of length n the page covers as slice bounds
+func (p Page) Paginate(n int) (start, end int) {
+	start = p.Offset
+	if start > n {
+		start = n
+	}
+	end = start + p.Limit
+	if p.Limit == 0 || end > n {
+		end = n
+	}
+	return start, end
+}
+
+// ResourceQuery is a validated GetResources query, deleted resources are never included
+type ResourceQuery struct {
+	// Tags must all be on a resource for it to match
+	Tags      []string
+	Submitter int64
+	// Approved filters on the approved flag when it's set, queries parsed from a request
+	// always only include approved resources
+	Approved *bool
+	// Prefix matches the start of the resource name, case insensitive
+	Prefix string
+
+	Sort   string
+	Limit  int
+	Offset int
+	// Cursor is the ID of the last resource of the previous page, it can't be combined with Offset
+	Cursor int64
+}
+
+// ParseResourceQuery validates url query parameters into a public ResourceQuery, which
+// only lists approved resources
+func ParseResourceQuery(params map[string][]string) (ResourceQuery, error) {
+	approved := true
+	query := ResourceQuery{Sort: SortNewest, Limit: DefaultLimit, Approved: &approved}
+
+	for param, values := range params {
+		if param != "tag" && len(values) > 1 {
+			return query, &QueryError{param, "can only be given once"}
+		}
+		value := ""
+		if len(values) > 0 {
+			value = values[0]
+		}
+
+		var err error
+		switch param {
+		case "tag":
+			query.Tags, err = NormalizeTags(values)
+		case "submitter":
+			query.Submitter, err = strconv.ParseInt(value, 10, 64)
+			if err == nil && query.Submitter <= 0 {
+				err = fmt.Errorf("must be a positive ID")
+			}
+		case "approved":
+			approved, err = strconv.ParseBool(value)
+			if err == nil && !approved {
+				err = fmt.Errorf("only approved resources are public")
+			}
+		case "prefix":
+			query.Prefix = value
+		case "sort":
+			err = fmt.Errorf("must be one of %s", strings.Join(sorts, ", "))
+			for _, known := range sorts {
+				if value == known {
+					query.Sort, err = value, nil
+				}
+			}
+		case "limit":
+			query.Limit, err = parseLimit(value)
+		case "offset":
+			query.Offset, err = parseOffset(value)
+		case "cursor":
+			query.Cursor, err = strconv.ParseInt(value, 10, 64)
+			if err == nil && query.Cursor <= 0 {
+				err = fmt.Errorf("must be a positive ID")
+			}
+		default:
+			err = fmt.Errorf("unknown parameter")
+		}
+		if err != nil {
+			return query, &QueryError{param, err.Error()}
+		}
+	}
+
+	if query.Cursor != 0 && query.Offset != 0 {
+		return query, &QueryError{"cursor", "cannot be combined with offset"}
+	}
+
+	return query, nil
+}
+
+// Match reports whether a resource passes the filters of the query
+func (q ResourceQuery) Match(resource Resource) bool {
+	if resource.Deleted {
+		return false
+	}
+	if q.Submitter != 0 && resource.Submitter != q.Submitter {
+		return false
+	}
+	if q.Approved != nil && resource.Approved != *q.Approved {
+		return false
+	}
+	if q.Prefix != "" && !strings.HasPrefix(strings.ToLower(resource.Name), strings.ToLower(q.Prefix)) {
+		return false
+	}
+	for _, tag := range q.Tags {
+		found := false
+		for _, t := range resource.Tags {
+			if t == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Popularity scores a resource for SortPopularity, higher is more popular, it's the vote
+// score plus how many users saved the resource
+func Popularity(resource Resource) float64 {
+	return float64(resource.Score + resource.BookmarkCount)
+}
+
+// less orders a before b according to the sort of the query, ties always go to the newest
+func (q ResourceQuery) less(a, b Resource) bool {
+	switch q.Sort {
+	case SortName:
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.ID < b.ID
+	case SortPopularity:
+		if pa, pb := Popularity(a), Popularity(b); pa != pb {
+			return pa > pb
+		}
+	case SortHot:
+		if a.Hot != b.Hot {
+			return a.Hot > b.Hot
+		}
+	case SortRating:
+		if a.Rating != b.Rating {
+			return a.Rating > b.Rating
+		}
+		if a.RatingCount != b.RatingCount {
+			return a.RatingCount > b.RatingCount
+		}
+	}
+	return a.ID > b.ID
+}
+
+// Apply filters, sorts and pages resources in memory, it's what stores without a query
+// language use to answer GetResources
+func (q ResourceQuery) Apply(resources []Resource) []Resource {
+	matched := make([]Resource, 0, len(resources))
+	var cursor *Resource
+	for i, resource := range resources {
+		if resource.ID == q.Cursor {
+			cursor = &resources[i]
+		}
+		if q.Match(resource) {
+			matched = append(matched, resource)
+		}
+	}
+
+	sort.Slice(matched, func(i, j int) bool { return q.less(matched[i], matched[j]) })
+
+	page := Page{Limit: q.Limit, Offset: q.Offset}
+	if q.Cursor != 0 {
+		page.Offset = len(matched)
+		if cursor != nil {
+			page.Offset = sort.Search(len(matched), func(i int) bool { return q.less(*cursor, matched[i]) })
+		}
+	}
+
+	start, end := page.Paginate(len(matched))
+	return matched[start:end]
+}
@@ -0,0 +1,67 @@
+package store
+
+import "testing"
+
+func TestParseResourceQuery(t *testing.T) {
+	query, err := ParseResourceQuery(map[string][]string{
+		"tag":      {"Go", "beginner"},
+		"approved": {"true"},
+		"sort":     {"name"},
+		"limit":    {"5"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(query.Tags) != 2 || query.Approved == nil || !*query.Approved || query.Sort != SortName || query.Limit != 5 {
+		t.Errorf("unexpected query: %+v", query)
+	}
+
+	for _, params := range []map[string][]string{
+		{"color": {"blue"}},
+		{"limit": {"1000"}},
+		{"sort": {"random"}},
+		{"offset": {"10"}, "cursor": {"4"}},
+		{"submitter": {"1", "2"}},
+	} {
+		if _, err := ParseResourceQuery(params); err == nil {
+			t.Errorf("expected %v to be rejected", params)
+		} else if _, ok := err.(*QueryError); !ok {
+			t.Errorf("expected a *QueryError for %v, got %T", params, err)
+		}
+	}
+}
+
+func TestApplyCursor(t *testing.T) {
+	var resources []Resource
+	for _, name := range []string{"c", "a", "d", "b"} {
+		resources = append(resources, Resource{ID: int64(len(resources) + 1), Name: name})
+	}
+
+	query := ResourceQuery{Sort: SortName, Limit: 2}
+	first := query.Apply(resources)
+	if len(first) != 2 || first[0].Name != "a" || first[1].Name != "b" {
+		t.Fatalf("unexpected first page: %+v", first)
+	}
+
+	query.Cursor = first[1].ID
+	second := query.Apply(resources)
+	if len(second) != 2 || second[0].Name != "c" || second[1].Name != "d" {
+		t.Errorf("unexpected second page: %+v", second)
+	}
+}
+
+func TestApplySortRating(t *testing.T) {
+	resources := []Resource{
+		{ID: 1, Rating: 4, RatingCount: 2},
+		{ID: 2, Rating: 4.5, RatingCount: 1},
+		{ID: 3, Rating: 4, RatingCount: 10},
+		{ID: 4},
+	}
+
+	sorted := ResourceQuery{Sort: SortRating}.Apply(resources)
+	for i, id := range []int64{2, 3, 1, 4} {
+		if sorted[i].ID != id {
+			t.Fatalf("unexpected rating order: %+v", sorted)
+		}
+	}
+}
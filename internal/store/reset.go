@@ -0,0 +1,23 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// PasswordReset lets whoever holds its token choose a new password for User until
+// ExpiresAt, only a hash of the token is ever stored
+type PasswordReset struct {
+	TokenHash string    `json:"tokenHash"`
+	User      int64     `json:"user"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ValidatePassword checks the length of a new password, bcrypt ignores anything past 72 bytes
+func ValidatePassword(password string) error {
+	if len(password) < 8 || len(password) > 72 {
+		return fmt.Errorf("passwords must be between 8 and 72 characters")
+	}
+	return nil
+}
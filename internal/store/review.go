@@ -0,0 +1,40 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// Review is a user's rating of a resource, a user reviews a resource at most once
+type Review struct {
+	Resource  int64     `json:"resource"`
+	Reviewer  int64     `json:"reviewer"`
+	Rating    int       `json:"rating"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ValidateReview checks the editable fields of a review
+func ValidateReview(review Review) error {
+	if review.Rating < 1 || review.Rating > 5 {
+		return fmt.Errorf("rating must be between 1 and 5 stars")
+	}
+	if len(review.Text) > 5000 {
+		return fmt.Errorf("reviews can be at most 5000 characters")
+	}
+	return nil
+}
+
+// AverageRating returns the average of ratings, 0 when there are none
+func AverageRating(ratings []int) (average float64, count int64) {
+	if len(ratings) == 0 {
+		return 0, 0
+	}
+
+	total := 0
+	for _, rating := range ratings {
+		total += rating
+	}
+	return float64(total) / float64(len(ratings)), int64(len(ratings))
+}
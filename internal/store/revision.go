@@ -0,0 +1,74 @@
+package store
+
+import "time"
+
+// Revision is a recorded change to the content of a resource, revisions of a resource
+// are numbered from 1 which is the resource as it was submitted
+type Revision struct {
+	Revision  int64             `json:"revision"`
+	Resource  int64             `json:"resource"`
+	Editor    int64             `json:"editor"`
+	CreatedAt time.Time         `json:"createdAt"`
+	Changes   map[string]Change `json:"changes"`
+}
+
+// Change is the value of a single field before and after a revision
+type Change struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Diff returns the content fields that differ between two versions of a resource, keyed
+// by their json names. Approval, deletion and tags aren't edits so they're never included
+func Diff(old, new Resource) map[string]Change {
+	changes := make(map[string]Change)
+	if old.Name != new.Name {
+		changes["name"] = Change{old.Name, new.Name}
+	}
+	if old.Description != new.Description {
+		changes["description"] = Change{old.Description, new.Description}
+	}
+	if old.URL != new.URL {
+		changes["url"] = Change{old.URL, new.URL}
+	}
+	return changes
+}
+
+// Replay rebuilds the content of a resource as of revision from its ordered history
+func Replay(revisions []Revision, revision int64) (resource Resource, err error) {
+	found := false
+	for _, rev := range revisions {
+		if rev.Revision > revision {
+			break
+		}
+		for field, change := range rev.Changes {
+			switch field {
+			case "name":
+				resource.Name = change.To
+			case "description":
+				resource.Description = change.To
+			case "url":
+				resource.URL = change.To
+			}
+		}
+		found = rev.Revision == revision
+	}
+	if !found {
+		return resource, ErrNoResults
+	}
+	return resource, nil
+}
+
+// WithContent returns resource with the editable fields of content applied. Content that
+// changes has to be moderated again, so the resource goes back to the moderation queue
+func WithContent(resource, content Resource) Resource {
+	if len(Diff(resource, content)) > 0 {
+		resource.Approved = false
+		resource.Rejected = false
+		resource.RejectionReason = ""
+	}
+	resource.Name = content.Name
+	resource.Description = content.Description
+	resource.URL = content.URL
+	return resource
+}
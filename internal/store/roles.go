@@ -0,0 +1,52 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Roles grant users permissions on top of what every signed in user can do, what each
+// role allows is up to the auth package
+const (
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Roles lists every role that can be granted
+var Roles = []string{RoleAdmin, RoleModerator}
+
+// ValidateRole checks role is one of Roles
+func ValidateRole(role string) error {
+	for _, known := range Roles {
+		if role == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown role %q", role)
+}
+
+// HasRole reports whether the user was granted role
+func (u User) HasRole(role string) bool {
+	for _, granted := range u.Roles {
+		if granted == role {
+			return true
+		}
+	}
+	return false
+}
+
+// SetRole returns roles with role granted or revoked, sorted so every store saves them the
+// same way
+func SetRole(roles []string, role string, granted bool) []string {
+	updated := make([]string, 0, len(roles)+1)
+	for _, existing := range roles {
+		if existing != role {
+			updated = append(updated, existing)
+		}
+	}
+	if granted {
+		updated = append(updated, role)
+	}
+	sort.Strings(updated)
+	return updated
+}
@@ -0,0 +1,177 @@
+package store
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SearchQuery is a validated full text search over resource names and descriptions
+type SearchQuery struct {
+	Text   string
+	Limit  int
+	Offset int
+}
+
+// SearchResult is a resource matching a search, Snippet is an HTML escaped excerpt of the
+// description with the matching words wrapped in <b></b>
+type SearchResult struct {
+	Resource
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+// ParseSearchQuery validates url query parameters into a SearchQuery
+func ParseSearchQuery(params map[string][]string) (SearchQuery, error) {
+	query := SearchQuery{Limit: DefaultLimit}
+
+	for param, values := range params {
+		if len(values) > 1 {
+			return query, &QueryError{param, "can only be given once"}
+		}
+		value := ""
+		if len(values) > 0 {
+			value = values[0]
+		}
+
+		var err error
+		switch param {
+		case "q":
+			query.Text = strings.TrimSpace(value)
+		case "limit":
+			query.Limit, err = parseLimit(value)
+		case "offset":
+			query.Offset, err = parseOffset(value)
+		default:
+			err = fmt.Errorf("unknown parameter")
+		}
+		if err != nil {
+			return query, &QueryError{param, err.Error()}
+		}
+	}
+
+	if query.Text == "" {
+		return query, &QueryError{"q", "is required"}
+	}
+
+	return query, nil
+}
+
+// name matches weigh more than description matches, like the postgres A and B weights
+const (
+	nameWeight        = 1.0
+	descriptionWeight = 0.4
+	snippetWords      = 20
+)
+
+// words splits text into lowercase words
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// NaiveSearch answers a SearchQuery without an index, every word of the query has to
+// appear in the name or description of an approved resource for it to match. It's meant for
+// stores without full text search and tests, not for large data sets
+func NaiveSearch(resources []Resource, query SearchQuery) []SearchResult {
+	terms := words(query.Text)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	var results []SearchResult
+	for _, resource := range resources {
+		if resource.Deleted || !resource.Approved {
+			continue
+		}
+
+		name := count(words(resource.Name))
+		description := count(words(resource.Description))
+
+		rank := 0.0
+		for _, term := range terms {
+			if name[term] == 0 && description[term] == 0 {
+				rank = 0
+				break
+			}
+			rank += nameWeight*float64(name[term]) + descriptionWeight*float64(description[term])
+		}
+		if rank == 0 {
+			continue
+		}
+
+		results = append(results, SearchResult{
+			Resource: resource,
+			Rank:     rank,
+			Snippet:  snippet(resource.Description, terms),
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].ID > results[j].ID
+	})
+
+	start, end := Page{Limit: query.Limit, Offset: query.Offset}.Paginate(len(results))
+	return results[start:end]
+}
+
+func count(ws []string) map[string]int {
+	counts := make(map[string]int, len(ws))
+	for _, w := range ws {
+		counts[w]++
+	}
+	return counts
+}
+
+// snippet returns a window of text around the first matching word with every match
+// highlighted, the text is escaped so the only markup in it is the highlighting
+func snippet(text string, terms []string) string {
+	isTerm := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		isTerm[term] = true
+	}
+
+	fields := strings.Fields(text)
+	first := -1
+	for i, field := range fields {
+		if matches(field, isTerm) {
+			first = i
+			break
+		}
+	}
+
+	start := 0
+	if first > snippetWords/2 {
+		start = first - snippetWords/2
+	}
+	end := start + snippetWords
+	if end > len(fields) {
+		end = len(fields)
+	}
+
+	out := make([]string, 0, end-start)
+	for _, field := range fields[start:end] {
+		escaped := html.EscapeString(field)
+		if matches(field, isTerm) {
+			escaped = "<b>" + escaped + "</b>"
+		}
+		out = append(out, escaped)
+	}
+	return strings.Join(out, " ")
+}
+
+// matches reports whether any word inside a whitespace separated field is a search term
+func matches(field string, isTerm map[string]bool) bool {
+	for _, w := range words(field) {
+		if isTerm[w] {
+			return true
+		}
+	}
+	return false
+}
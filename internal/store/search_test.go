@@ -0,0 +1,43 @@
+package store
+
+import "testing"
+
+func TestNaiveSearch(t *testing.T) {
+	resources := []Resource{
+		{ID: 1, Name: "Learn Python", Description: "A tour of Go for python developers", Approved: true},
+		{ID: 2, Name: "A Tour of Go", Description: "The official interactive introduction to Go", Approved: true},
+		{ID: 3, Name: "Go by Example", Description: "Annotated example programs", Approved: true, Deleted: true},
+		{ID: 4, Name: "Go Tour Notes", Description: "Pending review"},
+	}
+
+	results := NaiveSearch(resources, SearchQuery{Text: "go tour", Limit: 10})
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %+v", results)
+	}
+	if results[0].ID != 2 {
+		t.Errorf("expected the name match to rank first, got %+v", results)
+	}
+	if results[1].Snippet != "A <b>tour</b> of <b>Go</b> for python developers" {
+		t.Errorf("unexpected snippet: %q", results[1].Snippet)
+	}
+
+	if results := NaiveSearch(resources, SearchQuery{Text: "rust", Limit: 10}); len(results) != 0 {
+		t.Errorf("expected no results, got %+v", results)
+	}
+}
+
+func TestSnippetEscapesHTML(t *testing.T) {
+	resources := []Resource{
+		{ID: 1, Name: "Go Tour", Description: `Learn <script>alert("go")</script> & more Go`, Approved: true},
+	}
+
+	results := NaiveSearch(resources, SearchQuery{Text: "go", Limit: 10})
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %+v", results)
+	}
+	// the script mentions go so it's highlighted too, but only as text
+	want := `Learn <b>&lt;script&gt;alert(&#34;go&#34;)&lt;/script&gt;</b> &amp; more <b>Go</b>`
+	if results[0].Snippet != want {
+		t.Errorf("unexpected snippet: %q", results[0].Snippet)
+	}
+}
@@ -0,0 +1,30 @@
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrReusedToken is returned for a refresh token that was already rotated out, the session
+// it belonged to is revoked since either it or its replacement leaked
+var ErrReusedToken = errors.New("refresh token was already used")
+
+// Session is a device a user signed in on, it lasts as long as its refresh token keeps
+// being rotated before ExpiresAt
+type Session struct {
+	ID         int64      `json:"id"`
+	User       int64      `json:"user"`
+	UserAgent  string     `json:"userAgent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt time.Time  `json:"lastUsedAt"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	// Current is only ever set for the session making a request
+	Current bool `json:"current"`
+}
+
+// Active reports whether the session can still be used at now
+func (s Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
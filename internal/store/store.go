@@ -1,14 +1,23 @@
 package store
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
 
 // ErrNoResults is a generic error of sql.ErrNoRows
 var ErrNoResults = fmt.Errorf("no results returned")
 
+// ErrUsernameExists is returned when a user is created with a username another user has
+var ErrUsernameExists = fmt.Errorf("Username exists, cannot create")
+
 // Service contains all functions to int64erface with a store
 type Service interface {
 	// Authentication Functions
-	Auth(user User) (string, error)
+	// Auth checks the username and password of user and returns who they are
+	Auth(user User) (User, error)
 	// User Functions
 	CreateUser(user User) (int64, error)
 	GetUser(ID int64) (User, error)
@@ -16,43 +25,269 @@ type Service interface {
 	DeleteUser(ID int64) error
 	GetUsers() ([]User, error)
 	CheckUsername(user User) error
+	// SetRole grants or revokes one of Roles
+	SetRole(ID int64, role string, granted bool) error
+	// VerifyUser marks the user's email as verified
+	VerifyUser(ID int64) error
+	// ClaimVerificationSend records that a verification email is going out to the user, it
+	// returns false without recording anything when one went out less than interval ago
+	ClaimVerificationSend(ID int64, interval time.Duration) (bool, error)
+	// GetUserByEmail ignores case, the oldest user wins when several share an email
+	GetUserByEmail(email string) (User, error)
+	// Password Reset Functions
+	// CreatePasswordReset also clears out every expired reset
+	CreatePasswordReset(reset PasswordReset) error
+	// ResetPassword consumes the unexpired reset matching tokenHash along with every other
+	// reset of its user, replaces the user's password hash and revokes every session they
+	// have. It returns the user's ID, or ErrNoResults when no usable reset matches
+	ResetPassword(tokenHash, passwordHash string) (int64, error)
+	// SessionsRevokedAt returns when the user's sessions were last revoked, tokens issued
+	// before then are no longer valid. It's the zero time when they never were
+	SessionsRevokedAt(user int64) (time.Time, error)
+	// Session Functions
+	// CreateSession starts a session whose refresh token hashes to tokenHash
+	CreateSession(session Session, tokenHash string) (int64, error)
+	GetSession(ID int64) (Session, error)
+	// GetSessions lists a user's active sessions, most recently used first
+	GetSessions(user int64) ([]Session, error)
+	// RotateSession swaps the current refresh token of a session, tokenHash, for newHash and
+	// extends the session to expiresAt. A token that was already rotated out revokes its
+	// session and returns ErrReusedToken, unknown tokens and inactive sessions ErrNoResults
+	RotateSession(tokenHash, newHash string, expiresAt time.Time) (Session, error)
+	// RevokeSession returns ErrNoResults when the session isn't the user's
+	RevokeSession(user, ID int64) error
+	// RevokeSessionByToken revokes the session a refresh token belongs to
+	RevokeSessionByToken(tokenHash string) error
+	// API Key Functions
+	// CreateAPIKey stores a key of the user whose token hashes to tokenHash
+	CreateAPIKey(key APIKey, tokenHash string) (int64, error)
+	GetAPIKey(ID int64) (APIKey, error)
+	// GetAPIKeys lists a user's keys, expired ones included, newest first
+	GetAPIKeys(user int64) ([]APIKey, error)
+	// UpdateAPIKey renames a key and replaces its scopes, it returns ErrNoResults when the
+	// key isn't key.User's
+	UpdateAPIKey(key APIKey) error
+	// DeleteAPIKey returns ErrNoResults when the key isn't the user's
+	DeleteAPIKey(user, ID int64) error
+	// UseAPIKey returns the unexpired key whose token hashes to tokenHash and records that
+	// it was used, ErrNoResults when there's none
+	UseAPIKey(tokenHash string) (APIKey, error)
+	// External Account Functions
+	// LinkAccount returns ErrNoResults when the user doesn't exist and ErrAccountLinked when
+	// either the account or the user's account at the provider is already linked
+	LinkAccount(account ExternalAccount) error
+	// CreateExternalUser creates user and links account to them in one go, so there's never
+	// a user left behind without the account they signed up with
+	CreateExternalUser(user User, account ExternalAccount) (int64, error)
+	// GetAccountUser returns the ID of the user an external account is linked to
+	GetAccountUser(provider, subject string) (int64, error)
+	// GetAccounts lists a user's linked accounts, oldest first
+	GetAccounts(user int64) ([]ExternalAccount, error)
+	UnlinkAccount(user int64, provider string) error
+	// Outbox Functions
+	// QueueMail adds mail to the outbox, due right away
+	QueueMail(mail OutboxMail) (int64, error)
+	// ClaimMail returns up to limit due mails oldest first, counting an attempt for each and
+	// holding them for lease so no other worker sends them meanwhile. Mail held by a worker
+	// that died comes due again once its lease is up
+	ClaimMail(limit int, lease time.Duration) ([]OutboxMail, error)
+	// DeleteMail removes mail that was sent from the outbox
+	DeleteMail(ID int64) error
+	// RetryMail records why a send failed and when the mail is next due, a zero retryAt
+	// gives up on the mail but keeps it in the outbox
+	RetryMail(ID int64, lastError string, retryAt time.Time) error
 	//GetUsers() ([]User, error)
 	//GetUserGroup(ID int64) ([]User, error)
 	//UpdateUser(user User) error
 	//DeleteUser(ID int64) error
-	//VerifyUser(ID int64) error
 	// Resource Functions
 	CreateResource(resource Resource) (int64, error)
 	GetResource(ID int64) (Resource, error)
-	GetResources(query map[string][]string) ([]Resource, error)
+	GetResources(query ResourceQuery) ([]Resource, error)
 	// probably consolidating this with GetResources and query params
 	//GetResourceGroup(ID int64) ([]Resource, error)
-	UpdateResource(resource Resource) error
+	UpdateResource(resource Resource, editor int64) error
 	DeleteResource(ID int64) error
+	GetRevisions(resourceID int64) ([]Revision, error)
+	RevertResource(resourceID, revision, editor int64) error
+	// Moderation Functions
+	GetPendingResources(page Page) ([]Resource, error)
+	// ApproveResource does nothing when the resource is already approved, it reports whether
+	// it approved it
+	ApproveResource(ID, moderator int64) (bool, error)
+	RejectResource(ID, moderator int64, reason string) error
+	// Tag Functions
+	AddTags(resourceID int64, tags []string) error
+	RemoveTags(resourceID int64, tags []string) error
+	GetTags() ([]Tag, error)
+	GetTaggedResources(tag string) ([]Resource, error)
+	// MergeTags retags everything tagged from with into and moves the followers of from to into,
+	// it returns ErrNoResults when no live resource is tagged from
+	MergeTags(from, into string) error
+	// Search Functions
+	Search(query SearchQuery) ([]SearchResult, error)
+	// Collection Functions
+	CreateCollection(collection Collection) (int64, error)
+	GetCollection(ID int64) (Collection, error)
+	GetCollections(query CollectionQuery) ([]Collection, error)
+	UpdateCollection(collection Collection) error
+	DeleteCollection(ID int64) error
+	// AddCollectionItem appends an item, or updates the note of an item already in the collection
+	AddCollectionItem(collectionID int64, item CollectionItem) error
+	RemoveCollectionItem(collectionID, resourceID int64) error
+	// SetCollectionItems replaces every item of a collection in the given order
+	SetCollectionItems(collectionID int64, items []CollectionItem) error
+	// Curriculum Functions
+	CreateCurriculum(curriculum Curriculum) (int64, error)
+	// GetCurriculum returns the whole tree of sections and steps
+	GetCurriculum(ID int64) (Curriculum, error)
+	GetCurriculums(query CurriculumQuery) ([]Curriculum, error)
+	UpdateCurriculum(curriculum Curriculum) error
+	DeleteCurriculum(ID int64) error
+	// AddCurriculumSection appends an empty section
+	AddCurriculumSection(curriculumID int64, section Section) (int64, error)
+	UpdateCurriculumSection(curriculumID int64, section Section) error
+	DeleteCurriculumSection(curriculumID, sectionID int64) error
+	// OrderCurriculumSections rearranges sections, order must list every section once
+	OrderCurriculumSections(curriculumID int64, order []int64) error
+	// AddCurriculumStep appends a step to a section
+	AddCurriculumStep(curriculumID, sectionID int64, step Step) (int64, error)
+	UpdateCurriculumStep(curriculumID, sectionID int64, step Step) error
+	DeleteCurriculumStep(curriculumID, sectionID, stepID int64) error
+	// OrderCurriculumSteps rearranges the steps of a section, order must list every step once
+	OrderCurriculumSteps(curriculumID, sectionID int64, order []int64) error
+	// Review Functions
+	// SetReview creates or replaces the reviewer's review of a resource
+	SetReview(review Review) error
+	GetReview(resourceID, reviewer int64) (Review, error)
+	// GetReviews lists the reviews of a resource, most recently updated first
+	GetReviews(resourceID int64, page Page) ([]Review, error)
+	DeleteReview(resourceID, reviewer int64) error
+	// Vote Functions
+	// Vote records a user's upvote (1) or downvote (-1) of a resource, 0 withdraws their vote
+	Vote(resourceID, voter int64, value int) error
+	// Bookmark Functions
+	// AddBookmark saves a resource for a user, saving it again does nothing
+	AddBookmark(user, resourceID int64) error
+	RemoveBookmark(user, resourceID int64) error
+	// GetBookmarks lists a user's saved resources, most recently saved first
+	GetBookmarks(user int64, page Page) ([]Resource, error)
+	// Bookmarked reports which of resourceIDs the user has saved
+	Bookmarked(user int64, resourceIDs []int64) (map[int64]bool, error)
+	// Follow Functions
+	// FollowUser and FollowTag do nothing when the follow already exists, FollowUser reports
+	// whether the follow is new
+	FollowUser(follower, user int64) (bool, error)
+	UnfollowUser(follower, user int64) error
+	FollowTag(follower int64, tag string) error
+	UnfollowTag(follower int64, tag string) error
+	GetFollows(follower int64) (Follows, error)
+	// GetFeed lists events by followed users or tagged with followed tags, newest first,
+	// events about anything since deleted or hidden are left out
+	GetFeed(user int64, query FeedQuery) ([]Event, error)
+	// Notification Functions
+	CreateNotification(notification Notification) (int64, error)
+	// GetNotifications lists a user's notifications, newest first
+	GetNotifications(user int64, query NotificationQuery) ([]Notification, error)
+	UnreadNotifications(user int64) (int64, error)
+	// MarkNotificationRead returns ErrNoResults when the notification isn't the user's
+	MarkNotificationRead(user, ID int64) error
+	MarkAllNotificationsRead(user int64) error
+	// SetNotificationPreferences replaces the user's notification preferences
+	SetNotificationPreferences(user int64, preferences NotificationPreferences) error
+	// Comment Functions
+	// CreateComment adds a top level comment, or a reply when Parent is set
+	CreateComment(comment Comment) (int64, error)
+	GetComment(ID int64) (Comment, error)
+	// GetComments pages through the top level comments of a resource, newest first, each
+	// with every reply under it
+	GetComments(resourceID int64, page Page) ([]Comment, error)
+	UpdateComment(ID int64, body string) error
+	// DeleteComment leaves a tombstone so replies keep their place in the thread
+	DeleteComment(ID int64) error
+	// Progress Functions
+	// SetStepProgress marks a step of the curriculum as started or completed for a learner
+	SetStepProgress(learner, curriculumID, stepID int64, status string) error
+	// GetStepProgress returns a learner's progress in a curriculum, or in every curriculum
+	// they've started when curriculumID is 0
+	GetStepProgress(learner, curriculumID int64) ([]StepProgress, error)
 	Close() error
 }
 
 // Resource is a single learning resource submitted by a user
 type Resource struct {
-	ID          int64  `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	URL         string `json:"url"`
-	Approved    bool   `json:"approved"`
-	Submitter   int64  `json:"submitter"`
-	Deleted     bool   `json:"deleted"`
+	ID          int64    `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	URL         string   `json:"url"`
+	Approved    bool     `json:"approved"`
+	Submitter   int64    `json:"submitter"`
+	Deleted     bool     `json:"deleted"`
+	Tags        []string `json:"tags"`
+	// Rejected resources were turned down by a moderator for RejectionReason
+	Rejected        bool   `json:"rejected"`
+	RejectionReason string `json:"rejectionReason,omitempty"`
+	// ModeratedBy is the moderator who last approved or rejected it, at ModeratedAt
+	ModeratedBy int64      `json:"moderatedBy,omitempty"`
+	ModeratedAt *time.Time `json:"moderatedAt,omitempty"`
+	// ApprovedAt is when it was first approved, approving it again after an edit keeps it
+	ApprovedAt *time.Time `json:"approvedAt,omitempty"`
+	// Rating is the average of RatingCount reviews, 0 when nobody has reviewed it
+	Rating      float64 `json:"rating"`
+	RatingCount int64   `json:"ratingCount"`
+	// Score is upvotes minus downvotes, Hot is the HotScore it was last ranked with
+	Score     int64     `json:"score"`
+	Hot       float64   `json:"hot"`
+	CreatedAt time.Time `json:"createdAt"`
+	// BookmarkCount is how many users saved the resource, Bookmarked is only ever set for
+	// the user making a request
+	BookmarkCount int64 `json:"bookmarkCount"`
+	Bookmarked    bool  `json:"bookmarked"`
+}
+
+// Tag is a topic resources can be grouped by, Count is how many resources carry it
+type Tag struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// NormalizeTags lowercases and trims tags, drops duplicates and sorts them so every store
+// saves tags the same way
+func NormalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			return nil, fmt.Errorf("tags cannot be empty")
+		}
+		if len(tag) > 256 {
+			return nil, fmt.Errorf("tag %q is longer than 256 characters", tag)
+		}
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	sort.Strings(normalized)
+	return normalized, nil
 }
 
 // User Represents every user that has signed up for Instruu
 type User struct {
-	ID           int64  `json:"id"`
-	Username     string `json:"username"`
-	Email        string `json:"email"`
-	FirstName    string `json:"firstName"`
-	LastName     string `json:"lastName"`
-	Verified     bool   `json:"verified"`
-	Password     string `json:"password"`
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	Verified  bool   `json:"verified"`
+	// Roles are what the user was granted beyond a regular user, see Roles
+	Roles        []string `json:"roles"`
+	Password     string   `json:"password"`
 	PasswordHash string
+	// Notifications are the notification types the user turned on or off
+	Notifications NotificationPreferences `json:"notifications,omitempty"`
 }
 
 // Secure user is a struct for return values so that password will not accidentally be returned
@@ -64,3 +299,12 @@ type SecureUser struct {
 	LastName  string `json:"lastName"`
 	Verified  bool   `json:"verified"`
 }
+
+// PublicUser is what anyone can see of a user, SecureUser without the email
+type PublicUser struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	Verified  bool   `json:"verified"`
+}
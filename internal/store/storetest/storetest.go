@@ -0,0 +1,215 @@
+// Package storetest holds tests every store.Service has to pass the same way, each store's
+// own tests run them against it
+package storetest
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/natethinks/instruu-api/internal/store"
+)
+
+// suffix keeps what a test creates apart from anything already in a shared database
+func suffix() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+// Tags checks GetTags only counts approved, live resources and leaves out tags that no such
+// resource carries
+func Tags(t *testing.T, sto store.Service) {
+	s := suffix()
+	live, pending, deleted, removed := "live-"+s, "pending-"+s, "deleted-"+s, "removed-"+s
+
+	create := func(name string, tags ...string) int64 {
+		id, err := sto.CreateResource(store.Resource{Name: name, URL: "https://example.com/" + name + "-" + s, Tags: tags})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	first := create("first", live, removed)
+	second := create("second", live, deleted)
+	create("third", live, pending)
+	gone := create("fourth", deleted)
+	for _, id := range []int64{first, second, gone} {
+		if _, err := sto.ApproveResource(id, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sto.DeleteResource(gone); err != nil {
+		t.Fatal(err)
+	}
+	if err := sto.RemoveTags(first, []string{removed}); err != nil {
+		t.Fatal(err)
+	}
+
+	tags, err := sto.GetTags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int64)
+	for _, tag := range tags {
+		counts[tag.Name] = tag.Count
+	}
+
+	if counts[live] != 2 || counts[deleted] != 1 {
+		t.Errorf("unexpected counts: %s=%d %s=%d", live, counts[live], deleted, counts[deleted])
+	}
+	for _, name := range []string{pending, removed} {
+		if count, ok := counts[name]; ok {
+			t.Errorf("expected %s to be left out, got a count of %d", name, count)
+		}
+	}
+}
+
+// DeleteUser checks what a user owns goes with them while the resources they submitted stay
+func DeleteUser(t *testing.T, sto store.Service) {
+	s := suffix()
+	id, err := sto.CreateUser(store.User{Username: "deleted-" + s, Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resource, err := sto.CreateResource(store.Resource{Name: "kept", URL: "https://example.com/kept-" + s, Submitter: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sto.LinkAccount(store.ExternalAccount{Provider: "github", Subject: s, User: id}); err != nil {
+		t.Fatal(err)
+	}
+	tokenHash := strings.Repeat("0", 64-len(s)) + s
+	if _, err := sto.CreateAPIKey(store.APIKey{User: id, Name: "deleted", Scopes: []string{"read"}}, tokenHash); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sto.DeleteUser(id); err != nil {
+		t.Fatal(err)
+	}
+	if err := sto.DeleteUser(id); err != store.ErrNoResults {
+		t.Errorf("expected ErrNoResults deleting a missing user, got %v", err)
+	}
+	if _, err := sto.GetUser(id); err != store.ErrNoResults {
+		t.Errorf("expected ErrNoResults getting a deleted user, got %v", err)
+	}
+	if _, err := sto.GetAccountUser("github", s); err != store.ErrNoResults {
+		t.Errorf("expected the linked account to go with the user, got %v", err)
+	}
+	if _, err := sto.UseAPIKey(tokenHash); err != store.ErrNoResults {
+		t.Errorf("expected the api key to go with the user, got %v", err)
+	}
+	if _, err := sto.GetResource(resource); err != nil {
+		t.Errorf("expected the submitted resource to stay: %v", err)
+	}
+}
+
+// Usernames checks a username can only be taken once, whether creating or renaming a user
+func Usernames(t *testing.T, sto store.Service) {
+	s := suffix()
+	taken, other := "taken-"+s, "other-"+s
+
+	id, err := sto.CreateUser(store.User{Username: taken, Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sto.CreateUser(store.User{Username: taken, Password: "secret"}); err != store.ErrUsernameExists {
+		t.Errorf("expected ErrUsernameExists creating a taken username, got %v", err)
+	}
+	if err := sto.CheckUsername(store.User{Username: taken}); err != store.ErrUsernameExists {
+		t.Errorf("expected ErrUsernameExists checking a taken username, got %v", err)
+	}
+
+	second, err := sto.CreateUser(store.User{Username: other, Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sto.PatchUser(store.User{ID: second, Username: taken}); err == nil {
+		t.Error("renamed a user to a taken username")
+	}
+	if user, err := sto.GetUser(id); err != nil || user.Username != taken {
+		t.Errorf("expected user %d to keep %s, got %q: %v", id, taken, user.Username, err)
+	}
+}
+
+// ExternalUser checks a user signing up with an external account is created and linked
+// together, or not at all
+func ExternalUser(t *testing.T, sto store.Service) {
+	s := suffix()
+	account := store.ExternalAccount{Provider: "github", Subject: s, Email: s + "@example.com"}
+
+	id, err := sto.CreateExternalUser(store.User{Username: "external-" + s, Password: "secret"}, account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if linked, err := sto.GetAccountUser("github", s); err != nil || linked != id {
+		t.Errorf("expected the account to be linked to %d, got %d: %v", id, linked, err)
+	}
+
+	if _, err := sto.CreateExternalUser(store.User{Username: "again-" + s, Password: "secret"}, account); err != store.ErrAccountLinked {
+		t.Errorf("expected ErrAccountLinked signing up with a linked account, got %v", err)
+	}
+	if err := sto.CheckUsername(store.User{Username: "again-" + s}); err != nil {
+		t.Errorf("expected no user to be left behind by a failed link: %v", err)
+	}
+
+	other := store.ExternalAccount{Provider: "github", Subject: "other-" + s}
+	if _, err := sto.CreateExternalUser(store.User{Username: "external-" + s, Password: "secret"}, other); err != store.ErrUsernameExists {
+		t.Errorf("expected ErrUsernameExists signing up with a taken username, got %v", err)
+	}
+	if _, err := sto.GetAccountUser("github", other.Subject); err != store.ErrNoResults {
+		t.Errorf("expected no account to be linked without a user, got %v", err)
+	}
+}
+
+// Moderation checks the moderator who approved or rejected a resource is kept with it, that
+// approving it twice does nothing and only its first approval is recorded in the feed
+func Moderation(t *testing.T, sto store.Service) {
+	s := suffix()
+	moderator, err := sto.CreateUser(store.User{Username: "moderator-" + s, Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	submitter, err := sto.CreateUser(store.User{Username: "submitter-" + s, Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := sto.CreateResource(store.Resource{Name: "moderated", URL: "https://example.com/moderated-" + s, Submitter: submitter})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sto.RejectResource(id, moderator, "spam"); err != nil {
+		t.Fatal(err)
+	}
+	resource, err := sto.GetResource(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resource.Rejected || resource.RejectionReason != "spam" || resource.ModeratedBy != moderator || resource.ModeratedAt == nil {
+		t.Errorf("expected a rejection by %d, got %+v", moderator, resource)
+	}
+
+	// approving, editing and approving again only makes the feed once
+	if _, err := sto.FollowUser(moderator, submitter); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"approved", "edited"} {
+		resource.Name = name
+		if err := sto.UpdateResource(resource, submitter); err != nil {
+			t.Fatal(err)
+		}
+		if approved, err := sto.ApproveResource(id, moderator); err != nil || !approved {
+			t.Fatalf("expected the %s resource to be approved: %v", name, err)
+		}
+	}
+	if approved, err := sto.ApproveResource(id, moderator); err != nil || approved {
+		t.Errorf("expected approving an approved resource to change nothing, got %v: %v", approved, err)
+	}
+	events, err := sto.GetFeed(moderator, store.FeedQuery{Limit: 10})
+	if err != nil && err != store.ErrNoResults {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Subject != id {
+		t.Errorf("expected one approval of %d in the feed, got %+v", id, events)
+	}
+}
@@ -0,0 +1,37 @@
+package store
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// hotEpoch is subtracted from creation times so hot scores stay small
+const hotEpoch = 1514764800 // 2018-01-01T00:00:00Z
+
+// hotDecay is how many seconds of age it takes to outweigh ten times the votes
+const hotDecay = 45000
+
+// ValidateVote checks a vote is an upvote (1), a downvote (-1) or withdrawn (0)
+func ValidateVote(value int) error {
+	if value < -1 || value > 1 {
+		return fmt.Errorf("a vote must be 1, -1 or 0")
+	}
+	return nil
+}
+
+// HotScore ranks resources for SortHot, votes count logarithmically and newer resources
+// get a boost that grows steadily with time, so the score of a resource only changes when
+// it's voted on and can be stored with it
+func HotScore(score int64, createdAt time.Time) float64 {
+	order := math.Log10(math.Max(math.Abs(float64(score)), 1))
+
+	sign := 0.0
+	if score > 0 {
+		sign = 1
+	} else if score < 0 {
+		sign = -1
+	}
+
+	return sign*order + float64(createdAt.Unix()-hotEpoch)/hotDecay
+}
@@ -0,0 +1,21 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHotScore(t *testing.T) {
+	now := time.Now()
+
+	if HotScore(10, now) <= HotScore(1, now) {
+		t.Error("more votes should rank higher at the same age")
+	}
+	if HotScore(-5, now) >= HotScore(0, now) {
+		t.Error("a downvoted resource should rank below an unvoted one")
+	}
+	// ten times the votes is worth hotDecay seconds of age
+	if older, newer := HotScore(100, now.Add(-hotDecay*time.Second)), HotScore(10, now); older-newer > 1e-9 || newer-older > 1e-9 {
+		t.Errorf("expected %v and %v to tie", older, newer)
+	}
+}